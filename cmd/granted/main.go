@@ -3,7 +3,6 @@ package main
 import (
 	"fmt"
 	"os"
-	"os/signal"
 	"path/filepath"
 	"runtime"
 	"syscall"
@@ -16,10 +15,7 @@ import (
 )
 
 func main() {
-	c := make(chan os.Signal, 1)
-	signal.Notify(c, syscall.SIGINT, syscall.SIGTERM)
-	go func() {
-		<-c
+	assume.HandleExitSignals(func() {
 		// restore cursor in case spinner gets stuck
 		// https://github.com/apppackio/apppack/commit/a711e55238af2402b4b027a73fccc663ec7ba0f4
 		// https://github.com/briandowns/spinner/issues/122
@@ -27,7 +23,7 @@ func main() {
 			_, _ = fmt.Fprint(os.Stdin, "\033[?25h")
 		}
 		os.Exit(130)
-	}()
+	}, syscall.SIGINT, syscall.SIGTERM)
 
 	// Use a single binary to keep keychain ACLs simple, swapping behavior via argv[0]
 	var app *cli.App
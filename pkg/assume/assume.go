@@ -1,6 +1,7 @@
 package assume
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"net/url"
@@ -27,6 +28,7 @@ import (
 	"github.com/common-fate/granted/pkg/config"
 	"github.com/common-fate/granted/pkg/console"
 	"github.com/common-fate/granted/pkg/forkprocess"
+	"github.com/common-fate/granted/pkg/idclogin"
 	"github.com/common-fate/granted/pkg/launcher"
 	"github.com/common-fate/granted/pkg/testable"
 	cfflags "github.com/common-fate/granted/pkg/urfav_overrides"
@@ -100,12 +102,23 @@ func AssumeCommand(c *cli.Context) error {
 		return err
 	}
 
+	if assumeFlags.Bool("no-browser") {
+		// set for the login flow, and any credential process invoked by the AWS SDK
+		_ = os.Setenv(idclogin.EnvHeadless, "true")
+	}
+
 	if assumeFlags.String("exec") != "" && runtime.GOOS == "windows" {
 		return clierr.New("--exec flag is not currently supported on Windows",
 			clierr.Info("Let us know if you'd like support for this by creating an issue on our Github repo: https://github.com/common-fate/granted/issues/new"),
 		)
 	}
 
+	switch assumeFlags.String("output") {
+	case assumeprint.FormatShell, assumeprint.FormatJSON:
+	default:
+		return fmt.Errorf("unknown output format %q: allowed formats are %s, %s", assumeFlags.String("output"), assumeprint.FormatShell, assumeprint.FormatJSON)
+	}
+
 	profileName, execCfg, err := processArgsAndExecFlag(c, assumeFlags)
 	if err != nil {
 		return err
@@ -113,12 +126,31 @@ func AssumeCommand(c *cli.Context) error {
 	clio.Debug("processed profile name", profileName)
 	clio.Debug("exec config:", execCfg)
 
+	if execCfg == nil {
+		additionalProfiles, err := assumeFlags.Args()
+		if err != nil {
+			return err
+		}
+		if len(additionalProfiles) > 0 {
+			return assumeMultiple(c, assumeFlags, append([]string{profileName}, additionalProfiles...))
+		}
+	}
+
 	activeRoleProfile := assumeFlags.String("active-aws-profile")
 	activeRoleFlag := assumeFlags.Bool("active-role")
 
 	showRerunCommand := false
+	// ssoOnly is true if the profile isn't in the AWS config file, so the SSO account and role are exported instead of AWS_PROFILE
+	ssoOnly := assumeFlags.Bool("sso")
 	var profile *cfaws.Profile
-	if assumeFlags.Bool("sso") {
+	if assumeFlags.Bool("sso-list") {
+		var local bool
+		profile, local, err = pickSSOProfile(c.Context, assumeFlags)
+		if err != nil {
+			return err
+		}
+		ssoOnly = !local
+	} else if assumeFlags.Bool("sso") {
 		profile, err = SSOProfileFromFlags(c)
 		if err != nil {
 			return err
@@ -127,41 +159,30 @@ func AssumeCommand(c *cli.Context) error {
 		// save the profile to the AWS config file if the user requested it.
 		saveProfileName := assumeFlags.String("save-to")
 		if saveProfileName != "" {
-			configFilename := cfaws.GetAWSConfigPath()
-			config, err := ini.LoadSources(ini.LoadOptions{
+			err = cfaws.UpdateFile(cfaws.GetAWSConfigPath(), ini.LoadOptions{
 				AllowNonUniqueSections:  false,
 				SkipUnrecognizableLines: false,
 				AllowNestedValues:       true,
-			}, configFilename)
-			if err != nil {
-				if !os.IsNotExist(err) {
-					return err
-				}
-				config = ini.Empty()
-			}
-			err = awsconfigfile.Merge(awsconfigfile.MergeOpts{
-				Config:              config,
-				SectionNameTemplate: saveProfileName,
-				Profiles: []awsconfigfile.SSOProfile{
-					{
-						SSOStartURL:   profile.SSOStartURL(),
-						SSORegion:     profile.SSORegion(),
-						AccountID:     profile.AWSConfig.SSOAccountID,
-						AccountName:   profile.AWSConfig.SSOAccountID,
-						RoleName:      profile.AWSConfig.SSORoleName,
-						GeneratedFrom: "commonfate",
+			}, func(config *ini.File) error {
+				return awsconfigfile.Merge(awsconfigfile.MergeOpts{
+					Config:              config,
+					SectionNameTemplate: saveProfileName,
+					Profiles: []awsconfigfile.SSOProfile{
+						{
+							SSOStartURL:   profile.SSOStartURL(),
+							SSORegion:     profile.SSORegion(),
+							AccountID:     profile.AWSConfig.SSOAccountID,
+							AccountName:   profile.AWSConfig.SSOAccountID,
+							RoleName:      profile.AWSConfig.SSORoleName,
+							GeneratedFrom: "commonfate",
+						},
 					},
-				},
+				})
 			})
 			if err != nil {
 				return err
 			}
 
-			err = config.SaveTo(configFilename)
-			if err != nil {
-				return err
-			}
-
 			clio.Successf("Saved AWS profile as %s. You can use this profile with the AWS CLI using the '--profile' flags when running AWS commands.", saveProfileName)
 		}
 	} else if activeRoleFlag && os.Getenv("GRANTED_SSO") == "true" {
@@ -263,41 +284,14 @@ func AssumeCommand(c *cli.Context) error {
 		}
 	}
 
-	var region string
-	// The region flag may be supplied in shorthand form, first check if the flag is set and expand the region
-	// else use the profile region
-	if assumeFlags.String("region") != "" {
-		regionFlag := assumeFlags.String("region")
-		region, err = cfaws.ExpandRegion(regionFlag)
-		if err != nil {
-			return fmt.Errorf("couldn't parse region %s: %v", region, err)
-		}
-	} else {
-		region, err = profile.Region(c.Context)
-		if err != nil {
-			return err
-		}
-	}
-
-	configOpts := cfaws.ConfigOpts{
-		Duration:     time.Hour,
-		MFATokenCode: assumeFlags.String("mfa-token"),
-		Args:         assumeFlags.StringSlice("pass-through"),
-		DisableCache: assumeFlags.Bool("no-cache"),
-	}
-
-	// attempt to get session duration from profile
-	if profile.AWSConfig.RoleDurationSeconds != nil {
-		configOpts.Duration = *profile.AWSConfig.RoleDurationSeconds
+	region, err := regionForProfile(c.Context, profile, assumeFlags)
+	if err != nil {
+		return err
 	}
 
-	duration := assumeFlags.String("duration")
-	if duration != "" {
-		d, err := time.ParseDuration(duration)
-		if err != nil {
-			return err
-		}
-		configOpts.Duration = d
+	configOpts, err := configOptsForProfile(profile, assumeFlags)
+	if err != nil {
+		return err
 	}
 
 	cfg, err := config.Load()
@@ -322,12 +316,7 @@ func AssumeCommand(c *cli.Context) error {
 			Destination: assumeFlags.String("console-destination"),
 		}
 
-		creds, err := profile.AssumeConsole(c.Context, configOpts)
-		if err != nil && strings.HasPrefix(err.Error(), "no access") {
-			clio.Debugw("received a No Access error", "error", err)
-			// TODO: this is where we can add a hook in future to allow users to define a shell script to be executed to automatically request access, etc.
-		}
-
+		creds, err := assumeWithHooks(c.Context, cfg.Hooks, hookInput(profile, region, assumeFlags), configOpts, profile.AssumeConsole)
 		if err != nil {
 			return err
 		}
@@ -429,18 +418,11 @@ func AssumeCommand(c *cli.Context) error {
 
 	// check if it's needed to provide credentials to terminal or default to it if console wasn't specified
 	if assumeFlags.Bool("terminal") || !getConsoleURL {
-		creds, err := profile.AssumeTerminal(c.Context, configOpts)
-		if err != nil && strings.HasPrefix(err.Error(), "no access") {
-			clio.Debugw("received a No Access error", "error", err)
-			// TODO: this is where we can add a hook in future to allow users to define a shell script to be executed to automatically request access, etc.
-		}
-
+		creds, err := assumeWithHooks(c.Context, cfg.Hooks, hookInput(profile, region, assumeFlags), configOpts, profile.AssumeTerminal)
 		if err != nil {
 			return err
 		}
-		sessionExpiration := ""
 		if creds.CanExpire {
-			sessionExpiration = creds.Expires.Local().Format(time.RFC3339)
 			// We add 10 seconds here as a fudge factor, the credentials will be a
 			// few seconds old already.
 			durationDescription := durafmt.Parse(time.Until(creds.Expires) + 10*time.Second).LimitFirstN(1).String()
@@ -486,78 +468,138 @@ func AssumeCommand(c *cli.Context) error {
 			clio.Success("Exported sso token to ~/.aws/sso/cache")
 		}
 
+		outputFormat := assumeFlags.String("output")
+		sso := &assumeprint.SSO{
+			StartURL:  profile.SSOStartURL(),
+			Region:    profile.SSORegion(),
+			AccountID: profile.AWSConfig.SSOAccountID,
+			RoleName:  profile.AWSConfig.SSORoleName,
+		}
+
 		if execCfg != nil {
-			escapedCmd := shellescape.QuoteCommand(append([]string{execCfg.Cmd}, execCfg.Args...))
-			output := PrepareStringsForShellScript([]string{creds.AccessKeyID, creds.SecretAccessKey, creds.SessionToken, "", region, "", "false", "", "", "", "", escapedCmd})
-			fmt.Printf("GrantedExec %s %s %s %s %s %s %s %s %s %s %s %s", output...)
-			return nil
+			out := assumeprint.Output{
+				Credentials: assumeprint.NewCredentials(creds),
+				Region:      region,
+				Exec:        shellescape.QuoteCommand(append([]string{execCfg.Cmd}, execCfg.Args...)),
+			}
+			return out.Print(os.Stdout, "GrantedExec", outputFormat)
 		}
 
 		if profile.RawConfig != nil && profile.RawConfig.HasKey("credential_process") && (assumeFlags.Bool("export-all-env-vars") || cfg.DefaultExportAllEnvVar) {
-			output := PrepareStringsForShellScript([]string{creds.AccessKeyID, creds.SecretAccessKey, creds.SessionToken, profile.Name, region, sessionExpiration, "true", profile.SSOStartURL(), profile.AWSConfig.SSORoleName, profile.SSORegion(), profile.AWSConfig.SSOAccountID, ""})
-			fmt.Printf("GrantedAssume %s %s %s %s %s %s %s %s %s %s %s %s", output...)
-			return nil
+			out := assumeprint.Output{
+				Credentials: assumeprint.NewCredentials(creds),
+				Profile:     profile.Name,
+				Region:      region,
+				SSO:         sso,
+			}
+			return out.Print(os.Stdout, "GrantedAssume", outputFormat)
 		}
 
 		// DO NOT REMOVE, this interacts with the shell script that wraps the assume command, the shell script is what configures your shell environment vars
-		// to export more environment variables, add then in the assume and assume.fish scripts then append them to this output preparation function
+		// to export more environment variables, add them to assumeprint.ManagedEnvVars and set them in assumeprint.Output.EnvVars.
 		// the shell script treats "None" as an empty string and will not set a value for that positional output
 
 		// If the profile uses "credential_process" to source credential externally then do not set accessKeyId, secretAccessKey, sessionToken
 		// so that aws cli automatically refreshes credential when they expire.
 		if profile.RawConfig != nil && profile.RawConfig.HasKey("credential_process") {
-			output := PrepareStringsForShellScript([]string{"", "", "", profile.Name, region, "", "", "", "", "", "", ""})
-			fmt.Printf("GrantedAssume %s %s %s %s %s %s %s %s %s %s %s %s", output...)
-
-			return nil
+			out := assumeprint.Output{
+				Profile: profile.Name,
+				Region:  region,
+			}
+			return out.Print(os.Stdout, "GrantedAssume", outputFormat)
 		}
 
-		if assumeFlags.Bool("sso") {
-			output := PrepareStringsForShellScript([]string{creds.AccessKeyID, creds.SecretAccessKey, creds.SessionToken, "", region, sessionExpiration, "true", profile.SSOStartURL(), profile.AWSConfig.SSORoleName, profile.SSORegion(), profile.AWSConfig.SSOAccountID, ""})
-			fmt.Printf("GrantedAssume %s %s %s %s %s %s %s %s %s %s %s %s", output...)
+		if ssoOnly {
+			out := assumeprint.Output{
+				Credentials: assumeprint.NewCredentials(creds),
+				Region:      region,
+				SSO:         sso,
+			}
+			return out.Print(os.Stdout, "GrantedAssume", outputFormat)
+		}
 
-			return nil
+		out := assumeprint.Output{
+			Credentials: assumeprint.NewCredentials(creds),
+			Profile:     profile.Name,
+			Region:      region,
 		}
 
 		//  When using the `--chain` flag, the profile does not exist because it is an ARN we are assuming, not a profile generated by granted.
 		//  Since the profile does not exist, the awscli will error due to it attempting to use a non-existent profile.
 		if assumeFlags.String("chain") != "" {
-			profile.Name = "None"
+			out.Profile = ""
 		}
 
-		output := PrepareStringsForShellScript([]string{creds.AccessKeyID, creds.SecretAccessKey, creds.SessionToken, profile.Name, region, sessionExpiration, "false", "", "", "", "", ""})
-		fmt.Printf("GrantedAssume %s %s %s %s %s %s %s %s %s %s %s %s", output...)
+		return out.Print(os.Stdout, "GrantedAssume", outputFormat)
 	}
 	return nil
 }
 
-// PrepareCredentialsForShellScript will set empty values to "None", this is required by the shell script to identify which variables to unset
-// it is also required to ensure that the return values are correctly split, e.g if sessionToken is "" then profile name will be used to set the session token environment variable
-func PrepareStringsForShellScript(in []string) []interface{} {
-	out := []interface{}{}
-	for _, s := range in {
-		if s == "" {
-			out = append(out, "None")
-		} else {
-			out = append(out, s)
-		}
+// regionForProfile returns the region to use for a profile. The region flag may be supplied in
+// shorthand form, so if the flag is set it is expanded, otherwise the profile region is used.
+func regionForProfile(ctx context.Context, profile *cfaws.Profile, assumeFlags *cfflags.Flags) (string, error) {
+	regionFlag := assumeFlags.String("region")
+	if regionFlag == "" {
+		return profile.Region(ctx)
+	}
+	region, err := cfaws.ExpandRegion(regionFlag)
+	if err != nil {
+		return "", fmt.Errorf("couldn't parse region %s: %v", regionFlag, err)
+	}
+	return region, nil
+}
+
+// configOptsForProfile returns the options used to assume a profile. The session duration is
+// taken from the profile, unless it is overridden with the duration flag.
+func configOptsForProfile(profile *cfaws.Profile, assumeFlags *cfflags.Flags) (cfaws.ConfigOpts, error) {
+	configOpts := cfaws.ConfigOpts{
+		Duration:     time.Hour,
+		MFATokenCode: assumeFlags.String("mfa-token"),
+		Args:         assumeFlags.StringSlice("pass-through"),
+		DisableCache: assumeFlags.Bool("no-cache"),
+	}
 
+	// attempt to get session duration from profile
+	if profile.AWSConfig.RoleDurationSeconds != nil {
+		configOpts.Duration = *profile.AWSConfig.RoleDurationSeconds
 	}
-	return out
+
+	duration := assumeFlags.String("duration")
+	if duration != "" {
+		d, err := time.ParseDuration(duration)
+		if err != nil {
+			return cfaws.ConfigOpts{}, err
+		}
+		configOpts.Duration = d
+	}
+
+	return configOpts, nil
+}
+
+// PrepareStringsForShellScript will set empty values to "None".
+//
+// Deprecated: use assumeprint.PrepareStringsForShellScript instead.
+func PrepareStringsForShellScript(in []string) []interface{} {
+	return assumeprint.PrepareStringsForShellScript(in)
 }
 
 // RunExecCommandWithCreds takes in a command, which may be a program and arguments separated by spaces
 // it splits these then runs the command with the credentials as the environment.
 // The output of this is returned via the assume script to stdout so it may be processed further by piping
+//
+// The exit code of the command is returned as a cli.ExitCoder, so that Granted exits with the same code.
 func RunExecCommandWithCreds(creds aws.Credentials, region string, cmd string, args ...string) error {
 	fmt.Print(assumeprint.SafeOutput(""))
-	c := exec.Command(cmd, args...)
-	c.Stdout = os.Stdout
-	c.Stderr = os.Stderr
 
 	// the aws profile env var will break the exec flow so we strip it out
-	c.Env = append(removeEnvKeys(os.Environ(), []string{"AWS_PROFILE"}), EnvKeys(creds, region)...)
-	return c.Run()
+	code, err := RunSupervised(execEnvForCreds(creds, region), cmd, args...)
+	if err != nil {
+		return err
+	}
+	if code != 0 {
+		return cli.Exit("", code)
+	}
+	return nil
 }
 func removeEnvKeys(env []string, keysToRemove []string) []string {
 	remainingEnv := []string{}
@@ -41,6 +41,7 @@ func GlobalFlags() []cli.Flag {
 		&cli.StringFlag{Name: "exec", Usage: "Assume a profile then execute this command"},
 		&cli.StringFlag{Name: "duration", Aliases: []string{"d"}, Usage: "Set session duration for your assumed role"},
 		&cli.BoolFlag{Name: "sso", Usage: "Assume an account and role with provided SSO flags"},
+		&cli.BoolFlag{Name: "sso-list", Usage: "Pick an account and role to assume from those available to you in IAM Identity Center. Uses the [SSO.default] entry in the Granted config file, or --sso-start-url and --sso-region"},
 		&cli.StringFlag{Name: "sso-start-url", Usage: "Use this in conjunction with --sso, the sso-start-url"},
 		&cli.StringFlag{Name: "sso-region", Usage: "Use this in conjunction with --sso, the sso-region"},
 		&cli.StringFlag{Name: "account-id", Usage: "Use this in conjunction with --sso, the account-id"},
@@ -50,13 +51,17 @@ func GlobalFlags() []cli.Flag {
 		&cli.StringFlag{Name: "save-to", Usage: "Use this in conjunction with --sso, the profile name to save the role to in your AWS config file"},
 		&cli.BoolFlag{Name: "export-all-env-vars", Aliases: []string{"x"}, Usage: "Exports all available credentials to the terminal when used with a profile configured for credential-process. Without this flag, only the AWS_PROFILE will be configured"},
 		&cli.StringFlag{Name: "aws-config-file"},
+		&cli.BoolFlag{Name: "no-browser", Usage: "Print the login URL for IAM Identity Center rather than opening a browser. Can also be set using GRANTED_HEADLESS=true"},
 		&cli.StringFlag{Name: "chain", Usage: "Assume a given role ARN using the profile selected"},
-		&cli.StringFlag{Name: "reason", Usage: "Provide a reason for requesting access to the role"},
+		&cli.StringFlag{Name: "reason", Usage: "Provide a reason for requesting access to the role, this is passed to any configured hooks"},
 		&cli.BoolFlag{Name: "confirm", Aliases: []string{"y"}, Usage: "Skip confirmation prompts for access requests"},
-		&cli.BoolFlag{Name: "wait", Usage: "When using Granted with Common Fate the assume will halt while waiting for the access request to be approved."},
+		&cli.BoolFlag{Name: "wait", Usage: "Passed to configured hooks to indicate that the assume should halt while waiting for the access request to be approved."},
 		&cli.BoolFlag{Name: "no-cache", Usage: "Disables caching of session credentials and forces a refresh", EnvVars: []string{"GRANTED_NO_CACHE"}},
 		&cli.StringSliceFlag{Name: "browser-launch-template-arg", Usage: "Additional arguments to provide to the browser launch template command in key=value format, e.g. '--browser-launch-template-arg foo=bar"},
 		&cli.BoolFlag{Name: "skip-profile-registry-sync", Usage: "You can use this to skip the automated profile registry sync process."},
+		&cli.BoolFlag{Name: "force-profile-registry-sync", Usage: "Sync all profile registries before assuming, even if they have been synced within their sync interval or background sync is enabled."},
+		&cli.StringFlag{Name: "output", Aliases: []string{"o"}, Usage: "Output format for assumed credentials, either 'shell' (the default, read by the assume shell script) or 'json'", Value: "shell"},
+		&cli.StringFlag{Name: "bundle", Usage: "When assuming multiple profiles, write the credentials for each profile to this JSON file"},
 		&cli.StringSliceFlag{Name: "attach", Usage: "Attach justifications to your request, such as a Jira ticket id or url `--attach=TP-123`"},
 	}
 }
@@ -71,7 +76,7 @@ func GetCliApp() *cli.App {
 		Name:                 "assume",
 		Writer:               os.Stderr,
 		Usage:                "https://granted.dev",
-		UsageText:            "assume [options][Profile]",
+		UsageText:            "assume [options][Profile] [Profile...]",
 		Version:              build.Version,
 		HideVersion:          false,
 		Flags:                GlobalFlags(),
@@ -144,7 +149,7 @@ func GetCliApp() *cli.App {
 
 			if !c.Bool("skip-profile-registry-sync") {
 				// Sync granted profile registries if enabled
-				autosync.Run(c.Context, true)
+				autosync.Run(c.Context, autosync.Opts{Interactive: true, Force: c.Bool("force-profile-registry-sync")})
 			} else {
 				clio.Debug("skipping profile registry sync because --skip-profile-registry-sync flag was true")
 			}
@@ -0,0 +1,116 @@
+package assume
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"os/signal"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/common-fate/clio"
+)
+
+// credentialEnvKeys are removed from the environment of commands run with credentials,
+// as they would take precedence over the credentials provided by Granted.
+var credentialEnvKeys = []string{
+	"AWS_PROFILE",
+	"AWS_ACCESS_KEY_ID",
+	"AWS_SECRET_ACCESS_KEY",
+	"AWS_SESSION_TOKEN",
+	"AWS_CONTAINER_CREDENTIALS_FULL_URI",
+	"AWS_CONTAINER_AUTHORIZATION_TOKEN",
+}
+
+// ExecEnv returns the current environment with any existing AWS credentials removed,
+// with the provided variables appended.
+func ExecEnv(vars ...string) []string {
+	return append(removeEnvKeys(os.Environ(), credentialEnvKeys), vars...)
+}
+
+// exitSignals is the channel registered by HandleExitSignals, and exitSignalList are the signals it is notified of.
+var (
+	exitSignals    chan os.Signal
+	exitSignalList []os.Signal
+)
+
+// HandleExitSignals calls exit when one of the signals is received. It is called by main, so that the
+// terminal is restored when Granted is interrupted. The handler is paused while RunSupervised runs a child.
+func HandleExitSignals(exit func(), sigs ...os.Signal) {
+	exitSignals = make(chan os.Signal, 1)
+	exitSignalList = sigs
+	signal.Notify(exitSignals, sigs...)
+	go func(c chan os.Signal) {
+		<-c
+		exit()
+	}(exitSignals)
+}
+
+// RunSupervised runs a command as a child process with the provided environment and waits
+// for it to exit, and returns the exit code of the child.
+//
+// The child stays in the foreground process group, so the terminal already delivers Ctrl+C
+// (SIGINT and SIGQUIT) to it. Granted only catches these signals so that it keeps running until
+// the child exits, as forwarding them would deliver them to the child twice. Other signals, such as
+// SIGTERM and SIGHUP, are only delivered to Granted and are forwarded to the child.
+func RunSupervised(env []string, cmd string, args ...string) (int, error) {
+	c := exec.Command(cmd, args...)
+	c.Stdin = os.Stdin
+	c.Stdout = os.Stdout
+	c.Stderr = os.Stderr
+	c.Env = env
+
+	// the signals are caught rather than ignored, as ignored signals would also be ignored by the child
+	terminal := make(chan os.Signal, 1)
+	signal.Notify(terminal, terminalSignals...)
+	defer signal.Stop(terminal)
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, forwardedSignals...)
+	defer signal.Stop(sigs)
+
+	// take over from the handler registered in main, which exits immediately, and restore it once the child exits.
+	// It is restored before the signals above are stopped, so that there is always a handler for them.
+	if exitSignals != nil {
+		signal.Stop(exitSignals)
+		defer signal.Notify(exitSignals, exitSignalList...)
+	}
+
+	err := c.Start()
+	if err != nil {
+		return 0, err
+	}
+
+	done := make(chan struct{})
+	go func() {
+		for {
+			select {
+			case sig := <-sigs:
+				clio.Debugw("forwarding signal to child process", "signal", sig.String(), "pid", c.Process.Pid)
+				err := c.Process.Signal(sig)
+				if err != nil {
+					clio.Debugw("error forwarding signal", "signal", sig.String(), "error", err)
+				}
+			case sig := <-terminal:
+				clio.Debugw("waiting for child process to handle signal", "signal", sig.String(), "pid", c.Process.Pid)
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	err = c.Wait()
+	close(done)
+
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return exitCode(exitErr), nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	return 0, nil
+}
+
+// execEnvForCreds returns the environment for a command run with static credentials.
+func execEnvForCreds(creds aws.Credentials, region string) []string {
+	return ExecEnv(EnvKeys(creds, region)...)
+}
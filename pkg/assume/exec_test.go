@@ -0,0 +1,88 @@
+//go:build !windows
+
+package assume
+
+import (
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRunSupervised(t *testing.T) {
+	code, err := RunSupervised([]string{"GRANTED_TEST=value"}, "sh", "-c", `[ "$GRANTED_TEST" = "value" ] && exit 3`)
+	require.NoError(t, err)
+	assert.Equal(t, 3, code)
+
+	// a command killed by a signal exits with 128 + the signal number
+	code, err = RunSupervised(nil, "sh", "-c", "kill -TERM $$")
+	require.NoError(t, err)
+	assert.Equal(t, 143, code)
+
+	_, err = RunSupervised(nil, "granted-command-which-does-not-exist")
+	assert.Error(t, err)
+}
+
+func TestRunSupervisedInterrupt(t *testing.T) {
+	exited := make(chan struct{}, 1)
+	HandleExitSignals(func() { exited <- struct{}{} }, syscall.SIGINT)
+	t.Cleanup(func() {
+		signal.Stop(exitSignals)
+		exitSignals = nil
+	})
+
+	dir := t.TempDir()
+	pidFile := filepath.Join(dir, "pid")
+	countFile := filepath.Join(dir, "count")
+	script := `trap 'echo INT >> "$COUNT"' INT; echo $$ > "$PID.tmp"; mv "$PID.tmp" "$PID"; while [ ! -f "$PID.stop" ]; do sleep 0.05; done`
+
+	go func() {
+		var pid int
+		ok := assert.Eventually(t, func() bool {
+			data, err := os.ReadFile(pidFile)
+			if err != nil {
+				return false
+			}
+			pid, err = strconv.Atoi(strings.TrimSpace(string(data)))
+			return err == nil
+		}, 5*time.Second, 10*time.Millisecond)
+		if !ok {
+			return
+		}
+
+		// the terminal sends Ctrl+C to both Granted and the child
+		assert.NoError(t, syscall.Kill(os.Getpid(), syscall.SIGINT))
+		assert.NoError(t, syscall.Kill(pid, syscall.SIGINT))
+		time.Sleep(200 * time.Millisecond)
+		assert.NoError(t, os.WriteFile(pidFile+".stop", nil, 0600))
+	}()
+
+	code, err := RunSupervised([]string{"PID=" + pidFile, "COUNT=" + countFile}, "sh", "-c", script)
+	require.NoError(t, err)
+	assert.Equal(t, 0, code)
+
+	count, err := os.ReadFile(countFile)
+	require.NoError(t, err)
+	assert.Equal(t, "INT\n", string(count), "the child should receive SIGINT once")
+
+	select {
+	case <-exited:
+		t.Fatal("the exit handler shouldn't be called while the child is running")
+	default:
+	}
+
+	// the exit handler is restored once the child exits
+	require.NoError(t, syscall.Kill(os.Getpid(), syscall.SIGINT))
+	select {
+	case <-exited:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the exit handler wasn't restored")
+	}
+}
@@ -0,0 +1,24 @@
+//go:build !windows
+
+package assume
+
+import (
+	"os"
+	"os/exec"
+	"syscall"
+)
+
+// terminalSignals are sent by the terminal to every process in the foreground process group, including the child.
+var terminalSignals = []os.Signal{syscall.SIGINT, syscall.SIGQUIT}
+
+// forwardedSignals are only sent to Granted, so they are forwarded to the child.
+var forwardedSignals = []os.Signal{syscall.SIGTERM, syscall.SIGHUP, syscall.SIGUSR1, syscall.SIGUSR2}
+
+// exitCode returns the exit code of the process, following the shell convention
+// of 128 plus the signal number if the process was killed by a signal.
+func exitCode(err *exec.ExitError) int {
+	if status, ok := err.Sys().(syscall.WaitStatus); ok && status.Signaled() {
+		return 128 + int(status.Signal())
+	}
+	return err.ExitCode()
+}
@@ -0,0 +1,20 @@
+//go:build windows
+
+package assume
+
+import (
+	"os"
+	"os/exec"
+	"syscall"
+)
+
+// On Windows, the console delivers Ctrl+C to the child process directly. The signals are
+// still caught so that Granted waits for the child to exit rather than exiting immediately.
+var terminalSignals = []os.Signal{os.Interrupt, syscall.SIGTERM}
+
+// Signals can't be sent to other processes on Windows.
+var forwardedSignals []os.Signal
+
+func exitCode(err *exec.ExitError) int {
+	return err.ExitCode()
+}
@@ -0,0 +1,100 @@
+package assume
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/aws/arn"
+	"github.com/common-fate/clio"
+	"github.com/common-fate/granted/pkg/cfaws"
+	"github.com/common-fate/granted/pkg/config"
+	"github.com/common-fate/granted/pkg/hook"
+	cfflags "github.com/common-fate/granted/pkg/urfav_overrides"
+)
+
+type assumeFunc func(ctx context.Context, configOpts cfaws.ConfigOpts) (aws.Credentials, error)
+
+// hookInput builds the details about the assume which are passed to hooks.
+func hookInput(profile *cfaws.Profile, region string, assumeFlags *cfflags.Flags) hook.Input {
+	in := hook.Input{
+		Profile:   profile.Name,
+		AccountID: profile.AWSConfig.SSOAccountID,
+		RoleName:  profile.AWSConfig.SSORoleName,
+		RoleARN:   profile.AWSConfig.RoleARN,
+		Region:    region,
+		Reason:    assumeFlags.String("reason"),
+		Attach:    assumeFlags.StringSlice("attach"),
+		Wait:      assumeFlags.Bool("wait"),
+	}
+
+	if in.RoleARN != "" {
+		parsed, err := arn.Parse(in.RoleARN)
+		if err == nil {
+			in.AccountID = parsed.AccountID
+			in.RoleName = strings.TrimPrefix(parsed.Resource, "role/")
+		}
+	}
+
+	return in
+}
+
+// isNoAccessError returns true if the user does not have access to the role they are assuming.
+func isNoAccessError(err error) bool {
+	var noAccess cfaws.NoAccessError
+	return errors.As(err, &noAccess) || strings.HasPrefix(err.Error(), "no access")
+}
+
+// assumeWithHooks calls assume, running any configured hooks around it.
+//
+// If access is denied and on-no-access hooks are configured, the hooks are run
+// and the assume is retried with ShouldRetryAssuming set, to allow time for
+// the access to be provisioned.
+func assumeWithHooks(ctx context.Context, hooks *config.HooksConfig, input hook.Input, configOpts cfaws.ConfigOpts, assume assumeFunc) (aws.Credentials, error) {
+	pre := input
+	pre.Event = hook.EventPreAssume
+	err := hook.Run(ctx, hook.HooksForEvent(hooks, hook.EventPreAssume), pre)
+	if err != nil {
+		return aws.Credentials{}, err
+	}
+
+	creds, err := assume(ctx, configOpts)
+	if err != nil && isNoAccessError(err) {
+		clio.Debugw("received a No Access error", "error", err)
+
+		noAccessHooks := hook.HooksForEvent(hooks, hook.EventNoAccess)
+		if len(noAccessHooks) == 0 {
+			return aws.Credentials{}, err
+		}
+
+		noAccess := input
+		noAccess.Event = hook.EventNoAccess
+		noAccess.Error = err.Error()
+		hookErr := hook.Run(ctx, noAccessHooks, noAccess)
+		if hookErr != nil {
+			return aws.Credentials{}, errors.Join(err, hookErr)
+		}
+
+		clio.Infof("%s hooks completed successfully, retrying assuming %s", hook.EventNoAccess, input.Profile)
+		shouldRetry := true
+		configOpts.ShouldRetryAssuming = &shouldRetry
+		creds, err = assume(ctx, configOpts)
+	}
+	if err != nil {
+		return aws.Credentials{}, err
+	}
+
+	post := input
+	post.Event = hook.EventPostAssume
+	if creds.CanExpire {
+		post.Expiration = &creds.Expires
+	}
+	err = hook.Run(ctx, hook.HooksForEvent(hooks, hook.EventPostAssume), post)
+	if err != nil {
+		// the credentials are valid at this point, so don't fail the assume
+		clio.Warnf("%s", err.Error())
+	}
+
+	return creds, nil
+}
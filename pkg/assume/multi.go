@@ -0,0 +1,238 @@
+package assume
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/common-fate/clio"
+	"github.com/common-fate/granted/pkg/assumeprint"
+	"github.com/common-fate/granted/pkg/cfaws"
+	"github.com/common-fate/granted/pkg/config"
+	cfflags "github.com/common-fate/granted/pkg/urfav_overrides"
+	"github.com/urfave/cli/v2"
+)
+
+// multiProfileIncompatibleFlags can't be used when assuming several profiles at once,
+// as they open a console or export credentials for a single profile into the shell.
+var multiProfileIncompatibleFlags = []string{"console", "console-destination", "url", "service", "active-role", "browser-profile", "sso", "sso-list", "chain", "exec", "export-sso-token"}
+
+type assumeResult struct {
+	profile *cfaws.Profile
+	region  string
+	creds   aws.Credentials
+}
+
+// assumeMultiple assumes several profiles in parallel, exporting the credentials for each one
+// under its own name to the AWS credentials file, a .env file, or a JSON bundle.
+//
+// For example: 'assume shared-services workload --export'
+func assumeMultiple(c *cli.Context, assumeFlags *cfflags.Flags, profileNames []string) error {
+	for _, f := range multiProfileIncompatibleFlags {
+		if assumeFlags.Bool(f) || (assumeFlags.String(f) != "" && assumeFlags.String(f) != "false") {
+			return fmt.Errorf("the --%s flag can't be used when assuming multiple profiles", f)
+		}
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		return err
+	}
+
+	bundlePath := assumeFlags.String("bundle")
+	printBundle := assumeFlags.String("output") == assumeprint.FormatJSON
+	exportToCredentials := assumeFlags.Bool("export") || cfg.ExportCredsToAWS
+	exportToEnv := assumeFlags.Bool("env")
+
+	if !exportToCredentials && !exportToEnv && bundlePath == "" && !printBundle {
+		return errors.New("when assuming multiple profiles, use --export, --env, --bundle <file> or --output json to choose where the credentials are exported to")
+	}
+
+	profiles, err := cfaws.LoadProfiles()
+	if err != nil {
+		return err
+	}
+
+	// initialise the profiles up front, as profiles may share parents in the profile tree
+	var toAssume []*cfaws.Profile
+	seen := map[string]bool{}
+	for _, name := range profileNames {
+		if seen[name] {
+			continue
+		}
+		seen[name] = true
+
+		if !profiles.HasProfile(name) {
+			return fmt.Errorf("%s does not match any profiles in your AWS config or credentials files", name)
+		}
+		profile, err := profiles.LoadInitialisedProfile(c.Context, name)
+		if err != nil {
+			return err
+		}
+		toAssume = append(toAssume, profile)
+	}
+
+	results, err := assumeProfilesInParallel(c.Context, cfg, assumeFlags, toAssume)
+	if err != nil {
+		return err
+	}
+
+	for _, r := range results {
+		cfaws.UpdateFrecencyCache(r.profile.Name)
+		if os.Getenv("GRANTED_QUIET") != "true" {
+			clio.Successf("[%s](%s) session credentials ready", r.profile.Name, r.region)
+		}
+	}
+
+	if exportToCredentials {
+		for _, r := range results {
+			err = cfaws.ExportCredsToProfile(r.profile.Name, r.creds)
+			if err != nil {
+				return fmt.Errorf("exporting credentials for %s: %w", r.profile.Name, err)
+			}
+		}
+		if cfg.ExportCredentialSuffix == "" {
+			clio.Warn("No credential suffix found. This can cause issues with using exported credentials if conflicting profiles exist. Run `granted settings export-suffix set` to set one.")
+		}
+		clio.Successf("Exported credentials for %d profiles to %s", len(results), cfaws.GetAWSCredentialsPath())
+	}
+
+	if exportToEnv {
+		var named []cfaws.NamedCredentials
+		for _, r := range results {
+			named = append(named, cfaws.NamedCredentials{Name: r.profile.Name, Region: r.region, Credentials: r.creds})
+		}
+		err = cfaws.WriteNamedCredentialsToDotenv(named)
+		if err != nil {
+			return err
+		}
+		clio.Successf("Exported credentials for %d profiles to .env file successfully", len(results))
+	}
+
+	bundle := assumeprint.Bundle{Profiles: map[string]assumeprint.Output{}}
+	for _, r := range results {
+		bundle.Profiles[r.profile.Name] = assumeprint.Output{
+			Profile:     r.profile.Name,
+			Region:      r.region,
+			Credentials: assumeprint.NewCredentials(r.creds),
+		}
+	}
+
+	if bundlePath != "" {
+		err = bundle.WriteFile(bundlePath)
+		if err != nil {
+			return err
+		}
+		clio.Successf("Exported credentials for %d profiles to %s", len(results), bundlePath)
+	}
+
+	if printBundle {
+		return bundle.Print(os.Stdout)
+	}
+
+	return nil
+}
+
+// assumeProfilesInParallel assumes each profile concurrently, returning the results in the same order as the profiles.
+//
+// Profiles which share an IAM Identity Center start URL share an SSO token, so the first profile
+// for each start URL is assumed before the others, to avoid prompting the user to log in several times.
+// Profiles which can prompt, such as for an MFA token, are assumed one at a time so that their prompts don't overlap.
+func assumeProfilesInParallel(ctx context.Context, cfg *config.Config, assumeFlags *cfflags.Flags, profiles []*cfaws.Profile) ([]assumeResult, error) {
+	results := make([]assumeResult, len(profiles))
+	errs := make([]error, len(profiles))
+
+	assumeProfile := func(i int) {
+		profile := profiles[i]
+		region, err := regionForProfile(ctx, profile, assumeFlags)
+		if err != nil {
+			errs[i] = fmt.Errorf("%s: %w", profile.Name, err)
+			return
+		}
+		configOpts, err := configOptsForProfile(profile, assumeFlags)
+		if err != nil {
+			errs[i] = fmt.Errorf("%s: %w", profile.Name, err)
+			return
+		}
+		creds, err := assumeWithHooks(ctx, cfg.Hooks, hookInput(profile, region, assumeFlags), configOpts, profile.AssumeTerminal)
+		if err != nil {
+			errs[i] = fmt.Errorf("%s: %w", profile.Name, err)
+			return
+		}
+		results[i] = assumeResult{profile: profile, region: region, creds: creds}
+	}
+
+	assumeConcurrently := func(batch []int) {
+		var wg sync.WaitGroup
+		for _, i := range batch {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				assumeProfile(i)
+			}(i)
+		}
+		wg.Wait()
+	}
+
+	first, prompting, rest := assumeOrder(profiles)
+	assumeConcurrently(first)
+	for _, i := range prompting {
+		assumeProfile(i)
+	}
+	assumeConcurrently(rest)
+
+	err := errors.Join(errs...)
+	if err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// assumeOrder splits the profiles into the first profile for each IAM Identity Center start URL, the profiles which
+// can prompt, and the rest. The first and the rest are assumed concurrently, and the profiles which can prompt are
+// assumed one at a time between them. A profile which can prompt logs in to its start URL, so the profiles
+// which share its start URL are assumed after it.
+func assumeOrder(profiles []*cfaws.Profile) (first, prompting, rest []int) {
+	startURLs := map[string]bool{}
+	for i, p := range profiles {
+		startURL := rootProfile(p).SSOStartURL()
+		switch {
+		case canPrompt(p):
+			startURLs[startURL] = true
+			prompting = append(prompting, i)
+		case startURL != "" && !startURLs[startURL]:
+			startURLs[startURL] = true
+			first = append(first, i)
+		default:
+			rest = append(rest, i)
+		}
+	}
+	return first, prompting, rest
+}
+
+// canPrompt returns true if assuming the profile may prompt on the terminal, other than to log in to IAM Identity Center.
+// Profiles which require MFA prompt for a token, and profiles sourced from tools such as aws-azure-login may prompt to log in.
+func canPrompt(p *cfaws.Profile) bool {
+	for _, profile := range append(append([]*cfaws.Profile{}, p.Parents...), p) {
+		if profile.AWSConfig.MFASerial != "" {
+			return true
+		}
+	}
+	switch rootProfile(p).ProfileType {
+	case "AWS_SSO", "AWS_IAM":
+		return false
+	default:
+		return true
+	}
+}
+
+// rootProfile returns the profile at the root of the profile's parent tree, which is the profile that sources the credentials.
+func rootProfile(p *cfaws.Profile) *cfaws.Profile {
+	if len(p.Parents) > 0 {
+		return p.Parents[0]
+	}
+	return p
+}
@@ -0,0 +1,34 @@
+package assume
+
+import (
+	"testing"
+
+	"github.com/common-fate/granted/pkg/cfaws"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAssumeOrder(t *testing.T) {
+	ssoProfile := func(name string, startURL string) *cfaws.Profile {
+		p := &cfaws.Profile{Name: name, ProfileType: "AWS_SSO"}
+		p.AWSConfig.SSOStartURL = startURL
+		return p
+	}
+	iam := &cfaws.Profile{Name: "iam", ProfileType: "AWS_IAM"}
+	mfa := &cfaws.Profile{Name: "mfa", ProfileType: "AWS_IAM"}
+	mfa.AWSConfig.MFASerial = "arn:aws:iam::123456789012:mfa/user"
+	chained := &cfaws.Profile{Name: "chained", ProfileType: "AWS_IAM", Parents: []*cfaws.Profile{mfa}}
+	azure := &cfaws.Profile{Name: "azure", ProfileType: "AWS_AZURE_LOGIN"}
+
+	first, prompting, rest := assumeOrder([]*cfaws.Profile{
+		ssoProfile("a", "https://a.awsapps.com/start"),
+		ssoProfile("a2", "https://a.awsapps.com/start"),
+		iam,
+		mfa,
+		chained,
+		azure,
+		ssoProfile("b", "https://b.awsapps.com/start"),
+	})
+	assert.Equal(t, []int{0, 6}, first)
+	assert.Equal(t, []int{3, 4, 5}, prompting)
+	assert.Equal(t, []int{1, 2}, rest)
+}
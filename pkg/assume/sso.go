@@ -16,20 +16,8 @@ func SSOProfileFromFlags(c *cli.Context) (*cfaws.Profile, error) {
 	if err != nil {
 		return nil, err
 	}
-	s := &cfaws.AwsSsoAssumer{}
 	ssoStartURL, ssoRegion, accountID, roleName := ssoFlags(c)
-	p := &cfaws.Profile{
-		Name:        roleName,
-		ProfileType: s.Type(),
-		AWSConfig: config.SharedConfig{
-			SSOAccountID: accountID,
-			SSORoleName:  roleName,
-			SSORegion:    ssoRegion,
-			SSOStartURL:  ssoStartURL,
-		},
-		Initialised: true,
-	}
-	return p, nil
+	return ssoProfile(ssoStartURL, ssoRegion, accountID, roleName), nil
 }
 
 // SSOProfileFromEnv will prepare a profile to be assumed from environment variables
@@ -41,8 +29,13 @@ func SSOProfileFromEnv() (*cfaws.Profile, error) {
 	if ssoStartURL == "" || ssoRegion == "" || accountID == "" || roleName == "" {
 		return nil, errors.New("one of the require environment variables was not found while loading an sso profile ['GRANTED_SSO_START_URL','GRANTED_SSO_REGION','GRANTED_SSO_ACCOUNT_ID','GRANTED_SSO_ROLE_NAME']")
 	}
+	return ssoProfile(ssoStartURL, ssoRegion, accountID, roleName), nil
+}
+
+// ssoProfile returns a profile for an account and role in IAM Identity Center which isn't in the AWS config file.
+func ssoProfile(ssoStartURL, ssoRegion, accountID, roleName string) *cfaws.Profile {
 	s := &cfaws.AwsSsoAssumer{}
-	p := &cfaws.Profile{
+	return &cfaws.Profile{
 		Name:        roleName,
 		ProfileType: s.Type(),
 		AWSConfig: config.SharedConfig{
@@ -53,7 +46,6 @@ func SSOProfileFromEnv() (*cfaws.Profile, error) {
 		},
 		Initialised: true,
 	}
-	return p, nil
 }
 
 func ssoFlags(c *cli.Context) (ssoStartURL, ssoRegion, accountID, roleName string) {
@@ -74,6 +66,10 @@ func ValidateSSOFlags(c *cli.Context) error {
 		if !good {
 			return errors.New("flags [sso-start-url, sso-region, account-id, role-name] are required to use the -sso flag")
 		}
+	} else if c.Bool("sso-list") {
+		if accountID != "" || roleName != "" {
+			return errors.New("flags [account-id, role-name] can't be used with the -sso-list flag, as the account and role are picked from a list")
+		}
 	} else if ssoStartURL != "" || ssoRegion != "" || accountID != "" || roleName != "" {
 		return errors.New("flags [sso-start-url, sso-region, account-id, role-name] can only be used with the -sso flag")
 	}
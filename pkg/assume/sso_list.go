@@ -0,0 +1,116 @@
+package assume
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/AlecAivazis/survey/v2"
+	"github.com/common-fate/clio"
+	"github.com/common-fate/granted/pkg/cfaws"
+	"github.com/common-fate/granted/pkg/testable"
+	cfflags "github.com/common-fate/granted/pkg/urfav_overrides"
+)
+
+// ssoListRole is an account and role from the JSON output of 'granted sso list'.
+type ssoListRole struct {
+	StartURL     string `json:"startUrl"`
+	SSORegion    string `json:"ssoRegion"`
+	AccountID    string `json:"accountId"`
+	AccountName  string `json:"accountName"`
+	RoleName     string `json:"roleName"`
+	LocalProfile string `json:"localProfile,omitempty"`
+}
+
+// listSSORoles lists the accounts and roles available in IAM Identity Center by running 'granted sso list'.
+// The assume and granted CLIs share a binary, so the listing, filters and inventory cache of 'granted sso list' are reused.
+// The start URL and region default to the [SSO.default] entry in the Granted config file.
+func listSSORoles(ctx context.Context, startURL string, ssoRegion string) ([]ssoListRole, error) {
+	exe, err := os.Executable()
+	if err != nil {
+		return nil, err
+	}
+
+	args := []string{"granted", "sso", "list", "--output", "json"}
+	if ssoRegion != "" {
+		args = append(args, "--sso-region", ssoRegion)
+	}
+	if startURL != "" {
+		args = append(args, startURL)
+	}
+
+	// the binary swaps between the 'granted' and 'assume' CLIs based on argv[0]
+	var env []string
+	for _, e := range os.Environ() {
+		if !strings.HasPrefix(e, "FORCE_ASSUME_CLI=") {
+			env = append(env, e)
+		}
+	}
+
+	var stdout bytes.Buffer
+	cmd := exec.CommandContext(ctx, exe)
+	cmd.Args = args
+	cmd.Env = env
+	// the login flow prompts on stderr, and stdout is read by the assume shell script
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = &stdout
+	cmd.Stderr = os.Stderr
+	err = cmd.Run()
+	if err != nil {
+		return nil, fmt.Errorf("listing accounts and roles with 'granted sso list': %w", err)
+	}
+
+	var roles []ssoListRole
+	err = json.Unmarshal(stdout.Bytes(), &roles)
+	if err != nil {
+		return nil, fmt.Errorf("reading the output of 'granted sso list': %w", err)
+	}
+	return roles, nil
+}
+
+// pickSSOProfile prompts for an account and role available in IAM Identity Center, and returns a profile to assume it.
+// The local profile is used if one exists, so that its configuration such as the region is applied.
+// local is false if the profile isn't in the AWS config file, in which case it is assumed in the same way as with --sso.
+func pickSSOProfile(ctx context.Context, assumeFlags *cfflags.Flags) (profile *cfaws.Profile, local bool, err error) {
+	roles, err := listSSORoles(ctx, assumeFlags.String("sso-start-url"), assumeFlags.String("sso-region"))
+	if err != nil {
+		return nil, false, err
+	}
+	if len(roles) == 0 {
+		return nil, false, errors.New("no accounts and roles are available")
+	}
+
+	options := make([]string, len(roles))
+	for i, r := range roles {
+		options[i] = fmt.Sprintf("%s (%s) / %s", r.AccountName, r.AccountID, r.RoleName)
+		if r.LocalProfile != "" {
+			options[i] += fmt.Sprintf(" [%s]", r.LocalProfile)
+		}
+	}
+
+	var selected int
+	in := survey.Select{Message: "Select an account and role to assume:", Options: options}
+	err = testable.AskOne(&in, &selected, survey.WithStdio(os.Stdin, os.Stderr, os.Stderr))
+	if err != nil {
+		return nil, false, err
+	}
+	role := roles[selected]
+
+	if role.LocalProfile != "" {
+		clio.Infof("To assume this role again, run: assume %s", role.LocalProfile)
+		profiles, err := cfaws.LoadProfiles()
+		if err != nil {
+			return nil, false, err
+		}
+		profile, err = profiles.LoadInitialisedProfile(ctx, role.LocalProfile)
+		return profile, true, err
+	}
+
+	clio.Infof("To assume this role again, run: assume --sso --sso-start-url %s --sso-region %s --account-id %s --role-name %s", role.StartURL, role.SSORegion, role.AccountID, role.RoleName)
+	return ssoProfile(role.StartURL, role.SSORegion, role.AccountID, role.RoleName), false, nil
+}
@@ -4,12 +4,13 @@ import (
 	"fmt"
 
 	"github.com/common-fate/clio"
+	"github.com/common-fate/granted/pkg/assumeprint"
 	"github.com/urfave/cli/v2"
 )
 
 func UnsetAction(c *cli.Context) error {
 	clio.Success("Environment variables cleared")
 	// interacts with scripts to unset all the aws environment variables
-	fmt.Print("GrantedDesume")
+	fmt.Print(assumeprint.DesumeShellOutput())
 	return nil
 }
@@ -0,0 +1,214 @@
+package assumeprint
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+)
+
+// OutputVersion is the version of the JSON wire format printed by 'assume --output json'.
+// It must be incremented whenever a breaking change is made to the Output struct.
+const OutputVersion = 1
+
+const (
+	// FormatShell is the default positional format consumed by the assume shell scripts.
+	FormatShell = "shell"
+	// FormatJSON is a versioned JSON document intended for editor plugins and CI tools.
+	FormatJSON = "json"
+)
+
+// Output is the result of assuming a profile.
+type Output struct {
+	Version     int          `json:"version"`
+	Profile     string       `json:"profile,omitempty"`
+	Region      string       `json:"region,omitempty"`
+	Credentials *Credentials `json:"credentials,omitempty"`
+	SSO         *SSO         `json:"sso,omitempty"`
+	// Exec is the shell-escaped command to run with the credentials, if --exec was used.
+	Exec string `json:"exec,omitempty"`
+	// Env contains every environment variable which the shell scripts would export.
+	Env map[string]string `json:"env"`
+}
+
+type Credentials struct {
+	AccessKeyID     string     `json:"accessKeyId"`
+	SecretAccessKey string     `json:"secretAccessKey"`
+	SessionToken    string     `json:"sessionToken,omitempty"`
+	Expiration      *time.Time `json:"expiration,omitempty"`
+}
+
+type SSO struct {
+	StartURL  string `json:"startUrl,omitempty"`
+	Region    string `json:"region,omitempty"`
+	AccountID string `json:"accountId,omitempty"`
+	RoleName  string `json:"roleName,omitempty"`
+}
+
+// NewCredentials converts AWS credentials to their output format.
+func NewCredentials(creds aws.Credentials) *Credentials {
+	c := Credentials{
+		AccessKeyID:     creds.AccessKeyID,
+		SecretAccessKey: creds.SecretAccessKey,
+		SessionToken:    creds.SessionToken,
+	}
+	if creds.CanExpire {
+		expires := creds.Expires
+		c.Expiration = &expires
+	}
+	return &c
+}
+
+func (o Output) expiration() string {
+	if o.Credentials == nil || o.Credentials.Expiration == nil {
+		return ""
+	}
+	return o.Credentials.Expiration.Local().Format(time.RFC3339)
+}
+
+// ShellFields returns the 12 positional fields read by the assume shell scripts.
+// Empty values are returned as empty strings, use PrepareStringsForShellScript to pad them.
+//
+// The order of these fields must not be changed, as older shell scripts parse them by position.
+func (o Output) ShellFields() []string {
+	fields := make([]string, 12)
+	if o.Credentials != nil {
+		fields[0] = o.Credentials.AccessKeyID
+		fields[1] = o.Credentials.SecretAccessKey
+		fields[2] = o.Credentials.SessionToken
+		fields[6] = "false"
+	}
+	fields[3] = o.Profile
+	fields[4] = o.Region
+	// the exec output has always left the expiration empty, and the shell scripts don't read it
+	if o.Exec == "" {
+		fields[5] = o.expiration()
+	}
+	if o.SSO != nil {
+		fields[6] = "true"
+		fields[7] = o.SSO.StartURL
+		fields[8] = o.SSO.RoleName
+		fields[9] = o.SSO.Region
+		fields[10] = o.SSO.AccountID
+	}
+	fields[11] = o.Exec
+	return fields
+}
+
+// ManagedEnvVars are the environment variables which the assume shell scripts set when assuming a profile,
+// and clear when assuming another profile or running 'assume --unset'.
+//
+// To export a new variable, add it here and set it in EnvVars. The shell scripts apply the
+// assignments from EnvAssignments, so they don't need to be changed.
+var ManagedEnvVars = []string{
+	"AWS_ACCESS_KEY_ID",
+	"AWS_SECRET_ACCESS_KEY",
+	"AWS_SESSION_TOKEN",
+	"AWS_PROFILE",
+	"AWS_REGION",
+	"AWS_DEFAULT_REGION",
+	"AWS_SESSION_EXPIRATION",
+	"AWS_CREDENTIAL_EXPIRATION",
+	"GRANTED_SSO",
+	"GRANTED_SSO_START_URL",
+	"GRANTED_SSO_ROLE_NAME",
+	"GRANTED_SSO_REGION",
+	"GRANTED_SSO_ACCOUNT_ID",
+}
+
+// EnvAssignments returns a KEY=VALUE assignment for each of the ManagedEnvVars.
+// An empty value means that the variable should be unset.
+func (o Output) EnvAssignments() []string {
+	env := o.EnvVars()
+	var assignments []string
+	for _, key := range ManagedEnvVars {
+		assignments = append(assignments, key+"="+env[key])
+	}
+	return assignments
+}
+
+// DesumeShellOutput is printed by 'assume --unset'. It clears all of the ManagedEnvVars.
+func DesumeShellOutput() string {
+	return "GrantedDesume " + strings.Join(append(slices.Repeat([]string{"None"}, 12), Output{}.EnvAssignments()...), " ")
+}
+
+// EnvVars returns the environment variables which are exported by the assume shell scripts for this output.
+func (o Output) EnvVars() map[string]string {
+	env := map[string]string{}
+	set := func(key, value string) {
+		if value != "" {
+			env[key] = value
+		}
+	}
+	if o.Credentials != nil {
+		set("AWS_ACCESS_KEY_ID", o.Credentials.AccessKeyID)
+		set("AWS_SECRET_ACCESS_KEY", o.Credentials.SecretAccessKey)
+		set("AWS_SESSION_TOKEN", o.Credentials.SessionToken)
+		set("AWS_SESSION_EXPIRATION", o.expiration())
+		set("AWS_CREDENTIAL_EXPIRATION", o.expiration())
+	}
+	set("AWS_PROFILE", o.Profile)
+	set("AWS_REGION", o.Region)
+	set("AWS_DEFAULT_REGION", o.Region)
+	if o.SSO != nil {
+		set("GRANTED_SSO", "true")
+		set("GRANTED_SSO_START_URL", o.SSO.StartURL)
+		set("GRANTED_SSO_ROLE_NAME", o.SSO.RoleName)
+		set("GRANTED_SSO_REGION", o.SSO.Region)
+		set("GRANTED_SSO_ACCOUNT_ID", o.SSO.AccountID)
+	}
+	return env
+}
+
+// Print writes the output to w in the provided format.
+// flag is the positional marker used by the shell scripts, e.g. 'GrantedAssume' or 'GrantedExec'.
+func (o Output) Print(w io.Writer, flag string, format string) error {
+	switch format {
+	case FormatJSON:
+		o.Version = OutputVersion
+		o.Env = o.EnvVars()
+		b, err := json.Marshal(o)
+		if err != nil {
+			return err
+		}
+		// when running via the shell script, the GrantedOutput prefix causes the
+		// JSON to be printed to stdout unaltered.
+		if os.Getenv("GRANTED_ALIAS_CONFIGURED") == "true" {
+			_, err = fmt.Fprint(w, SafeOutput(string(b)))
+			return err
+		}
+		_, err = fmt.Fprintln(w, string(b))
+		return err
+	case FormatShell, "":
+		_, err := fmt.Fprintf(w, flag+" %s %s %s %s %s %s %s %s %s %s %s %s", PrepareStringsForShellScript(o.ShellFields())...)
+		if err != nil || o.Exec != "" {
+			// the exec command is the rest of the line, so nothing can follow it
+			return err
+		}
+		// the positional fields are kept for older shell scripts, newer scripts apply these assignments
+		_, err = fmt.Fprint(w, " "+strings.Join(o.EnvAssignments(), " "))
+		return err
+	default:
+		return fmt.Errorf("unknown output format %q: allowed formats are %s, %s", format, FormatShell, FormatJSON)
+	}
+}
+
+// PrepareStringsForShellScript will set empty values to "None", this is required by the shell script to identify which variables to unset
+// it is also required to ensure that the return values are correctly split, e.g if sessionToken is "" then profile name will be used to set the session token environment variable
+func PrepareStringsForShellScript(in []string) []interface{} {
+	out := []interface{}{}
+	for _, s := range in {
+		if s == "" {
+			out = append(out, "None")
+		} else {
+			out = append(out, s)
+		}
+
+	}
+	return out
+}
@@ -0,0 +1,123 @@
+package assumeprint
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOutputPrint(t *testing.T) {
+	expires := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	creds := aws.Credentials{AccessKeyID: "AKIA", SecretAccessKey: "secret", SessionToken: "token", CanExpire: true, Expires: expires}
+	t.Setenv("GRANTED_ALIAS_CONFIGURED", "")
+
+	tests := []struct {
+		name      string
+		out       Output
+		flag      string
+		wantShell string
+		wantEnv   map[string]string
+	}{
+		{
+			name: "credential process profile",
+			out:  Output{Profile: "dev", Region: "us-east-1"},
+			flag: "GrantedAssume",
+			wantShell: "GrantedAssume None None None dev us-east-1 None None None None None None None" +
+				" AWS_ACCESS_KEY_ID= AWS_SECRET_ACCESS_KEY= AWS_SESSION_TOKEN= AWS_PROFILE=dev AWS_REGION=us-east-1 AWS_DEFAULT_REGION=us-east-1" +
+				" AWS_SESSION_EXPIRATION= AWS_CREDENTIAL_EXPIRATION= GRANTED_SSO= GRANTED_SSO_START_URL= GRANTED_SSO_ROLE_NAME= GRANTED_SSO_REGION= GRANTED_SSO_ACCOUNT_ID=",
+			wantEnv: map[string]string{"AWS_PROFILE": "dev", "AWS_REGION": "us-east-1", "AWS_DEFAULT_REGION": "us-east-1"},
+		},
+		{
+			name: "exec",
+			out:  Output{Credentials: NewCredentials(creds), Region: "us-east-1", Exec: "'aws' 's3' 'ls'"},
+			flag: "GrantedExec",
+			// the expiration is left empty and nothing follows the command, as in the original exec output
+			wantShell: "GrantedExec AKIA secret token None us-east-1 None false None None None None 'aws' 's3' 'ls'",
+			wantEnv: map[string]string{
+				"AWS_ACCESS_KEY_ID":         "AKIA",
+				"AWS_SECRET_ACCESS_KEY":     "secret",
+				"AWS_SESSION_TOKEN":         "token",
+				"AWS_SESSION_EXPIRATION":    expires.Local().Format(time.RFC3339),
+				"AWS_CREDENTIAL_EXPIRATION": expires.Local().Format(time.RFC3339),
+				"AWS_REGION":                "us-east-1",
+				"AWS_DEFAULT_REGION":        "us-east-1",
+			},
+		},
+		{
+			name: "sso",
+			out:  Output{Credentials: NewCredentials(creds), Region: "us-east-1", SSO: &SSO{StartURL: "https://example.awsapps.com/start", Region: "us-west-2", AccountID: "123456789012", RoleName: "Admin"}},
+			flag: "GrantedAssume",
+			wantShell: "GrantedAssume AKIA secret token None us-east-1 " + expires.Local().Format(time.RFC3339) + " true https://example.awsapps.com/start Admin us-west-2 123456789012 None" +
+				" AWS_ACCESS_KEY_ID=AKIA AWS_SECRET_ACCESS_KEY=secret AWS_SESSION_TOKEN=token AWS_PROFILE= AWS_REGION=us-east-1 AWS_DEFAULT_REGION=us-east-1" +
+				" AWS_SESSION_EXPIRATION=" + expires.Local().Format(time.RFC3339) + " AWS_CREDENTIAL_EXPIRATION=" + expires.Local().Format(time.RFC3339) +
+				" GRANTED_SSO=true GRANTED_SSO_START_URL=https://example.awsapps.com/start GRANTED_SSO_ROLE_NAME=Admin GRANTED_SSO_REGION=us-west-2 GRANTED_SSO_ACCOUNT_ID=123456789012",
+			wantEnv: map[string]string{
+				"AWS_ACCESS_KEY_ID":         "AKIA",
+				"AWS_SECRET_ACCESS_KEY":     "secret",
+				"AWS_SESSION_TOKEN":         "token",
+				"AWS_SESSION_EXPIRATION":    expires.Local().Format(time.RFC3339),
+				"AWS_CREDENTIAL_EXPIRATION": expires.Local().Format(time.RFC3339),
+				"AWS_REGION":                "us-east-1",
+				"AWS_DEFAULT_REGION":        "us-east-1",
+				"GRANTED_SSO":               "true",
+				"GRANTED_SSO_START_URL":     "https://example.awsapps.com/start",
+				"GRANTED_SSO_ROLE_NAME":     "Admin",
+				"GRANTED_SSO_REGION":        "us-west-2",
+				"GRANTED_SSO_ACCOUNT_ID":    "123456789012",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.wantShell != "" {
+				var shell bytes.Buffer
+				err := tt.out.Print(&shell, tt.flag, FormatShell)
+				require.NoError(t, err)
+				assert.Equal(t, tt.wantShell, shell.String())
+			}
+
+			var buf bytes.Buffer
+			err := tt.out.Print(&buf, tt.flag, FormatJSON)
+			require.NoError(t, err)
+
+			var got Output
+			err = json.Unmarshal(buf.Bytes(), &got)
+			require.NoError(t, err)
+			assert.Equal(t, OutputVersion, got.Version)
+			assert.Equal(t, tt.wantEnv, got.Env)
+		})
+	}
+}
+
+func TestDesumeShellOutput(t *testing.T) {
+	out := DesumeShellOutput()
+	assert.True(t, strings.HasPrefix(out, "GrantedDesume None None None None None None None None None None None None AWS_ACCESS_KEY_ID= "), out)
+	assert.Len(t, strings.Fields(out), 1+12+len(ManagedEnvVars))
+}
+
+func TestBundlePrint(t *testing.T) {
+	t.Setenv("GRANTED_ALIAS_CONFIGURED", "")
+
+	b := Bundle{Profiles: map[string]Output{
+		"shared-services": {Profile: "shared-services", Region: "us-east-1", Credentials: &Credentials{AccessKeyID: "AKIA1", SecretAccessKey: "secret1"}},
+		"workload":        {Profile: "workload", Region: "us-west-2", Credentials: &Credentials{AccessKeyID: "AKIA2", SecretAccessKey: "secret2"}},
+	}}
+
+	var buf bytes.Buffer
+	err := b.Print(&buf)
+	require.NoError(t, err)
+
+	var got Bundle
+	err = json.Unmarshal(buf.Bytes(), &got)
+	require.NoError(t, err)
+	assert.Equal(t, OutputVersion, got.Version)
+	assert.Len(t, got.Profiles, 2)
+	assert.Equal(t, "AKIA2", got.Profiles["workload"].Env["AWS_ACCESS_KEY_ID"])
+	assert.Equal(t, "us-west-2", got.Profiles["workload"].Env["AWS_REGION"])
+}
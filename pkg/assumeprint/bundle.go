@@ -0,0 +1,52 @@
+package assumeprint
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+)
+
+// Bundle contains the credentials for several profiles assumed at once, keyed by profile name.
+type Bundle struct {
+	Version  int               `json:"version"`
+	Profiles map[string]Output `json:"profiles"`
+}
+
+// prepare sets the version and environment variables of the bundle and each profile.
+func (b Bundle) prepare() Bundle {
+	b.Version = OutputVersion
+	profiles := make(map[string]Output, len(b.Profiles))
+	for name, o := range b.Profiles {
+		o.Version = OutputVersion
+		o.Env = o.EnvVars()
+		profiles[name] = o
+	}
+	b.Profiles = profiles
+	return b
+}
+
+// Print writes the bundle as JSON, for use with 'assume --output json'.
+func (b Bundle) Print(w io.Writer) error {
+	out, err := json.Marshal(b.prepare())
+	if err != nil {
+		return err
+	}
+	// when running via the shell script, the GrantedOutput prefix causes the
+	// JSON to be printed to stdout unaltered.
+	if os.Getenv("GRANTED_ALIAS_CONFIGURED") == "true" {
+		_, err = fmt.Fprint(w, SafeOutput(string(out)))
+		return err
+	}
+	_, err = fmt.Fprintln(w, string(out))
+	return err
+}
+
+// WriteFile writes the bundle as JSON to a file which is only readable by the current user.
+func (b Bundle) WriteFile(path string) error {
+	out, err := json.MarshalIndent(b.prepare(), "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, out, 0600)
+}
@@ -5,13 +5,27 @@ import (
 	"time"
 
 	"github.com/common-fate/clio"
+	grantedConfig "github.com/common-fate/granted/pkg/config"
 	"github.com/common-fate/granted/pkg/granted/registry"
 )
 
-// interactive when false will fail the profile registry sync
-// in case where user specific values that are defined in granted.yml's `templateValues` are not available.
-// this is done so that users are aware of required keys when granted's credential-process is used through the AWS CLI.
-func Run(ctx context.Context, interactive bool) {
+type Opts struct {
+	// Interactive when false will fail the profile registry sync
+	// in case where user specific values that are defined in granted.yml's `templateValues` are not available.
+	// this is done so that users are aware of required keys when granted's credential-process is used through the AWS CLI.
+	Interactive bool
+	// Force syncs every registry in the foreground, even if it has been synced within its sync interval.
+	Force bool
+}
+
+// Run syncs any profile registries which haven't been synced within their sync interval.
+func Run(ctx context.Context, opts Opts) {
+	run(ctx, opts, true)
+}
+
+// run syncs the due profile registries. If allowBackground is true and background sync is
+// enabled, the sync is started in a background process rather than running in this one.
+func run(ctx context.Context, opts Opts, allowBackground bool) {
 	if registry.IsOutdatedConfig() {
 		clio.Warn("Outdated Profile Registry Configuration. Use `granted registry migrate` to update your configuration.")
 
@@ -20,7 +34,7 @@ func Run(ctx context.Context, interactive bool) {
 		return
 	}
 
-	registries, err := registry.GetProfileRegistries(interactive)
+	registries, err := registry.GetProfileRegistries(opts.Interactive)
 	if err != nil {
 		clio.Debugf("unable to load granted config file with err %s", err.Error())
 		return
@@ -33,15 +47,33 @@ func Run(ctx context.Context, interactive bool) {
 		return
 	}
 
-	// load and check if sync has been run for the day. If true then skip.
-	rc, ok := loadRegistryConfig()
-	clio.Debug("checking if autosync has been run for the day")
-	if ok && time.Now().Weekday() == rc.LastCheckForSync {
-		clio.Debug("skipping profile registry sync until tomorrow=%s", rc.Path())
+	var configs []grantedConfig.Registry
+	for _, r := range registries {
+		configs = append(configs, r.Config)
+	}
+
+	rc, _ := registry.LoadRegistrySyncConfig()
+	due := registry.DueRegistries(configs, rc, time.Now(), opts.Force)
+	if len(due) == 0 {
+		clio.Debugf("skipping profile registry sync as all registries have been synced within their sync interval, state=%s", rc.Path())
+		return
+	}
+
+	gConf, err := grantedConfig.Load()
+	if err != nil {
+		clio.Debugf("unable to load granted config file with err %s", err.Error())
+		return
+	}
+
+	if allowBackground && gConf.ProfileRegistry.BackgroundSync && !opts.Force {
+		err = startBackgroundSync(rc)
+		if err != nil {
+			clio.Warnf("Failed to start background Profile Registry sync: %s", err.Error())
+		}
 		return
 	}
 
-	err = runSync(ctx, rc, interactive)
+	err = runSync(ctx, due, opts.Interactive)
 	if err != nil {
 		clio.Warnf("Failed to sync Profile Registries: %s", err.Error())
 	}
@@ -0,0 +1,96 @@
+package autosync
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/common-fate/clio"
+	grantedConfig "github.com/common-fate/granted/pkg/config"
+	"github.com/common-fate/granted/pkg/granted/registry"
+	"github.com/urfave/cli/v2"
+)
+
+// backgroundSyncCommandName is the name of the hidden command which runs a background sync.
+const backgroundSyncCommandName = "background-registry-sync"
+
+// backgroundSyncTimeout is how long to wait for a background sync to finish before another one can be started.
+const backgroundSyncTimeout = 5 * time.Minute
+
+// BackgroundSyncCommand is run in a detached process by startBackgroundSync.
+var BackgroundSyncCommand = cli.Command{
+	Name:   backgroundSyncCommandName,
+	Usage:  "Sync Profile Registries which are due to be synced. Used internally when background sync is enabled",
+	Hidden: true,
+	Action: func(c *cli.Context) error {
+		run(c.Context, Opts{}, false)
+
+		rc, ok := registry.LoadRegistrySyncConfig()
+		if !ok {
+			return nil
+		}
+		rc.BackgroundSyncStartedAt = time.Time{}
+		return rc.Save()
+	},
+}
+
+// startBackgroundSync starts a detached 'granted background-registry-sync' process, unless one has recently been started.
+// The output of the process is written to 'registry-sync.log' in the Granted state folder.
+func startBackgroundSync(rc registry.RegistrySyncConfig) error {
+	if time.Since(rc.BackgroundSyncStartedAt) < backgroundSyncTimeout {
+		clio.Debugf("skipping background profile registry sync as one was started at %s", rc.BackgroundSyncStartedAt)
+		return nil
+	}
+
+	exe, err := os.Executable()
+	if err != nil {
+		return err
+	}
+
+	stateDir, err := grantedConfig.GrantedStateFolder()
+	if err != nil {
+		return err
+	}
+	err = os.MkdirAll(stateDir, 0700)
+	if err != nil {
+		return err
+	}
+	logFile, err := os.OpenFile(filepath.Join(stateDir, "registry-sync.log"), os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	defer logFile.Close()
+
+	// the binary swaps between the 'granted' and 'assume' CLIs based on argv[0]
+	var env []string
+	for _, e := range os.Environ() {
+		if !strings.HasPrefix(e, "FORCE_ASSUME_CLI=") {
+			env = append(env, e)
+		}
+	}
+
+	cmd := &exec.Cmd{
+		Path:        exe,
+		Args:        []string{"granted", backgroundSyncCommandName},
+		Env:         env,
+		Stdout:      logFile,
+		Stderr:      logFile,
+		SysProcAttr: detachedProcAttr(),
+	}
+
+	rc.BackgroundSyncStartedAt = time.Now()
+	err = rc.Save()
+	if err != nil {
+		return err
+	}
+
+	err = cmd.Start()
+	if err != nil {
+		return err
+	}
+
+	clio.Debugf("started background profile registry sync with pid %d", cmd.Process.Pid)
+	return cmd.Process.Release()
+}
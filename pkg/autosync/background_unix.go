@@ -0,0 +1,10 @@
+//go:build !windows
+
+package autosync
+
+import "syscall"
+
+// detachedProcAttr starts the process in a new session, so that it isn't stopped when the terminal is closed.
+func detachedProcAttr() *syscall.SysProcAttr {
+	return &syscall.SysProcAttr{Setsid: true}
+}
@@ -0,0 +1,13 @@
+//go:build windows
+
+package autosync
+
+import "syscall"
+
+// detachedProcess is the DETACHED_PROCESS process creation flag.
+const detachedProcess = 0x00000008
+
+// detachedProcAttr starts the process without a console, so that it isn't stopped when the terminal is closed.
+func detachedProcAttr() *syscall.SysProcAttr {
+	return &syscall.SysProcAttr{CreationFlags: detachedProcess | syscall.CREATE_NEW_PROCESS_GROUP}
+}
@@ -2,24 +2,18 @@ package autosync
 
 import (
 	"context"
-	"fmt"
-	"time"
 
 	"github.com/common-fate/clio"
 	"github.com/common-fate/granted/pkg/granted/registry"
 )
 
-func runSync(ctx context.Context, rc RegistrySyncConfig, interactive bool) error {
+func runSync(ctx context.Context, names []string, interactive bool) error {
 	clio.Info("Syncing Profile Registries")
-	err := registry.SyncProfileRegistries(ctx, interactive)
+	// the last sync time of each registry is recorded when the synced profiles are saved
+	err := registry.SyncNamedProfileRegistries(ctx, interactive, names)
 	if err != nil {
 		return err
 	}
-	rc.LastCheckForSync = time.Now().Weekday()
-	err = rc.Save()
-	if err != nil {
-		return fmt.Errorf("saving registry sync config: %w", err)
-	}
 	clio.Success("Completed syncing Profile Registries")
 	return nil
 }
@@ -14,7 +14,6 @@ import (
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/config"
 	"github.com/common-fate/clio"
-	"github.com/common-fate/granted/pkg/securestorage"
 	"github.com/hashicorp/go-version"
 	"gopkg.in/ini.v1"
 )
@@ -72,8 +71,7 @@ func (cc *CredentialCapture) Creds() (aws.Credentials, error) {
 
 func (gimme *AwsGimmeAwsCredsAssumer) AssumeTerminal(ctx context.Context, c *Profile, configOpts ConfigOpts) (aws.Credentials, error) {
 	// try cache
-	sessionCredStorage := securestorage.NewSecureSessionCredentialStorage()
-	creds, err := sessionCredStorage.GetCredentials(c.AWSConfig.Profile)
+	creds, err := c.CachedSessionCredentials(configOpts)
 
 	if err != nil {
 		clio.Debugw("error loading cached credentials", "error", err)
@@ -157,7 +155,7 @@ func (gimme *AwsGimmeAwsCredsAssumer) AssumeTerminal(ctx context.Context, c *Pro
 	}
 
 	// store cached creds
-	if err := sessionCredStorage.StoreCredentials(c.AWSConfig.Profile, awscreds); err != nil {
+	if err := c.CacheSessionCredentials(configOpts, awscreds); err != nil {
 		clio.Warnf("Error caching credentials, MFA token will be requested")
 	}
 
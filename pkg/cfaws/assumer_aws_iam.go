@@ -26,9 +26,7 @@ type AwsIamAssumer struct {
 func (aia *AwsIamAssumer) AssumeTerminal(ctx context.Context, c *Profile, configOpts ConfigOpts) (aws.Credentials, error) {
 	// check if the valid credentials are available in session credential store
 
-	sessionCredStorage := securestorage.NewSecureSessionCredentialStorage()
-
-	cachedCreds, err := sessionCredStorage.GetCredentials(c.AWSConfig.Profile)
+	cachedCreds, err := c.CachedSessionCredentials(configOpts)
 	if err != nil {
 		clio.Debugw("error loading cached credentials", "error", err)
 	} else if cachedCreds != nil && !cachedCreds.Expired() {
@@ -69,7 +67,7 @@ func (aia *AwsIamAssumer) AssumeTerminal(ctx context.Context, c *Profile, config
 			return aws.Credentials{}, err
 		}
 
-		if err := sessionCredStorage.StoreCredentials(c.AWSConfig.Profile, creds); err != nil {
+		if err := c.CacheSessionCredentials(configOpts, creds); err != nil {
 			clio.Warnf("Error caching credentials, MFA token will be requested before current token is expired")
 		}
 
@@ -173,7 +171,7 @@ func (aia *AwsIamAssumer) AssumeTerminal(ctx context.Context, c *Profile, config
 		}
 	}
 
-	if err := sessionCredStorage.StoreCredentials(c.AWSConfig.Profile, credentials); err != nil {
+	if err := c.CacheSessionCredentials(configOpts, credentials); err != nil {
 		clio.Warnf("Error caching credentials, MFA token will be requested before current token is expired")
 	}
 
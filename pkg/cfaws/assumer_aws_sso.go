@@ -2,6 +2,8 @@ package cfaws
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
 	"net/http"
 	"strings"
@@ -16,6 +18,7 @@ import (
 	"github.com/aws/aws-sdk-go-v2/service/sts"
 	"github.com/aws/smithy-go"
 	"github.com/common-fate/clio"
+	"github.com/common-fate/granted/pkg/filelock"
 	"github.com/common-fate/granted/pkg/idclogin"
 	"github.com/common-fate/granted/pkg/securestorage"
 	"github.com/hako/durafmt"
@@ -146,6 +149,15 @@ func (c *Profile) SSOLoginWithToken(ctx context.Context, cfg *aws.Config, access
 	return credProvider.Credentials, nil
 }
 
+// ssoLoginLockTimeout is how long to wait for another Granted process which is logging in to the same start URL.
+const ssoLoginLockTimeout = 5 * time.Minute
+
+// ssoLoginLockName returns the name of the lock held while logging in to get the SSO token with the key.
+func ssoLoginLockName(ssoTokenKey string) string {
+	hash := sha256.Sum256([]byte(ssoTokenKey))
+	return "sso-login-" + hex.EncodeToString(hash[:8])
+}
+
 func (c *Profile) SSOLogin(ctx context.Context, configOpts ConfigOpts) (aws.Credentials, error) {
 	rootProfile := c
 	if len(c.Parents) > 0 {
@@ -193,10 +205,22 @@ func (c *Profile) SSOLogin(ctx context.Context, configOpts ConfigOpts) (aws.Cred
 		return aws.Credentials{}, fmt.Errorf("error when retrieving credentials from custom process. please login using '%s'", cmd)
 	}
 
+	if cachedToken == nil && plainTextToken == nil && !configOpts.DisableCache {
+		// credential processes for several profiles using the same start URL may need to log in at once.
+		// Only one of them logs in, and the others use the token it stores once they take the lock.
+		lock, err := filelock.Lock(ssoLoginLockName(ssoTokenKey), ssoLoginLockTimeout)
+		if err != nil {
+			clio.Debugw("logging in without holding the SSO login lock", "error", err)
+		} else {
+			defer func() { _ = lock.Unlock() }()
+			cachedToken = secureSSOTokenStorage.GetValidSSOToken(ctx, ssoTokenKey)
+		}
+	}
+
 	if cachedToken == nil && plainTextToken == nil {
 		newCfg := aws.NewConfig()
 		newCfg.Region = rootProfile.SSORegion()
-		newSSOToken, err := idclogin.Login(ctx, *newCfg, rootProfile.SSOStartURL(), rootProfile.SSOScopes())
+		newSSOToken, err := idclogin.LoginWithFlow(ctx, *newCfg, rootProfile.SSOStartURL(), rootProfile.SSOScopes(), idclogin.Flow(rootProfile.SSOLoginFlow()))
 		if err != nil {
 			return aws.Credentials{}, err
 		}
@@ -0,0 +1,188 @@
+package cfaws
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/common-fate/clio"
+	gconfig "github.com/common-fate/granted/pkg/config"
+)
+
+// MaxBackups is the number of backups kept for each file.
+const MaxBackups = 10
+
+// backupTimeFormat is the format of backup file names, which sorts in chronological order.
+const backupTimeFormat = "20060102T150405.000000000Z"
+
+// Backup is a copy of an AWS config or credentials file taken before Granted wrote to it.
+type Backup struct {
+	Path      string
+	CreatedAt time.Time
+}
+
+// backupFolder returns the folder that backups of the file are kept in. Backups of the credentials
+// file contain credentials, so the folder is only accessible to the current user.
+func backupFolder(filename string) (string, error) {
+	stateFolder, err := gconfig.GrantedStateFolder()
+	if err != nil {
+		return "", err
+	}
+	abs, err := resolvePath(filename)
+	if err != nil {
+		return "", err
+	}
+	hash := sha256.Sum256([]byte(abs))
+	return filepath.Join(stateFolder, "backups", filepath.Base(abs)+"-"+hex.EncodeToString(hash[:8])), nil
+}
+
+// ListBackups returns the backups of the file, newest first.
+func ListBackups(filename string) ([]Backup, error) {
+	dir, err := backupFolder(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var backups []Backup
+	for _, e := range entries {
+		t, err := time.Parse(backupTimeFormat, e.Name())
+		if err != nil {
+			continue
+		}
+		backups = append(backups, Backup{Path: filepath.Join(dir, e.Name()), CreatedAt: t})
+	}
+
+	sort.Slice(backups, func(i, j int) bool {
+		return backups[i].CreatedAt.After(backups[j].CreatedAt)
+	})
+	return backups, nil
+}
+
+// BackupsEnabled returns whether the file is backed up before Granted writes to it.
+// The AWS credentials file is only backed up if BackupAWSCredentialsFile is set in the Granted config,
+// as the backups would otherwise keep plaintext credentials after they are moved to secure storage or rotated.
+func BackupsEnabled(filename string) bool {
+	if !sameFile(filename, GetAWSCredentialsPath()) {
+		return true
+	}
+	cfg, err := gconfig.Load()
+	if err != nil {
+		clio.Debugw("not backing up the AWS credentials file as the Granted config couldn't be loaded", "error", err)
+		return false
+	}
+	return cfg.BackupAWSCredentialsFile
+}
+
+func sameFile(a, b string) bool {
+	absA, err := resolvePath(a)
+	if err != nil {
+		return false
+	}
+	absB, err := resolvePath(b)
+	if err != nil {
+		return false
+	}
+	return absA == absB
+}
+
+// RemoveBackups deletes every backup of the file.
+func RemoveBackups(filename string) error {
+	dir, err := backupFolder(filename)
+	if err != nil {
+		return err
+	}
+	return os.RemoveAll(dir)
+}
+
+// backupFile copies the file into its backup folder, unless backups are disabled for the file, or it is
+// empty or unchanged since the last backup. Only the newest MaxBackups backups are kept.
+func backupFile(filename string) error {
+	if !BackupsEnabled(filename) {
+		return nil
+	}
+
+	data, err := os.ReadFile(filename)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if len(data) == 0 {
+		return nil
+	}
+
+	backups, err := ListBackups(filename)
+	if err != nil {
+		return err
+	}
+	if len(backups) > 0 {
+		latest, err := os.ReadFile(backups[0].Path)
+		if err == nil && bytes.Equal(latest, data) {
+			return nil
+		}
+	}
+
+	dir, err := backupFolder(filename)
+	if err != nil {
+		return err
+	}
+	err = os.MkdirAll(dir, 0700)
+	if err != nil {
+		return err
+	}
+
+	err = os.WriteFile(filepath.Join(dir, time.Now().UTC().Format(backupTimeFormat)), data, 0600)
+	if err != nil {
+		return fmt.Errorf("backing up %s: %w", filename, err)
+	}
+
+	// the new backup isn't in the list, so keep one fewer of the existing backups
+	if len(backups) >= MaxBackups {
+		for _, b := range backups[MaxBackups-1:] {
+			_ = os.Remove(b.Path)
+		}
+	}
+	return nil
+}
+
+// RestoreBackup replaces the file with the backup. The current file is backed up first if backups
+// are enabled for it, so that the restore can be undone.
+func RestoreBackup(filename string, b Backup) error {
+	lock, err := LockPath(filename)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = lock.Unlock() }()
+
+	data, err := os.ReadFile(b.Path)
+	if err != nil {
+		return err
+	}
+
+	err = backupFile(filename)
+	if err != nil {
+		return err
+	}
+
+	return writeFileAtomic(filename, func(w io.Writer) error {
+		_, err := w.Write(data)
+		return err
+	})
+}
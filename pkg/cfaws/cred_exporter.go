@@ -16,30 +16,6 @@ func ExportCredsToProfile(profileName string, creds aws.Credentials) error {
 	// fetch the parsed cred file
 	credPath := GetAWSCredentialsPath()
 
-	// create it if it doesn't exist
-	if _, err := os.Stat(credPath); os.IsNotExist(err) {
-
-		f, err := os.Create(credPath)
-		if err != nil {
-			return err
-		}
-		err = f.Close()
-		if err != nil {
-			return err
-		}
-		clio.Infof("An AWS credentials file was not found at %s so it has been created", credPath)
-
-	}
-
-	credentialsFile, err := ini.LoadSources(ini.LoadOptions{
-		AllowNonUniqueSections:  false,
-		SkipUnrecognizableLines: false,
-		AllowNestedValues:       true,
-	}, credPath)
-	if err != nil {
-		return err
-	}
-
 	cfg, err := gconfig.Load()
 	if err != nil {
 		return err
@@ -49,25 +25,33 @@ func ExportCredsToProfile(profileName string, creds aws.Credentials) error {
 		profileName = profileName + "-" + cfg.ExportCredentialSuffix
 	}
 
-	credentialsFile.DeleteSection(profileName)
-	section, err := credentialsFile.NewSection(profileName)
-	if err != nil {
-		return err
+	// the file is created if it doesn't exist
+	if _, err := os.Stat(credPath); os.IsNotExist(err) {
+		clio.Infof("An AWS credentials file was not found at %s so it has been created", credPath)
 	}
-	// put the creds into options
-	err = section.ReflectFrom(&struct {
-		AWSAccessKeyID     string `ini:"aws_access_key_id"`
-		AWSSecretAccessKey string `ini:"aws_secret_access_key"`
-		AWSSessionToken    string `ini:"aws_session_token,omitempty"`
-	}{
-		AWSAccessKeyID:     creds.AccessKeyID,
-		AWSSecretAccessKey: creds.SecretAccessKey,
-		AWSSessionToken:    creds.SessionToken,
+
+	// credential-process may export credentials from many processes at once, so the file is locked while it is updated
+	return UpdateFile(credPath, ini.LoadOptions{
+		AllowNonUniqueSections:  false,
+		SkipUnrecognizableLines: false,
+		AllowNestedValues:       true,
+	}, func(credentialsFile *ini.File) error {
+		credentialsFile.DeleteSection(profileName)
+		section, err := credentialsFile.NewSection(profileName)
+		if err != nil {
+			return err
+		}
+		// put the creds into options
+		return section.ReflectFrom(&struct {
+			AWSAccessKeyID     string `ini:"aws_access_key_id"`
+			AWSSecretAccessKey string `ini:"aws_secret_access_key"`
+			AWSSessionToken    string `ini:"aws_session_token,omitempty"`
+		}{
+			AWSAccessKeyID:     creds.AccessKeyID,
+			AWSSecretAccessKey: creds.SecretAccessKey,
+			AWSSessionToken:    creds.SessionToken,
+		})
 	})
-	if err != nil {
-		return err
-	}
-	return credentialsFile.SaveTo(credPath)
 }
 
 // ExportAccessTokenToCache will export access tokens to ~/.aws/sso/cache
@@ -13,7 +13,6 @@ import (
 	"github.com/aws/aws-sdk-go-v2/service/sts/types"
 	"github.com/common-fate/clio"
 	"github.com/common-fate/granted/pkg/config"
-	"github.com/common-fate/granted/pkg/securestorage"
 	"github.com/common-fate/granted/pkg/testable"
 )
 
@@ -48,14 +47,28 @@ func GetAWSCredentials(ctx context.Context) (*aws.Credentials, error) {
 		return nil, err
 	}
 
-	secureSessionCredentialStorage := securestorage.NewSecureSessionCredentialStorage()
+	profiles, err := LoadProfiles()
+	if err != nil {
+		return nil, err
+	}
+
+	profile, err := profiles.LoadInitialisedProfile(ctx, profileName)
+	if err != nil {
+		return nil, err
+	}
+
+	duration := time.Hour
+	if profile.AWSConfig.RoleDurationSeconds != nil {
+		duration = *profile.AWSConfig.RoleDurationSeconds
+	}
+	configOpts := ConfigOpts{Duration: duration, UsingCredentialProcess: true, CredentialProcessAutoLogin: true}
 
 	// check if profile is set in the environment
 	useCache := !cfg.DisableCredentialProcessCache
 
 	if useCache {
 		// try and look up session credentials from the secure storage cache.
-		cachedCreds, err := secureSessionCredentialStorage.GetCredentials(profileName)
+		cachedCreds, err := profile.CachedSessionCredentials(configOpts)
 		if err != nil {
 			return nil, err
 		}
@@ -67,28 +80,13 @@ func GetAWSCredentials(ctx context.Context) (*aws.Credentials, error) {
 		clio.Debugw("refreshing credentials", "reason", "credential process cache is disabled via config")
 	}
 
-	profiles, err := LoadProfiles()
-	if err != nil {
-		return nil, err
-	}
-
-	profile, err := profiles.LoadInitialisedProfile(ctx, profileName)
-	if err != nil {
-		return nil, err
-	}
-
-	duration := time.Hour
-	if profile.AWSConfig.RoleDurationSeconds != nil {
-		duration = *profile.AWSConfig.RoleDurationSeconds
-	}
-
-	credentials, err := profile.AssumeTerminal(ctx, ConfigOpts{Duration: duration, UsingCredentialProcess: true, CredentialProcessAutoLogin: true})
+	credentials, err := profile.AssumeTerminal(ctx, configOpts)
 	if err != nil {
 		return nil, err
 	}
 	if !cfg.DisableCredentialProcessCache {
 		clio.Debugw("storing refreshed credentials in credential process cache", "expires", credentials.Expires.String(), "canExpire", credentials.CanExpire, "timeNow", time.Now().String())
-		if err := secureSessionCredentialStorage.StoreCredentials(profileName, credentials); err != nil {
+		if err := profile.CacheSessionCredentials(configOpts, credentials); err != nil {
 			return nil, err
 		}
 	}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 
 	"os"
+	"strings"
 
 	"github.com/AlecAivazis/survey/v2"
 	"github.com/aws/aws-sdk-go-v2/aws"
@@ -15,6 +16,45 @@ import (
 // WriteCredentialsToDotenv will check if a .env file exists and prompt to create one if it does not.
 // After the file exists, it will be opened, credentaisl added and then written to disc
 func WriteCredentialsToDotenv(region string, creds aws.Credentials) error {
+	return writeDotenv([]NamedCredentials{{Region: region, Credentials: creds}})
+}
+
+// NamedCredentials are credentials for a profile which are exported alongside other profiles.
+type NamedCredentials struct {
+	Name        string
+	Region      string
+	Credentials aws.Credentials
+}
+
+// DotenvPrefix returns the prefix used for a profile's variables when exporting
+// several profiles to a .env file, e.g. 'SHARED_SERVICES_' for 'shared-services'.
+func DotenvPrefix(profileName string) string {
+	var b strings.Builder
+	for _, r := range strings.ToUpper(profileName) {
+		if (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') {
+			b.WriteRune(r)
+		} else {
+			b.WriteRune('_')
+		}
+	}
+	return b.String() + "_"
+}
+
+// WriteNamedCredentialsToDotenv writes credentials for several profiles to the .env file,
+// prefixing the variables for each profile with DotenvPrefix, e.g. 'SHARED_SERVICES_AWS_ACCESS_KEY_ID'.
+func WriteNamedCredentialsToDotenv(creds []NamedCredentials) error {
+	seen := map[string]string{}
+	for _, c := range creds {
+		prefix := DotenvPrefix(c.Name)
+		if other, ok := seen[prefix]; ok {
+			return fmt.Errorf("profiles %s and %s would be exported to the same .env variables with the prefix %s", other, c.Name, prefix)
+		}
+		seen[prefix] = c.Name
+	}
+	return writeDotenv(creds)
+}
+
+func writeDotenv(creds []NamedCredentials) error {
 	withStdio := survey.WithStdio(os.Stdin, os.Stderr, os.Stderr)
 	if _, err := os.Stat("./.env"); os.IsNotExist(err) {
 		ans := false
@@ -42,10 +82,16 @@ func WriteCredentialsToDotenv(region string, creds aws.Credentials) error {
 		return err
 	}
 
-	myEnv["AWS_ACCESS_KEY_ID"] = creds.AccessKeyID
-	myEnv["AWS_SECRET_ACCESS_KEY"] = creds.SecretAccessKey
-	myEnv["AWS_SESSION_TOKEN"] = creds.SessionToken
-	myEnv["AWS_REGION"] = region
+	for _, c := range creds {
+		var prefix string
+		if c.Name != "" {
+			prefix = DotenvPrefix(c.Name)
+		}
+		myEnv[prefix+"AWS_ACCESS_KEY_ID"] = c.Credentials.AccessKeyID
+		myEnv[prefix+"AWS_SECRET_ACCESS_KEY"] = c.Credentials.SecretAccessKey
+		myEnv[prefix+"AWS_SESSION_TOKEN"] = c.Credentials.SessionToken
+		myEnv[prefix+"AWS_REGION"] = c.Region
+	}
 
 	return godotenv.Write(myEnv, "./.env")
 }
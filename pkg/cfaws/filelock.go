@@ -0,0 +1,26 @@
+package cfaws
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"path/filepath"
+	"time"
+
+	"github.com/common-fate/granted/pkg/filelock"
+)
+
+// LockTimeout is how long LockPath waits for another Granted process to release the lock on a file.
+var LockTimeout = 30 * time.Second
+
+// LockPath takes a lock for the file at the path. The lock is taken on a separate
+// file, as files written with SaveConfigAtomic are replaced rather than modified.
+// Symlinks are resolved, so that every path to the same file takes the same lock.
+func LockPath(filename string) (*filelock.FileLock, error) {
+	abs, err := resolvePath(filename)
+	if err != nil {
+		return nil, err
+	}
+	hash := sha256.Sum256([]byte(abs))
+	return filelock.Lock(filepath.Base(abs)+"-"+hex.EncodeToString(hash[:8]), LockTimeout)
+}
+
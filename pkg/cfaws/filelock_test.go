@@ -0,0 +1,39 @@
+package cfaws
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/common-fate/granted/pkg/filelock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLockPathSymlink(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	dir := t.TempDir()
+	target := filepath.Join(dir, "dotfiles", "config")
+	require.NoError(t, os.MkdirAll(filepath.Dir(target), 0700))
+	require.NoError(t, os.WriteFile(target, nil, 0600))
+	link := filepath.Join(dir, "config")
+	require.NoError(t, os.Symlink(target, link))
+
+	lock, err := LockPath(target)
+	require.NoError(t, err)
+	defer func() { _ = lock.Unlock() }()
+
+	// writers using the symlink and the file it points to take the same lock
+	timeout := LockTimeout
+	LockTimeout = 100 * time.Millisecond
+	t.Cleanup(func() { LockTimeout = timeout })
+	_, err = LockPath(link)
+	assert.ErrorIs(t, err, filelock.ErrLocked)
+
+	linkBackups, err := backupFolder(link)
+	require.NoError(t, err)
+	targetBackups, err := backupFolder(target)
+	require.NoError(t, err)
+	assert.Equal(t, targetBackups, linkBackups)
+}
@@ -0,0 +1,152 @@
+package cfaws
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/common-fate/granted/pkg/filelock"
+	"gopkg.in/ini.v1"
+)
+
+// LockedFile is an AWS config or credentials file which is locked against writes from other
+// Granted processes, such as concurrent 'granted credential-process' calls exporting credentials.
+type LockedFile struct {
+	Filename string
+	File     *ini.File
+	// SkipBackup prevents the file being backed up when it is saved, even if backups are enabled for it.
+	// It should be set when moving credentials out of the file, so that they aren't kept in plaintext in a backup.
+	SkipBackup bool
+	lock       *filelock.FileLock
+}
+
+// OpenLocked locks the file, then loads it. If the file doesn't exist, File is empty and the
+// file is created when it is saved. Close must be called to release the lock.
+//
+// The file should be read and written through the LockedFile, so that changes made by another
+// process between reading and writing the file aren't overwritten.
+func OpenLocked(filename string, opts ini.LoadOptions) (*LockedFile, error) {
+	lock, err := LockPath(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := ini.LoadSources(opts, filename)
+	if errors.Is(err, fs.ErrNotExist) {
+		f = ini.Empty(opts)
+		err = nil
+	}
+	if err != nil {
+		_ = lock.Unlock()
+		return nil, err
+	}
+
+	return &LockedFile{Filename: filename, File: f, lock: lock}, nil
+}
+
+// Save backs up the file and atomically replaces it with the contents of File.
+func (l *LockedFile) Save() error {
+	if l.SkipBackup {
+		return writeINIAtomic(l.File, l.Filename)
+	}
+	return SaveConfigAtomic(l.File, l.Filename)
+}
+
+// Close releases the lock on the file.
+func (l *LockedFile) Close() error {
+	return l.lock.Unlock()
+}
+
+// UpdateFile locks and loads the file, calls update to modify it, and then saves it.
+// The file isn't written if update returns an error.
+func UpdateFile(filename string, opts ini.LoadOptions, update func(f *ini.File) error) error {
+	l, err := OpenLocked(filename, opts)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = l.Close() }()
+
+	err = update(l.File)
+	if err != nil {
+		return err
+	}
+	return l.Save()
+}
+
+// SaveConfigAtomic backs up the existing file if backups are enabled for it, then writes the config file to a temporary file in the
+// same directory and renames it over the destination, so that other processes never read a partially
+// written file. Callers which read the file before writing it should hold a lock from OpenLocked.
+func SaveConfigAtomic(f *ini.File, filename string) error {
+	err := backupFile(filename)
+	if err != nil {
+		return err
+	}
+	return writeINIAtomic(f, filename)
+}
+
+func writeINIAtomic(f *ini.File, filename string) error {
+	return writeFileAtomic(filename, func(w io.Writer) error {
+		_, err := f.WriteTo(w)
+		return err
+	})
+}
+
+// resolvePath returns the absolute path of the file with any symlinks resolved, so that a file reached
+// through a symlink shares its lock and backups with the file it points to. The absolute path is returned
+// if the file doesn't exist yet.
+func resolvePath(filename string) (string, error) {
+	if resolved, err := filepath.EvalSymlinks(filename); err == nil {
+		filename = resolved
+	}
+	return filepath.Abs(filename)
+}
+
+// writeFileAtomic writes to a temporary file in the same directory as filename and renames it over filename.
+// The permissions of the existing file are kept. If filename is a symlink, such as to a file in a dotfiles
+// repository, the file it points to is replaced rather than the symlink.
+func writeFileAtomic(filename string, write func(w io.Writer) error) error {
+	filename, err := resolvePath(filename)
+	if err != nil {
+		return err
+	}
+
+	mode := os.FileMode(0600)
+	if info, err := os.Stat(filename); err == nil {
+		mode = info.Mode().Perm()
+	}
+
+	err = os.MkdirAll(filepath.Dir(filename), 0700)
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(filename), "."+filepath.Base(filename)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	// remove the temporary file if we fail before renaming it
+	defer os.Remove(tmp.Name())
+
+	err = write(tmp)
+	if err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	err = tmp.Sync()
+	if err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	err = tmp.Close()
+	if err != nil {
+		return err
+	}
+	err = os.Chmod(tmp.Name(), mode)
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), filename)
+}
@@ -0,0 +1,117 @@
+package cfaws
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/ini.v1"
+)
+
+func TestUpdateFileConcurrent(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	filename := filepath.Join(t.TempDir(), "credentials")
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := UpdateFile(filename, ini.LoadOptions{}, func(f *ini.File) error {
+				_, err := f.NewSection(fmt.Sprintf("profile-%d", i))
+				return err
+			})
+			assert.NoError(t, err)
+		}()
+	}
+	wg.Wait()
+
+	f, err := ini.Load(filename)
+	require.NoError(t, err)
+	// every update is kept, as each one reads the file after the previous one was written
+	assert.Len(t, f.SectionStrings(), 21)
+}
+
+func TestBackupAndRestore(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	filename := filepath.Join(t.TempDir(), "config")
+
+	for i := 0; i < MaxBackups+5; i++ {
+		err := UpdateFile(filename, ini.LoadOptions{}, func(f *ini.File) error {
+			f.Section("default").Key("version").SetValue(fmt.Sprint(i))
+			return nil
+		})
+		require.NoError(t, err)
+	}
+
+	backups, err := ListBackups(filename)
+	require.NoError(t, err)
+	assert.Len(t, backups, MaxBackups)
+
+	// the newest backup is the file before the last write
+	data, err := os.ReadFile(backups[0].Path)
+	require.NoError(t, err)
+	assert.Contains(t, string(data), fmt.Sprintf("version = %d", MaxBackups+3))
+
+	err = RestoreBackup(filename, backups[0])
+	require.NoError(t, err)
+
+	f, err := ini.Load(filename)
+	require.NoError(t, err)
+	assert.Equal(t, fmt.Sprint(MaxBackups+3), f.Section("default").Key("version").String())
+
+	// the file before the restore was backed up
+	backups, err = ListBackups(filename)
+	require.NoError(t, err)
+	data, err = os.ReadFile(backups[0].Path)
+	require.NoError(t, err)
+	assert.Contains(t, string(data), fmt.Sprintf("version = %d", MaxBackups+4))
+}
+
+func TestUpdateFileSymlink(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	dir := t.TempDir()
+	target := filepath.Join(dir, "dotfiles", "config")
+	require.NoError(t, os.MkdirAll(filepath.Dir(target), 0700))
+	require.NoError(t, os.WriteFile(target, []byte("[default]\nregion = us-east-1\n"), 0600))
+	link := filepath.Join(dir, "config")
+	require.NoError(t, os.Symlink(target, link))
+
+	err := UpdateFile(link, ini.LoadOptions{}, func(f *ini.File) error {
+		f.Section("default").Key("region").SetValue("us-west-2")
+		return nil
+	})
+	require.NoError(t, err)
+
+	// the symlink is kept, and the file it points to is updated
+	info, err := os.Lstat(link)
+	require.NoError(t, err)
+	assert.Equal(t, os.ModeSymlink, info.Mode().Type())
+
+	f, err := ini.Load(target)
+	require.NoError(t, err)
+	assert.Equal(t, "us-west-2", f.Section("default").Key("region").String())
+}
+
+func TestCredentialsFileNotBackedUp(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	filename := filepath.Join(t.TempDir(), "credentials")
+	t.Setenv("AWS_SHARED_CREDENTIALS_FILE", filename)
+
+	for i := 0; i < 2; i++ {
+		err := UpdateFile(filename, ini.LoadOptions{}, func(f *ini.File) error {
+			f.Section("default").Key("aws_access_key_id").SetValue(fmt.Sprintf("AKIA%d", i))
+			return nil
+		})
+		require.NoError(t, err)
+	}
+
+	// backups of the credentials file would keep plaintext credentials, so they are opt-in
+	backups, err := ListBackups(filename)
+	require.NoError(t, err)
+	assert.Empty(t, backups)
+}
@@ -26,13 +26,14 @@ func ParseGrantedSSOProfile(ctx context.Context, profile *Profile) (*config.Shar
 		return nil, err
 	}
 	cfg.SSOAccountID = item.Value()
+	sessionStartURL, sessionRegion := ssoSessionValues(profile, cfg)
 	item, err = profile.RawConfig.GetKey("granted_sso_region")
 	if err != nil {
-		// the region may have been populated by an sso session section by the aws SDK
-		if cfg.SSOSession.SSORegion == "" {
+		// the region may have been populated by an sso-session section
+		if sessionRegion == "" {
 			return nil, err
 		} else {
-			cfg.SSORegion = cfg.SSOSession.SSORegion
+			cfg.SSORegion = sessionRegion
 		}
 	} else {
 		cfg.SSORegion = item.Value()
@@ -46,10 +47,10 @@ func ParseGrantedSSOProfile(ctx context.Context, profile *Profile) (*config.Shar
 
 	item, err = profile.RawConfig.GetKey("granted_sso_start_url")
 	if err != nil {
-		if cfg.SSOSession.SSOStartURL == "" {
+		if sessionStartURL == "" {
 			return nil, err
 		} else {
-			cfg.SSOStartURL = cfg.SSOSession.SSOStartURL
+			cfg.SSOStartURL = sessionStartURL
 		}
 	} else {
 		cfg.SSOStartURL = item.Value()
@@ -84,7 +85,7 @@ func ParseGrantedSSOProfile(ctx context.Context, profile *Profile) (*config.Shar
 		if err != nil {
 			return nil, err
 		}
-		err = validateCredentialProcess(item.Value(), profile.Name)
+		err = ValidateCredentialProcess(item.Value(), profile.Name)
 		if err != nil {
 			return nil, err
 		}
@@ -102,17 +103,36 @@ func IsValidGrantedProfile(profile *Profile) error {
 			return fmt.Errorf("invalid aws config for granted login. '%s' field must be provided", value)
 		}
 	}
-	if profile.AWSConfig.SSOSession != nil {
-		if profile.AWSConfig.SSOSession.SSORegion == "" && !profile.RawConfig.HasKey("granted_sso_region") {
+	if profile.AWSConfig.SSOSession != nil || profile.SSOSessionConfig != nil {
+		startURL, region := ssoSessionValues(profile, profile.AWSConfig)
+		if region == "" && !profile.RawConfig.HasKey("granted_sso_region") {
 			return fmt.Errorf("invalid aws config for granted login. '%s' field must be provided", "granted_sso_region")
 		}
-		if profile.AWSConfig.SSOSession.SSOStartURL == "" && !profile.RawConfig.HasKey("granted_sso_start_url") {
+		if startURL == "" && !profile.RawConfig.HasKey("granted_sso_start_url") {
 			return fmt.Errorf("invalid aws config for granted login. '%s' field must be provided", "granted_sso_start_url")
 		}
 	}
 	return nil
 }
 
+// ssoSessionValues returns the start URL and region from the sso-session referenced by the profile.
+// Values parsed from the config file by Granted take precedence over those parsed by the AWS SDK.
+func ssoSessionValues(profile *Profile, cfg config.SharedConfig) (startURL string, region string) {
+	if profile.SSOSessionConfig != nil {
+		startURL = profile.SSOSessionConfig.StartURL
+		region = profile.SSOSessionConfig.Region
+	}
+	if cfg.SSOSession != nil {
+		if startURL == "" {
+			startURL = cfg.SSOSession.SSOStartURL
+		}
+		if region == "" {
+			region = cfg.SSOSession.SSORegion
+		}
+	}
+	return startURL, region
+}
+
 // check if the config section has any keys prefixed with "granted_sso_"
 func hasGrantedSSOPrefix(rawConfig *ini.Section) bool {
 	for _, v := range rawConfig.KeyStrings() {
@@ -123,12 +143,12 @@ func hasGrantedSSOPrefix(rawConfig *ini.Section) bool {
 	return false
 }
 
-// validateCredentialProcess checks whether the granted_ prefixed AWS profiles
+// ValidateCredentialProcess checks whether the granted_ prefixed AWS profiles
 // are correctly using the granted credential-process override or not.
 // also check whether the provided flag to 'granted credential-process --profile pname'
 // matches the AWS config profile name. If it doesn't then return an err
 // as the user will certainly run into unexpected behaviour.
-func validateCredentialProcess(arg string, awsProfileName string) error {
+func ValidateCredentialProcess(arg string, awsProfileName string) error {
 	regex := regexp.MustCompile(`^(\s+)?(dgranted|granted)\s+credential-process.*--profile\s+(?P<PName>([^\s]+))`)
 
 	if regex.MatchString(arg) {
@@ -33,7 +33,7 @@ func TestValidateCredentialProcess(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 
-			err := validateCredentialProcess(tt.arg, tt.profileName)
+			err := ValidateCredentialProcess(tt.arg, tt.profileName)
 			if err != nil {
 				if err.Error() != tt.wantErr {
 					t.Fatal(err)
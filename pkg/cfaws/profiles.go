@@ -44,10 +44,15 @@ type Profile struct {
 	Initialised                    bool
 	LoadingError                   error
 	HasSecureStorageIAMCredentials bool
+	// SSOSessionConfig is the sso-session section referenced by the profile's 'sso_session' key, if any.
+	SSOSessionConfig *SSOSession
 }
 
 // Returns the SSORegion from either the session or the profile in that order
 func (p *Profile) SSORegion() string {
+	if p.SSOSessionConfig != nil && p.SSOSessionConfig.Region != "" {
+		return p.SSOSessionConfig.Region
+	}
 	if p.AWSConfig.SSOSession != nil {
 		return p.AWSConfig.SSOSession.SSORegion
 	}
@@ -56,17 +61,17 @@ func (p *Profile) SSORegion() string {
 
 // Returns the SSOStartURL from either the session or the profile in that order
 func (p *Profile) SSOStartURL() string {
+	if p.SSOSessionConfig != nil && p.SSOSessionConfig.StartURL != "" {
+		return p.SSOSessionConfig.StartURL
+	}
 	if p.AWSConfig.SSOSession != nil {
 		return p.AWSConfig.SSOSession.SSOStartURL
 	}
 	return p.AWSConfig.SSOStartURL
 }
 
-// Returns the SSOScopes from the profile. Currently, this looks up the non-standard
-// 'granted_sso_registration_scopes' key on the profile.
-//
-// In future, we'll make this fully compatible with the 'sso_registration_scopes' config used
-// in the native AWS CLI, i.e.
+// Returns the SSOScopes for the profile. The 'sso_registration_scopes' key on the
+// profile's sso-session is used if present, for compatibility with the native AWS CLI, i.e.
 //
 // [profile AWSAdministratorAccess-123456789012]
 // sso_session = commonfate
@@ -79,9 +84,11 @@ func (p *Profile) SSOStartURL() string {
 // sso_region = ap-southeast-2
 // sso_registration_scopes = sso:account:access
 //
-// However, the AWS v2 Go SDK does not support reading 'sso_registration_scopes', so in order
-// to support this we'll need to parse and lookup the `sso-session` entries in the config file separately.
+// Otherwise, this falls back to the non-standard 'granted_sso_registration_scopes' key on the profile.
 func (p *Profile) SSOScopes() []string {
+	if p.SSOSessionConfig != nil && len(p.SSOSessionConfig.RegistrationScopes) > 0 {
+		return p.SSOSessionConfig.RegistrationScopes
+	}
 	if p.RawConfig == nil {
 		return nil
 	}
@@ -94,6 +101,19 @@ func (p *Profile) SSOScopes() []string {
 	return strings.Split(scopeVal, ",")
 }
 
+// Returns the flow used to log in to IAM Identity Center for the profile, from the
+// 'granted_sso_login_flow' key on the profile's sso-session or on the profile itself.
+// An empty string means the flow from the Granted config file is used.
+func (p *Profile) SSOLoginFlow() string {
+	if p.SSOSessionConfig != nil && p.SSOSessionConfig.LoginFlow != "" {
+		return p.SSOSessionConfig.LoginFlow
+	}
+	if p.RawConfig == nil {
+		return ""
+	}
+	return keyValue(p.RawConfig, "granted_sso_login_flow")
+}
+
 var ErrProfileNotInitialised error = errors.New("profile not initialised")
 
 var ErrProfileNotFound error = errors.New("profile not found")
@@ -102,6 +122,7 @@ type Profiles struct {
 	// alphabetically sorted after first load
 	ProfileNames []string
 	profiles     map[string]*Profile
+	ssoSessions  map[string]*SSOSession
 }
 
 func (p *Profiles) HasProfile(profile string) bool {
@@ -192,7 +213,7 @@ func LoadProfiles() (*Profiles, error) {
 }
 
 func loadProfiles(configFileLoader, credentialsFileLoader ConfigFileLoader) (*Profiles, error) {
-	p := Profiles{profiles: make(map[string]*Profile)}
+	p := Profiles{profiles: make(map[string]*Profile), ssoSessions: make(map[string]*SSOSession)}
 
 	err := p.loadConfigFile(configFileLoader)
 	if err != nil {
@@ -203,6 +224,7 @@ func loadProfiles(configFileLoader, credentialsFileLoader ConfigFileLoader) (*Pr
 	if err != nil {
 		return nil, err
 	}
+	p.linkSSOSessions()
 	sort.Strings(p.ProfileNames)
 	return &p, nil
 }
@@ -265,6 +287,10 @@ func (p *Profiles) loadConfigFile(loader ConfigFileLoader) error {
 	// Iterate through the config sections
 	for _, section := range configFile.Sections() {
 		// the ini package adds an extra section called DEFAULT, but this is different to the AWS standard of 'default' so we ignore it an only look at 'default'
+		if ssoSession := parseSSOSession(section); ssoSession != nil {
+			p.ssoSessions[ssoSession.Name] = ssoSession
+			continue
+		}
 		if section.Name() != "DEFAULT" {
 			// Check if the section is prefixed with 'profile ' and that the profile has a name
 			if ((strings.HasPrefix(section.Name(), "profile ") && len(section.Name()) > 8) || section.Name() == "default") && IsLegalProfileName(strings.TrimPrefix(section.Name(), "profile ")) {
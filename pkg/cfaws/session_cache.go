@@ -0,0 +1,129 @@
+package cfaws
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/common-fate/clio"
+	"github.com/common-fate/granted/pkg/securestorage"
+)
+
+// SessionCacheKey returns the key that session credentials for the profile are cached under.
+// The key is the profile name followed by a hash of the resolved configuration of the profile and
+// its parents, and the options used to assume it. This prevents credentials being reused when
+// they were assumed with a different duration, chained role or MFA device.
+func (p *Profile) SessionCacheKey(configOpts ConfigOpts) string {
+	h := sha256.New()
+	for _, profile := range append(append([]*Profile{}, p.Parents...), p) {
+		writeProfileConfig(h, profile)
+	}
+	_, _ = fmt.Fprintf(h, "duration=%s\n", configOpts.Duration)
+	_, _ = fmt.Fprintf(h, "args=%s\n", strings.Join(configOpts.Args, " "))
+	return p.Name + "-" + hex.EncodeToString(h.Sum(nil)[:8])
+}
+
+func writeProfileConfig(w io.Writer, p *Profile) {
+	_, _ = fmt.Fprintf(w, "[%s]\ntype=%s\n", p.Name, p.ProfileType)
+
+	// profiles for chained roles are built from flags, so they don't have any raw config
+	_, _ = fmt.Fprintf(w, "role_arn=%s\nmfa_serial=%s\n", p.AWSConfig.RoleARN, p.AWSConfig.MFASerial)
+
+	if p.RawConfig == nil {
+		return
+	}
+	keys := p.RawConfig.KeyStrings()
+	sort.Strings(keys)
+	for _, k := range keys {
+		_, _ = fmt.Fprintf(w, "%s=%s\n", k, p.RawConfig.Key(k).Value())
+	}
+}
+
+// SessionCredentialMetadata returns the metadata stored alongside cached session credentials for the profile.
+func (p *Profile) SessionCredentialMetadata() *securestorage.SessionCredentialMetadata {
+	return &securestorage.SessionCredentialMetadata{
+		Profile:   p.Name,
+		Assumer:   p.ProfileType,
+		RoleARN:   p.AWSConfig.RoleARN,
+		CreatedAt: time.Now(),
+	}
+}
+
+// CacheSessionCredentials stores the credentials in the session credential cache under the profile's SessionCacheKey.
+func (p *Profile) CacheSessionCredentials(configOpts ConfigOpts, creds aws.Credentials) error {
+	key := p.SessionCacheKey(configOpts)
+	clio.Debugw("caching session credentials", "profile", p.Name, "key", key, "expires", creds.Expires.String(), "canExpire", creds.CanExpire)
+	return p.SessionCache().StoreCredentials(key, creds)
+}
+
+// CachedSessionCredentials returns the cached session credentials for the profile, or nil if there are none.
+func (p *Profile) CachedSessionCredentials(configOpts ConfigOpts) (*aws.Credentials, error) {
+	return p.SessionCache().GetCredentials(p.SessionCacheKey(configOpts))
+}
+
+// ProfileSessionCache is the session credential cache for a profile. It can be used as a credentialserver.Cache,
+// and stores the profile's metadata alongside the credentials.
+type ProfileSessionCache struct {
+	Profile *Profile
+	Storage securestorage.SessionCredentialSecureStorage
+}
+
+// SessionCache returns the session credential cache for the profile.
+func (p *Profile) SessionCache() *ProfileSessionCache {
+	return &ProfileSessionCache{Profile: p, Storage: securestorage.NewSecureSessionCredentialStorage()}
+}
+
+func (c *ProfileSessionCache) GetCredentials(key string) (*aws.Credentials, error) {
+	return c.Storage.GetCredentials(key)
+}
+
+// StoreCredentials caches the credentials, and removes the profile's expired entries and the entry
+// cached under the bare profile name by older versions of Granted. Entries for the profile's other assume
+// contexts, such as a different duration, are kept while they are valid so that they can still be reused.
+func (c *ProfileSessionCache) StoreCredentials(key string, creds aws.Credentials) error {
+	err := c.Storage.StoreCredentialsWithMetadata(key, creds, c.Profile.SessionCredentialMetadata())
+	if err != nil {
+		return err
+	}
+
+	keys, err := c.Storage.SecureStorage.ListKeys()
+	if err != nil {
+		clio.Debugw("could not list session credentials to remove expired entries", "profile", c.Profile.Name, "error", err)
+		return nil
+	}
+	for _, k := range keys {
+		if k == key || !isSessionCacheKeyFor(k, c.Profile.Name) {
+			continue
+		}
+		if k != c.Profile.Name {
+			cached, err := c.Storage.GetCredentials(k)
+			if err == nil && !cached.Expired() {
+				continue
+			}
+		}
+		err = c.Storage.SecureStorage.Clear(k)
+		if err != nil {
+			clio.Debugw("could not remove session credentials", "profile", c.Profile.Name, "key", k, "error", err)
+		}
+	}
+	return nil
+}
+
+// isSessionCacheKeyFor reports whether key is a SessionCacheKey for the profile,
+// or the bare profile name which older versions of Granted cached credentials under.
+func isSessionCacheKeyFor(key string, profile string) bool {
+	if key == profile {
+		return true
+	}
+	hash, ok := strings.CutPrefix(key, profile+"-")
+	if !ok || len(hash) != 16 {
+		return false
+	}
+	_, err := hex.DecodeString(hash)
+	return err == nil
+}
@@ -0,0 +1,63 @@
+package cfaws
+
+import (
+	"testing"
+	"time"
+
+	"github.com/99designs/keyring"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/common-fate/granted/pkg/securestorage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/ini.v1"
+)
+
+func TestSessionCacheKey(t *testing.T) {
+	f, err := ini.Load([]byte(`
+[profile base]
+region = us-east-1
+
+[profile role]
+role_arn = arn:aws:iam::123456789012:role/Admin
+source_profile = base
+`))
+	require.NoError(t, err)
+
+	base := &Profile{Name: "base", ProfileType: "AWS_IAM", RawConfig: f.Section("profile base")}
+	role := &Profile{Name: "role", ProfileType: "AWS_IAM", RawConfig: f.Section("profile role"), Parents: []*Profile{base}}
+	role.AWSConfig.RoleARN = "arn:aws:iam::123456789012:role/Admin"
+
+	key := role.SessionCacheKey(ConfigOpts{Duration: time.Hour})
+	assert.Regexp(t, `^role-[0-9a-f]{16}$`, key)
+
+	// the key is stable, and doesn't depend on options which don't change the credentials
+	assert.Equal(t, key, role.SessionCacheKey(ConfigOpts{Duration: time.Hour, MFATokenCode: "123456", UsingCredentialProcess: true}))
+
+	assert.NotEqual(t, key, role.SessionCacheKey(ConfigOpts{Duration: 2 * time.Hour}), "duration should change the key")
+
+	f.Section("profile base").Key("region").SetValue("us-west-2")
+	assert.NotEqual(t, key, role.SessionCacheKey(ConfigOpts{Duration: time.Hour}), "parent config should change the key")
+}
+
+func TestProfileSessionCacheRemovesExpiredEntries(t *testing.T) {
+	ring := keyring.NewArrayKeyring(nil)
+	storage := securestorage.SessionCredentialSecureStorage{SecureStorage: securestorage.SecureStorage{Backend: ring}}
+	live := aws.Credentials{AccessKeyID: "AKIA", CanExpire: true, Expires: time.Now().Add(time.Hour)}
+	expired := aws.Credentials{AccessKeyID: "AKIA", CanExpire: true, Expires: time.Now().Add(-time.Hour)}
+
+	// the bare profile name was used as the key by older versions of Granted
+	require.NoError(t, storage.StoreCredentials("prod", live))
+	require.NoError(t, storage.StoreCredentials("prod-0123456789abcdef", expired))
+	require.NoError(t, storage.StoreCredentials("prod-eu-0123456789abcdef", expired))
+	require.NoError(t, storage.StoreCredentials("dev-0123456789abcdef", expired))
+
+	cache := ProfileSessionCache{Profile: &Profile{Name: "prod", ProfileType: "AWS_IAM"}, Storage: storage}
+
+	// credentials for two assume contexts, such as 'assume --duration 8h' and 'credential-process', are both kept
+	require.NoError(t, cache.StoreCredentials("prod-1111111111111111", live))
+	require.NoError(t, cache.StoreCredentials("prod-2222222222222222", live))
+
+	keys, err := ring.Keys()
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"prod-1111111111111111", "prod-2222222222222222", "prod-eu-0123456789abcdef", "dev-0123456789abcdef"}, keys)
+}
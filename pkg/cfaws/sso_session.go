@@ -0,0 +1,84 @@
+package cfaws
+
+import (
+	"strings"
+
+	"gopkg.in/ini.v1"
+)
+
+// SSOSession is an '[sso-session <name>]' section in the AWS config file.
+//
+// The AWS v2 Go SDK parses these sections, but does not expose 'sso_registration_scopes',
+// so we parse them ourselves when loading the config file.
+//
+// [sso-session commonfate]
+// sso_start_url = https://example.awsapps.com/start
+// sso_region = ap-southeast-2
+// sso_registration_scopes = sso:account:access
+//
+// The non-standard 'granted_sso_login_flow' key selects the flow Granted uses to log in to the session.
+type SSOSession struct {
+	Name               string
+	StartURL           string
+	Region             string
+	RegistrationScopes []string
+	LoginFlow          string
+}
+
+const ssoSessionSectionPrefix = "sso-session "
+
+// parseSSOSession returns the SSO session for a config section, or nil if the section isn't an sso-session.
+func parseSSOSession(section *ini.Section) *SSOSession {
+	name := strings.TrimSpace(strings.TrimPrefix(section.Name(), ssoSessionSectionPrefix))
+	if !strings.HasPrefix(section.Name(), ssoSessionSectionPrefix) || name == "" {
+		return nil
+	}
+
+	return &SSOSession{
+		Name:               name,
+		StartURL:           strings.TrimSuffix(keyValue(section, "sso_start_url"), "/"),
+		Region:             keyValue(section, "sso_region"),
+		RegistrationScopes: splitScopes(keyValue(section, "sso_registration_scopes")),
+		LoginFlow:          keyValue(section, "granted_sso_login_flow"),
+	}
+}
+
+// keyValue returns the value of a key in the section, or an empty string if the key doesn't exist.
+// Unlike section.Key, this doesn't add the key to the section if it is missing.
+func keyValue(section *ini.Section, name string) string {
+	if !section.HasKey(name) {
+		return ""
+	}
+	return section.Key(name).String()
+}
+
+// splitScopes parses a comma separated list of scopes, ignoring whitespace and empty entries.
+func splitScopes(val string) []string {
+	var scopes []string
+	for _, s := range strings.Split(val, ",") {
+		s = strings.TrimSpace(s)
+		if s != "" {
+			scopes = append(scopes, s)
+		}
+	}
+	return scopes
+}
+
+// SSOSession returns the sso-session section with the given name.
+func (p *Profiles) SSOSession(name string) (*SSOSession, bool) {
+	s, ok := p.ssoSessions[name]
+	return s, ok
+}
+
+// linkSSOSessions associates each profile with the sso-session it references through the 'sso_session' key.
+func (p *Profiles) linkSSOSessions() {
+	for _, profile := range p.profiles {
+		if profile.RawConfig == nil || !profile.RawConfig.HasKey("sso_session") {
+			continue
+		}
+		name := keyValue(profile.RawConfig, "sso_session")
+		if s, ok := p.ssoSessions[name]; ok {
+			profile.SSOSessionConfig = s
+		}
+	}
+}
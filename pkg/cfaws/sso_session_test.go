@@ -0,0 +1,64 @@
+package cfaws
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/ini.v1"
+)
+
+type testLoader struct {
+	data string
+}
+
+func (l testLoader) Path() string { return "" }
+func (l testLoader) Load() (*ini.File, error) {
+	return ini.Load([]byte(l.data))
+}
+
+func TestLoadProfilesWithSSOSession(t *testing.T) {
+	config := `
+[profile native]
+sso_session = commonfate
+sso_account_id = 123456789012
+sso_role_name = AWSAdministratorAccess
+
+[profile legacy]
+sso_start_url = https://legacy.awsapps.com/start
+sso_region = us-east-1
+sso_account_id = 123456789012
+sso_role_name = AWSAdministratorAccess
+granted_sso_registration_scopes = sso:account:access
+
+[sso-session commonfate]
+sso_start_url = https://example.awsapps.com/start/
+sso_region = ap-southeast-2
+sso_registration_scopes = sso:account:access, openid
+`
+	profiles, err := loadProfiles(testLoader{data: config}, testLoader{})
+	require.NoError(t, err)
+
+	// sso-session sections should not be loaded as profiles
+	assert.Equal(t, []string{"legacy", "native"}, profiles.ProfileNames)
+
+	session, ok := profiles.SSOSession("commonfate")
+	require.True(t, ok)
+	assert.Equal(t, &SSOSession{
+		Name:               "commonfate",
+		StartURL:           "https://example.awsapps.com/start",
+		Region:             "ap-southeast-2",
+		RegistrationScopes: []string{"sso:account:access", "openid"},
+	}, session)
+
+	native, err := profiles.Profile("native")
+	require.NoError(t, err)
+	assert.Equal(t, "https://example.awsapps.com/start", native.SSOStartURL())
+	assert.Equal(t, "ap-southeast-2", native.SSORegion())
+	assert.Equal(t, []string{"sso:account:access", "openid"}, native.SSOScopes())
+
+	legacy, err := profiles.Profile("legacy")
+	require.NoError(t, err)
+	assert.Nil(t, legacy.SSOSessionConfig)
+	assert.Equal(t, []string{"sso:account:access"}, legacy.SSOScopes())
+}
@@ -47,7 +47,19 @@ type Config struct {
 	// and CustomSSOBrowserPath fields.
 	SSOBrowserLaunchTemplate *BrowserLaunchTemplate `toml:",omitempty"`
 
-	Keyring                *KeyringConfig `toml:",omitempty"`
+	// SSOLoginFlow is the flow used to log in to IAM Identity Center, either
+	// 'device-code' (the default) or 'pkce'. It can be overridden for an
+	// sso-session using the 'granted_sso_login_flow' key.
+	SSOLoginFlow string `toml:",omitempty"`
+
+	// SSOLoginQRCode, if 'true', prints a QR code of the login URL when
+	// logging in to IAM Identity Center in headless mode.
+	SSOLoginQRCode bool `toml:",omitempty"`
+
+	Keyring *KeyringConfig `toml:",omitempty"`
+	// SecureStorage selects where cached tokens and credentials are stored.
+	// If it isn't set, the keyring configured in Keyring is used.
+	SecureStorage          *SecureStorageConfig `toml:",omitempty"`
 	Ordering               string
 	ExportCredentialSuffix string
 	// AccessRequestURL is a Granted Approvals URL that users can visit
@@ -57,6 +69,10 @@ type Config struct {
 	ExportCredsToAWS bool `toml:",omitempty"`
 	// Set to true to export sso tokens to ~/.aws/sso/cache
 	ExportSSOToken bool `toml:",omitempty"`
+	// BackupAWSCredentialsFile, if true, backs up the AWS credentials file before Granted writes to it,
+	// so that it can be restored with 'granted config restore --file credentials'. It is off by default,
+	// as the backups keep a plaintext copy of the credentials in the file.
+	BackupAWSCredentialsFile bool `toml:",omitempty"`
 
 	AccessRequestURL string `toml:",omitempty"`
 
@@ -81,17 +97,43 @@ type Config struct {
 		RequiredKeys            map[string]string `toml:",omitempty"`
 		Variables               map[string]string `toml:",omitempty"`
 		Registries              []Registry        `toml:",omitempty"`
+		// BackgroundSync, if true, syncs profile registries in a background process
+		// so that 'assume' doesn't wait for the registries to be pulled.
+		BackgroundSync bool `toml:",omitempty"`
 	} `toml:",omitempty"`
 
 	// CredentialProcessAutoLogin, if 'true', will automatically attempt to
 	// authenticate to IAM Identity Center if your AWS SSO
 	// access token is expired.
 	//
-	// Do not set this to 'true' on headless systems, as it
-	// will cause Granted to hang during the login process.
+	// On headless systems, Granted prints the login URL and waits
+	// for the login to be completed on another device. Set
+	// GRANTED_HEADLESS=true if headless mode isn't detected automatically.
 	CredentialProcessAutoLogin bool `toml:",omitempty"`
 
 	SSO map[string]AWSSSOConfiguration `toml:",omitempty"`
+
+	// Hooks are external commands which run during the assume command,
+	// for example to automatically request access to a role.
+	Hooks *HooksConfig `toml:",omitempty"`
+}
+
+type HooksConfig struct {
+	// PreAssume hooks run before credentials are retrieved for a profile.
+	PreAssume []Hook `toml:",omitempty"`
+	// PostAssume hooks run after credentials have been retrieved successfully.
+	PostAssume []Hook `toml:",omitempty"`
+	// OnNoAccess hooks run if access to the role is denied. If all of the hooks
+	// succeed, the assume is retried.
+	OnNoAccess []Hook `toml:",omitempty"`
+}
+
+type Hook struct {
+	// Command is the executable to run. It receives details of the assume as JSON on stdin.
+	Command string
+	Args    []string `toml:",omitempty"`
+	// Timeout is a duration string such as '30s'. Defaults to 5 minutes.
+	Timeout string `toml:",omitempty"`
 }
 
 type KeyringConfig struct {
@@ -100,6 +142,52 @@ type KeyringConfig struct {
 	FileDir                 *string `toml:",omitempty"`
 	LibSecretCollectionName *string `toml:",omitempty"`
 	PassDir                 *string `toml:",omitempty"` // PassDir is the pass password-store directory, ~/ is resolved to the users' home dir
+	// EncryptedFileKeyFile is a file containing the key for the 'encrypted-file' backend.
+	EncryptedFileKeyFile *string `toml:",omitempty"`
+	// EncryptedFileSSHKey is the fingerprint or public key of an Ed25519 or RSA key in the SSH agent.
+	// The key for the 'encrypted-file' backend is derived from a signature made by the agent.
+	EncryptedFileSSHKey *string `toml:",omitempty"`
+}
+
+type SecureStorageConfig struct {
+	// Backend is the storage backend used by default: 'keyring' (the default), 'vault-transit', '1password' or 'age'.
+	Backend string `toml:",omitempty"`
+	// Storages overrides the backend for individual storages, keyed by the storage name,
+	// such as 'aws-sso-tokens' or 'aws-iam-credentials'.
+	Storages map[string]string `toml:",omitempty"`
+
+	VaultTransit *VaultTransitConfig `toml:",omitempty"`
+	OnePassword  *OnePasswordConfig  `toml:",omitempty"`
+	Age          *AgeConfig          `toml:",omitempty"`
+}
+
+// VaultTransitConfig configures the 'vault-transit' backend, which encrypts items with a
+// HashiCorp Vault transit key and stores the ciphertext in local files.
+type VaultTransitConfig struct {
+	// Address of the Vault server. Defaults to VAULT_ADDR.
+	Address string `toml:",omitempty"`
+	// MountPath of the transit secrets engine. Defaults to 'transit'.
+	MountPath string `toml:",omitempty"`
+	KeyName   string
+	// Dir stores the encrypted items. Defaults to the Granted config folder.
+	Dir string `toml:",omitempty"`
+}
+
+// OnePasswordConfig configures the '1password' backend, which stores items using the 1Password CLI ('op').
+type OnePasswordConfig struct {
+	Vault string
+	// Account is passed to 'op --account', for users signed in to more than one account.
+	Account string `toml:",omitempty"`
+}
+
+// AgeConfig configures the 'age' backend, which stores items in files encrypted using the age CLI.
+type AgeConfig struct {
+	// Recipients are the public keys which items are encrypted to.
+	Recipients []string
+	// IdentityFile is the private key used to decrypt items.
+	IdentityFile string
+	// Dir stores the encrypted items. Defaults to the Granted config folder.
+	Dir string `toml:",omitempty"`
 }
 
 type Registry struct {
@@ -112,6 +200,26 @@ type Registry struct {
 	PrefixDuplicateProfiles bool   `toml:"prefixDuplicateProfiles,omitempty"`
 	PrefixAllProfiles       bool   `toml:"prefixAllProfiles,omitempty"`
 	Type                    string `toml:"type,omitempty"`
+	// TrustedKeys are SSH or minisign public keys. If set, the registry must contain
+	// a 'granted.manifest' file signed by one of the keys, and sync refuses any files
+	// which don't match the signed manifest.
+	TrustedKeys []string `toml:"trustedKeys,omitempty"`
+	// Policy restricts the keys that profiles synced from the registry may contain.
+	Policy *RegistryPolicy `toml:"policy,omitempty"`
+	// SyncInterval is how often the registry is automatically synced, as a duration string such as '15m'. Defaults to '24h'.
+	SyncInterval string `toml:"syncInterval,omitempty"`
+}
+
+// RegistryPolicy restricts the keys that profiles synced from a registry may contain.
+// Sections which violate the policy are quarantined rather than merged into the AWS config file.
+type RegistryPolicy struct {
+	// AllowKeys, if set, are the only keys that sections may contain. Glob patterns such as 'granted_*' are supported.
+	AllowKeys []string `toml:"allowKeys,omitempty"`
+	// DenyKeys are keys that sections must not contain, such as 'ca_bundle' or 'endpoint_url'. Glob patterns are supported.
+	DenyKeys []string `toml:"denyKeys,omitempty"`
+	// GrantedCredentialProcessOnly only allows 'credential_process' values of the form
+	// 'granted credential-process --profile <profile name>', where the profile name matches the profile.
+	GrantedCredentialProcessOnly bool `toml:"grantedCredentialProcessOnly,omitempty"`
 }
 
 type AWSSSOConfiguration struct {
@@ -120,6 +228,21 @@ type AWSSSOConfiguration struct {
 	Prefix              string
 	NoCredentialProcess bool
 	ProfileTemplate     string
+	// Filter restricts the accounts and roles that profiles are generated for.
+	Filter SSOProfileFilter `toml:",omitempty"`
+}
+
+// SSOProfileFilter restricts the accounts and roles that 'granted sso populate' and 'granted sso generate'
+// create profiles for. Include filters only allow matching profiles, and exclude filters take precedence.
+// Name filters are glob patterns, such as 'prod-*'. Accounts can't be filtered by organizational unit or tag,
+// as IAM Identity Center doesn't return them.
+type SSOProfileFilter struct {
+	IncludeAccountIDs   []string `toml:",omitempty"`
+	ExcludeAccountIDs   []string `toml:",omitempty"`
+	IncludeAccountNames []string `toml:",omitempty"`
+	ExcludeAccountNames []string `toml:",omitempty"`
+	IncludeRoles        []string `toml:",omitempty"`
+	ExcludeRoles        []string `toml:",omitempty"`
 }
 
 // NewDefaultConfig returns a config with OS specific defaults populated
@@ -0,0 +1,214 @@
+// package credentialserver serves AWS credentials over a local HTTP endpoint
+// which is compatible with the container credentials provider used by the AWS SDKs
+// and the AWS CLI (AWS_CONTAINER_CREDENTIALS_FULL_URI).
+//
+// Unlike exporting static credentials into the environment, clients of the
+// server will retrieve refreshed credentials once the previous ones expire.
+package credentialserver
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/common-fate/clio"
+)
+
+// RetrieveFunc returns a fresh set of credentials, for example by calling
+// Profile.AssumeTerminal.
+type RetrieveFunc func(ctx context.Context) (aws.Credentials, error)
+
+// Cache persists credentials between server restarts.
+type Cache interface {
+	GetCredentials(key string) (*aws.Credentials, error)
+	StoreCredentials(key string, credentials aws.Credentials) error
+}
+
+type Opts struct {
+	// Retrieve is called whenever the cached credentials are missing or about to expire.
+	Retrieve RetrieveFunc
+	// AuthToken must be provided by clients in the Authorization header.
+	AuthToken string
+	// RefreshWindow is how long before expiry credentials are refreshed.
+	RefreshWindow time.Duration
+	// Cache is optional, if provided credentials are loaded from and stored to it under CacheKey.
+	Cache    Cache
+	CacheKey string
+}
+
+type Server struct {
+	opts Opts
+
+	mu    sync.Mutex
+	creds *aws.Credentials
+}
+
+// containerCredentials is the response schema expected by the AWS SDK container credentials provider.
+type containerCredentials struct {
+	AccessKeyID     string `json:"AccessKeyId"`
+	SecretAccessKey string `json:"SecretAccessKey"`
+	Token           string `json:"Token,omitempty"`
+	Expiration      string `json:"Expiration,omitempty"`
+}
+
+type errorResponse struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+func New(opts Opts) (*Server, error) {
+	if opts.Retrieve == nil {
+		return nil, errors.New("credential server requires a credential retrieval function")
+	}
+	if opts.AuthToken == "" {
+		return nil, errors.New("credential server requires an authorization token")
+	}
+	return &Server{opts: opts}, nil
+}
+
+// GenerateAuthToken returns a random token suitable for AWS_CONTAINER_AUTHORIZATION_TOKEN.
+func GenerateAuthToken() (string, error) {
+	b := make([]byte, 32)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// needsRefresh returns true if the credentials are missing or expire within the refresh window.
+func (s *Server) needsRefresh(creds *aws.Credentials, now time.Time) bool {
+	if creds == nil || !creds.HasKeys() {
+		return true
+	}
+	if !creds.CanExpire {
+		return false
+	}
+	return creds.Expires.Add(-s.opts.RefreshWindow).Before(now)
+}
+
+// Credentials returns valid credentials, refreshing them if required.
+func (s *Server) Credentials(ctx context.Context) (aws.Credentials, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+
+	if s.creds == nil && s.opts.Cache != nil {
+		cached, err := s.opts.Cache.GetCredentials(s.opts.CacheKey)
+		if err != nil {
+			clio.Debugw("error loading cached credentials", "error", err, "key", s.opts.CacheKey)
+		} else if !s.needsRefresh(cached, now) {
+			clio.Debugw("credentials found in cache", "expires", cached.Expires.String(), "canExpire", cached.CanExpire)
+			s.creds = cached
+		}
+	}
+
+	if !s.needsRefresh(s.creds, now) {
+		return *s.creds, nil
+	}
+
+	clio.Debugw("refreshing credentials", "key", s.opts.CacheKey)
+	creds, err := s.opts.Retrieve(ctx)
+	if err != nil {
+		return aws.Credentials{}, err
+	}
+	s.creds = &creds
+
+	if s.opts.Cache != nil {
+		if err := s.opts.Cache.StoreCredentials(s.opts.CacheKey, creds); err != nil {
+			clio.Debugw("error caching credentials", "error", err, "key", s.opts.CacheKey)
+		}
+	}
+
+	if creds.CanExpire {
+		clio.Infof("refreshed credentials, expiring at %s", creds.Expires.Local().Format(time.RFC3339))
+	}
+
+	return creds, nil
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeJSON(w, http.StatusMethodNotAllowed, errorResponse{Code: "MethodNotAllowed", Message: "only GET requests are supported"})
+		return
+	}
+
+	if subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), []byte(s.opts.AuthToken)) != 1 {
+		clio.Warnf("rejected credential request from %s: invalid authorization token", r.RemoteAddr)
+		writeJSON(w, http.StatusUnauthorized, errorResponse{Code: "Unauthorized", Message: "invalid authorization token"})
+		return
+	}
+
+	creds, err := s.Credentials(r.Context())
+	if err != nil {
+		clio.Errorf("error retrieving credentials: %s", err.Error())
+		writeJSON(w, http.StatusInternalServerError, errorResponse{Code: "CredentialsError", Message: err.Error()})
+		return
+	}
+
+	out := containerCredentials{
+		AccessKeyID:     creds.AccessKeyID,
+		SecretAccessKey: creds.SecretAccessKey,
+		Token:           creds.SessionToken,
+	}
+	if creds.CanExpire {
+		out.Expiration = creds.Expires.UTC().Format(time.RFC3339)
+	}
+	writeJSON(w, http.StatusOK, out)
+}
+
+// Listen opens a listener on the loopback interface. If port is 0 a random free port is used.
+// The AWS SDKs only allow plain HTTP container credential endpoints on loopback addresses.
+func Listen(port int) (net.Listener, error) {
+	return net.Listen("tcp", fmt.Sprintf("127.0.0.1:%d", port))
+}
+
+// URL returns the value to use for AWS_CONTAINER_CREDENTIALS_FULL_URI for a listener.
+func URL(l net.Listener) string {
+	return fmt.Sprintf("http://%s/", l.Addr().String())
+}
+
+// Serve serves credentials on the listener until the context is cancelled.
+func (s *Server) Serve(ctx context.Context, l net.Listener) error {
+	srv := &http.Server{
+		Handler:           s,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_ = srv.Shutdown(shutdownCtx)
+	}()
+
+	err := srv.Serve(l)
+	if errors.Is(err, http.ErrServerClosed) {
+		return nil
+	}
+	return err
+}
+
+// EnvVars returns the environment variables which configure AWS SDKs to use the server.
+func EnvVars(url string, authToken string) []string {
+	return []string{
+		"AWS_CONTAINER_CREDENTIALS_FULL_URI=" + url,
+		"AWS_CONTAINER_AUTHORIZATION_TOKEN=" + authToken,
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(body)
+}
@@ -0,0 +1,67 @@
+package credentialserver
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServeHTTP(t *testing.T) {
+	var calls int
+	srv, err := New(Opts{
+		AuthToken:     "secret",
+		RefreshWindow: 5 * time.Minute,
+		Retrieve: func(ctx context.Context) (aws.Credentials, error) {
+			calls++
+			// the first credentials expire within the refresh window, so should be refreshed on the next call
+			expires := time.Now().Add(time.Minute)
+			if calls > 1 {
+				expires = time.Now().Add(time.Hour)
+			}
+			return aws.Credentials{AccessKeyID: "AKIA", SecretAccessKey: "secret", SessionToken: "token", CanExpire: true, Expires: expires}, nil
+		},
+	})
+	require.NoError(t, err)
+
+	tests := []struct {
+		name       string
+		auth       string
+		wantStatus int
+		wantCalls  int
+	}{
+		{name: "missing token", auth: "", wantStatus: http.StatusUnauthorized, wantCalls: 0},
+		{name: "wrong token", auth: "other", wantStatus: http.StatusUnauthorized, wantCalls: 0},
+		{name: "ok", auth: "secret", wantStatus: http.StatusOK, wantCalls: 1},
+		{name: "refreshes near expiry", auth: "secret", wantStatus: http.StatusOK, wantCalls: 2},
+		{name: "uses cached credentials", auth: "secret", wantStatus: http.StatusOK, wantCalls: 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.auth != "" {
+				req.Header.Set("Authorization", tt.auth)
+			}
+			rec := httptest.NewRecorder()
+			srv.ServeHTTP(rec, req)
+
+			assert.Equal(t, tt.wantStatus, rec.Code)
+			assert.Equal(t, tt.wantCalls, calls)
+
+			if tt.wantStatus == http.StatusOK {
+				var got containerCredentials
+				err := json.Unmarshal(rec.Body.Bytes(), &got)
+				require.NoError(t, err)
+				assert.Equal(t, "AKIA", got.AccessKeyID)
+				assert.Equal(t, "token", got.Token)
+				assert.NotEmpty(t, got.Expiration)
+			}
+		})
+	}
+}
@@ -0,0 +1,68 @@
+// Package filelock provides advisory locks which are shared between Granted processes.
+package filelock
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/common-fate/clio"
+	gconfig "github.com/common-fate/granted/pkg/config"
+)
+
+// ErrLocked is returned if another process holds a lock which couldn't be taken before the timeout.
+var ErrLocked = errors.New("locked by another process")
+
+// FileLock is an advisory lock held on a file in the 'locks' folder of the Granted state folder.
+// Locks are only respected by other Granted processes.
+type FileLock struct {
+	f *os.File
+}
+
+// Lock takes the named lock, waiting up to timeout for other Granted processes to release it.
+func Lock(name string, timeout time.Duration) (*FileLock, error) {
+	stateFolder, err := gconfig.GrantedStateFolder()
+	if err != nil {
+		return nil, err
+	}
+
+	dir := filepath.Join(stateFolder, "locks")
+	err = os.MkdirAll(dir, 0700)
+	if err != nil {
+		return nil, err
+	}
+
+	p := filepath.Join(dir, name+".lock")
+	f, err := os.OpenFile(p, os.O_CREATE|os.O_RDWR, 0600)
+	if err != nil {
+		return nil, err
+	}
+
+	deadline := time.Now().Add(timeout)
+	for waiting := false; ; waiting = true {
+		err = tryLockFile(f)
+		if err == nil {
+			return &FileLock{f: f}, nil
+		}
+		if !errors.Is(err, ErrLocked) || time.Now().After(deadline) {
+			_ = f.Close()
+			return nil, fmt.Errorf("acquiring lock %s: %w", p, err)
+		}
+		if !waiting {
+			clio.Debugf("waiting for another Granted process to release lock %s", p)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
+
+// Unlock releases the lock.
+func (l *FileLock) Unlock() error {
+	err := unlockFile(l.f)
+	closeErr := l.f.Close()
+	if err != nil {
+		return err
+	}
+	return closeErr
+}
@@ -0,0 +1,28 @@
+package filelock
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLock(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
+	lock, err := Lock("test", time.Second)
+	require.NoError(t, err)
+
+	_, err = Lock("test", 100*time.Millisecond)
+	assert.ErrorIs(t, err, ErrLocked)
+
+	// the lock is taken once it is released
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		_ = lock.Unlock()
+	}()
+	lock, err = Lock("test", time.Second)
+	require.NoError(t, err)
+	assert.NoError(t, lock.Unlock())
+}
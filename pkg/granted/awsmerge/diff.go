@@ -0,0 +1,195 @@
+package awsmerge
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/fatih/color"
+	"gopkg.in/ini.v1"
+)
+
+type ChangeType string
+
+const (
+	ChangeAdded   ChangeType = "added"
+	ChangeRemoved ChangeType = "removed"
+	ChangeRenamed ChangeType = "renamed"
+	ChangeUpdated ChangeType = "updated"
+)
+
+// KeyChange is a change to a single key in a profile. Old is empty if the key
+// was added, and New is empty if the key was removed.
+type KeyChange struct {
+	Key string
+	Old string
+	New string
+}
+
+// ProfileChange describes how a section of the AWS config file changes after a merge.
+type ProfileChange struct {
+	// Section is the name of the section after the merge, or the name before the merge if it was removed.
+	Section string
+	Type    ChangeType
+	// RenamedFrom is the name of the section before it was prefixed with the registry name.
+	RenamedFrom string
+	// Keys are the keys which changed. For renamed profiles, the keys are compared against the section before it was renamed.
+	Keys []KeyChange
+}
+
+// Diff returns the changes between two AWS config files, such as the config file before and
+// after merging profile registries into it. The registry names are used to detect profiles
+// which were renamed because they have been prefixed with the name of the registry.
+//
+// The markers around generated registry sections aren't included in the diff.
+func Diff(before *ini.File, after *ini.File, registryNames []string) []ProfileChange {
+	beforeSections := sectionsByName(before)
+	afterSections := sectionsByName(after)
+
+	var changes []ProfileChange
+	var added []string
+	removed := map[string]bool{}
+
+	for name := range beforeSections {
+		if _, ok := afterSections[name]; !ok {
+			removed[name] = true
+		}
+	}
+
+	for name, sec := range afterSections {
+		prev, ok := beforeSections[name]
+		if !ok {
+			added = append(added, name)
+			continue
+		}
+		keys := diffKeys(prev, sec)
+		if len(keys) > 0 {
+			changes = append(changes, ProfileChange{Section: name, Type: ChangeUpdated, Keys: keys})
+		}
+	}
+
+	for _, name := range added {
+		sec := afterSections[name]
+
+		if from := renamedFrom(name, registryNames); from != "" && removed[from] {
+			delete(removed, from)
+			changes = append(changes, ProfileChange{
+				Section:     name,
+				Type:        ChangeRenamed,
+				RenamedFrom: from,
+				Keys:        diffKeys(beforeSections[from], sec),
+			})
+			continue
+		}
+
+		changes = append(changes, ProfileChange{Section: name, Type: ChangeAdded, Keys: diffKeys(nil, sec)})
+	}
+
+	for name := range removed {
+		changes = append(changes, ProfileChange{Section: name, Type: ChangeRemoved, Keys: diffKeys(beforeSections[name], nil)})
+	}
+
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].Section < changes[j].Section
+	})
+
+	return changes
+}
+
+// sectionsByName returns the sections of the config file, skipping the default section and
+// the generated registry markers. If a section name is duplicated, the first section is used.
+func sectionsByName(f *ini.File) map[string]*ini.Section {
+	sections := map[string]*ini.Section{}
+	for _, sec := range f.Sections() {
+		name := sec.Name()
+		if name == ini.DefaultSection || strings.HasPrefix(name, "granted_registry_start ") || strings.HasPrefix(name, "granted_registry_end ") {
+			continue
+		}
+		if _, ok := sections[name]; !ok {
+			sections[name] = sec
+		}
+	}
+	return sections
+}
+
+// renamedFrom returns the original name of a profile section which has been prefixed with
+// a registry name, using the same format as appendNamespaceToDuplicateSections.
+func renamedFrom(section string, registryNames []string) string {
+	name, ok := strings.CutPrefix(section, "profile ")
+	if !ok {
+		return ""
+	}
+	for _, r := range registryNames {
+		if original, ok := strings.CutPrefix(name, r+"."); ok && original != "" {
+			return "profile " + original
+		}
+	}
+	return ""
+}
+
+// diffKeys compares the keys of two sections, either of which may be nil.
+func diffKeys(before *ini.Section, after *ini.Section) []KeyChange {
+	values := func(sec *ini.Section) map[string]string {
+		m := map[string]string{}
+		if sec == nil {
+			return m
+		}
+		for _, k := range sec.Keys() {
+			m[k.Name()] = k.Value()
+		}
+		return m
+	}
+
+	oldValues := values(before)
+	newValues := values(after)
+
+	var changes []KeyChange
+	for k, v := range newValues {
+		if prev, ok := oldValues[k]; !ok || prev != v {
+			changes = append(changes, KeyChange{Key: k, Old: oldValues[k], New: v})
+		}
+	}
+	for k, v := range oldValues {
+		if _, ok := newValues[k]; !ok {
+			changes = append(changes, KeyChange{Key: k, Old: v})
+		}
+	}
+
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].Key < changes[j].Key
+	})
+
+	return changes
+}
+
+// PrintDiff writes a human readable summary of the changes.
+func PrintDiff(w io.Writer, changes []ProfileChange) {
+	green := color.New(color.FgGreen).SprintfFunc()
+	red := color.New(color.FgRed).SprintfFunc()
+	yellow := color.New(color.FgYellow).SprintfFunc()
+
+	for _, c := range changes {
+		switch c.Type {
+		case ChangeAdded:
+			fmt.Fprintln(w, green("+ [%s]", c.Section))
+		case ChangeRemoved:
+			fmt.Fprintln(w, red("- [%s]", c.Section))
+		case ChangeRenamed:
+			fmt.Fprintln(w, yellow("~ [%s] -> [%s] (prefixed with the registry name)", c.RenamedFrom, c.Section))
+		case ChangeUpdated:
+			fmt.Fprintln(w, yellow("~ [%s]", c.Section))
+		}
+
+		for _, k := range c.Keys {
+			switch {
+			case k.Old == "" && c.Type != ChangeRemoved:
+				fmt.Fprintln(w, green("    + %s = %s", k.Key, k.New))
+			case k.New == "" && c.Type != ChangeAdded:
+				fmt.Fprintln(w, red("    - %s = %s", k.Key, k.Old))
+			default:
+				fmt.Fprintln(w, yellow("    ~ %s: %s -> %s", k.Key, k.Old, k.New))
+			}
+		}
+	}
+}
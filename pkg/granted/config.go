@@ -0,0 +1,90 @@
+package granted
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/AlecAivazis/survey/v2"
+	"github.com/common-fate/clio"
+	"github.com/common-fate/clio/clierr"
+	"github.com/common-fate/granted/pkg/cfaws"
+	"github.com/urfave/cli/v2"
+)
+
+var ConfigCommand = cli.Command{
+	Name:        "config",
+	Usage:       "Manage the AWS config and credentials files written by Granted",
+	Subcommands: []*cli.Command{&ConfigRestoreCommand},
+}
+
+var ConfigRestoreCommand = cli.Command{
+	Name:  "restore",
+	Usage: "Restore the AWS config or credentials file from a backup taken before Granted last wrote to it",
+	Flags: []cli.Flag{
+		&cli.StringFlag{Name: "file", Usage: "The file to restore: 'config' or 'credentials'. The credentials file is only backed up if the BackupAWSCredentialsFile setting is enabled", Value: "config"},
+		&cli.BoolFlag{Name: "list", Usage: "List the backups instead of restoring one"},
+		&cli.BoolFlag{Name: "latest", Usage: "Restore the most recent backup without prompting"},
+	},
+	Action: func(c *cli.Context) error {
+		var filename string
+		switch c.String("file") {
+		case "config":
+			filename = cfaws.GetAWSConfigPath()
+		case "credentials":
+			filename = cfaws.GetAWSCredentialsPath()
+			if !cfaws.BackupsEnabled(filename) {
+				return clierr.New("Backups of the AWS credentials file are disabled, as they keep a plaintext copy of the credentials in the file",
+					clierr.Infof("To back up the credentials file before Granted writes to it, run '%s settings set --setting BackupAWSCredentialsFile --value true'", c.App.Name))
+			}
+		default:
+			return fmt.Errorf("unsupported file '%s': must be 'config' or 'credentials'", c.String("file"))
+		}
+
+		backups, err := cfaws.ListBackups(filename)
+		if err != nil {
+			return err
+		}
+		if len(backups) == 0 {
+			return fmt.Errorf("there are no backups of %s", filename)
+		}
+
+		if c.Bool("list") {
+			tw := tabwriter.NewWriter(os.Stdout, 10, 1, 3, ' ', 0)
+			_, _ = fmt.Fprintln(tw, "CREATED AT\tPATH")
+			for _, b := range backups {
+				_, _ = fmt.Fprintf(tw, "%s\t%s\n", b.CreatedAt.Local().Format(time.RFC3339), b.Path)
+			}
+			return tw.Flush()
+		}
+
+		selected := backups[0]
+		if !c.Bool("latest") {
+			options := make([]string, len(backups))
+			for i, b := range backups {
+				options[i] = b.CreatedAt.Local().Format(time.RFC1123)
+			}
+
+			var i int
+			in := survey.Select{Message: fmt.Sprintf("Select a backup of %s to restore:", filename), Options: options}
+			err = survey.AskOne(&in, &i, survey.WithStdio(os.Stdin, os.Stderr, os.Stderr))
+			if err != nil {
+				return err
+			}
+			selected = backups[i]
+		}
+
+		err = cfaws.RestoreBackup(filename, selected)
+		if errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("the backup %s no longer exists", selected.Path)
+		}
+		if err != nil {
+			return err
+		}
+
+		clio.Successf("Restored %s from the backup taken at %s. The previous contents have been backed up, so this can be undone with '%s config restore --file %s'", filename, selected.CreatedAt.Local().Format(time.RFC1123), c.App.Name, c.String("file"))
+		return nil
+	},
+}
@@ -1,6 +1,8 @@
 package granted
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"time"
@@ -11,6 +13,7 @@ import (
 	"github.com/common-fate/clio"
 	"github.com/common-fate/granted/pkg/cfaws"
 	"github.com/common-fate/granted/pkg/config"
+	"github.com/common-fate/granted/pkg/filelock"
 	"github.com/common-fate/granted/pkg/securestorage"
 	"github.com/urfave/cli/v2"
 )
@@ -34,6 +37,7 @@ var CredentialProcess = cli.Command{
 		&cli.DurationFlag{Name: "window", Value: 15 * time.Minute},
 		&cli.BoolFlag{Name: "auto-login", Usage: "automatically open the configured browser to log in if needed"},
 		&cli.BoolFlag{Name: "no-cache", Usage: "Disables caching of session credentials and forces a refresh", EnvVars: []string{"GRANTED_NO_CACHE"}},
+		&cli.DurationFlag{Name: "lock-timeout", Usage: "How long to wait for another credential process refreshing credentials for the same profile, such as while you log in", Value: 5 * time.Minute},
 	},
 	Action: func(c *cli.Context) error {
 		cfg, err := config.Load()
@@ -46,21 +50,29 @@ var CredentialProcess = cli.Command{
 		secureSessionCredentialStorage := securestorage.NewSecureSessionCredentialStorage()
 		clio.Debugw("running credential process with config", "profile", profileName, "url", c.String("url"), "window", c.Duration("window"), "disableCredentialProcessCache", cfg.DisableCredentialProcessCache)
 
+		profiles, err := cfaws.LoadProfiles()
+		if err != nil {
+			return err
+		}
+
+		profile, err := profiles.LoadInitialisedProfile(c.Context, profileName)
+		if err != nil {
+			return err
+		}
+
+		duration := time.Hour
+		if profile.AWSConfig.RoleDurationSeconds != nil {
+			duration = *profile.AWSConfig.RoleDurationSeconds
+		}
+
+		configOpts := cfaws.ConfigOpts{Duration: duration, UsingCredentialProcess: true, CredentialProcessAutoLogin: autoLogin}
+		cacheKey := profile.SessionCacheKey(configOpts)
+
 		cliNoCache := c.Bool("no-cache")
 		useCache := !cfg.DisableCredentialProcessCache && !cliNoCache
 
 		if useCache {
-			// try and look up session credentials from the secure storage cache.
-			cachedCreds, err := secureSessionCredentialStorage.GetCredentials(profileName)
-			if err != nil {
-				clio.Debugw("error loading cached credentials", "error", err, "profile", profileName)
-			} else if cachedCreds == nil {
-				clio.Debugw("refreshing credentials", "reason", "cachedCreds was nil")
-			} else if cachedCreds.CanExpire && cachedCreds.Expires.Add(-c.Duration("window")).Before(time.Now()) {
-				clio.Debugw("refreshing credentials", "reason", "credentials are expired")
-			} else {
-				// if we get here, the cached session credentials are valid
-				clio.Debugw("credentials found in cache", "expires", cachedCreds.Expires.String(), "canExpire", cachedCreds.CanExpire, "timeNow", time.Now().String(), "refreshIfBeforeNow", cachedCreds.Expires.Add(-c.Duration("window")).String())
+			if cachedCreds := cachedCredentials(secureSessionCredentialStorage, cacheKey, c.Duration("window")); cachedCreds != nil {
 				return printCredentials(*cachedCreds)
 			}
 		}
@@ -69,34 +81,37 @@ var CredentialProcess = cli.Command{
 			clio.Debugw("refreshing credentials", "reason", "credential process cache is disabled via config")
 		}
 
-		// purge the credentials from the cache
-		err = secureSessionCredentialStorage.SecureStorage.Clear(profileName)
+		// Tools such as terraform may run many credential processes for the same profile at once.
+		// Only one of them refreshes the credentials, and the others wait and then use the cached credentials,
+		// so that a single AssumeRole call is made and at most one browser window is opened to log in.
+		lock, err := filelock.Lock(credentialProcessLockName(profileName), c.Duration("lock-timeout"))
 		if err != nil {
-			clio.Debugw("error clearing cached credentials", "error", err, "profile", profileName)
-		}
-
-		profiles, err := cfaws.LoadProfiles()
-		if err != nil {
-			return err
+			// refreshing without the lock is better than failing the AWS CLI call
+			clio.Debugw("refreshing credentials without holding the credential process lock", "error", err, "profile", profileName)
+		} else {
+			defer func() { _ = lock.Unlock() }()
+
+			if useCache {
+				// another credential process may have refreshed the credentials while we were waiting for the lock
+				if cachedCreds := cachedCredentials(secureSessionCredentialStorage, cacheKey, c.Duration("window")); cachedCreds != nil {
+					return printCredentials(*cachedCreds)
+				}
+			}
 		}
 
-		profile, err := profiles.LoadInitialisedProfile(c.Context, profileName)
+		// purge the credentials from the cache
+		err = secureSessionCredentialStorage.SecureStorage.Clear(cacheKey)
 		if err != nil {
-			return err
-		}
-
-		duration := time.Hour
-		if profile.AWSConfig.RoleDurationSeconds != nil {
-			duration = *profile.AWSConfig.RoleDurationSeconds
+			clio.Debugw("error clearing cached credentials", "error", err, "profile", profileName)
 		}
 
-		credentials, err := profile.AssumeTerminal(c.Context, cfaws.ConfigOpts{Duration: duration, UsingCredentialProcess: true, CredentialProcessAutoLogin: autoLogin})
+		credentials, err := profile.AssumeTerminal(c.Context, configOpts)
 		if err != nil {
 			return err
 		}
 		if !cfg.DisableCredentialProcessCache {
 			clio.Debugw("storing refreshed credentials in credential process cache", "expires", credentials.Expires.String(), "canExpire", credentials.CanExpire, "timeNow", time.Now().String())
-			if err := secureSessionCredentialStorage.StoreCredentials(profileName, credentials); err != nil {
+			if err := profile.CacheSessionCredentials(configOpts, credentials); err != nil {
 				return err
 			}
 		}
@@ -105,6 +120,34 @@ var CredentialProcess = cli.Command{
 	},
 }
 
+// cachedCredentials returns the cached session credentials under the key, or nil if they
+// aren't cached or expire within the window.
+func cachedCredentials(storage securestorage.SessionCredentialSecureStorage, key string, window time.Duration) *aws.Credentials {
+	cachedCreds, err := storage.GetCredentials(key)
+	if err != nil {
+		clio.Debugw("error loading cached credentials", "error", err, "key", key)
+		return nil
+	}
+	if cachedCreds == nil {
+		clio.Debugw("refreshing credentials", "reason", "cachedCreds was nil")
+		return nil
+	}
+	if cachedCreds.CanExpire && cachedCreds.Expires.Add(-window).Before(time.Now()) {
+		clio.Debugw("refreshing credentials", "reason", "credentials are expired")
+		return nil
+	}
+	// if we get here, the cached session credentials are valid
+	clio.Debugw("credentials found in cache", "expires", cachedCreds.Expires.String(), "canExpire", cachedCreds.CanExpire, "timeNow", time.Now().String(), "refreshIfBeforeNow", cachedCreds.Expires.Add(-window).String())
+	return cachedCreds
+}
+
+// credentialProcessLockName returns the name of the lock held while refreshing credentials for the profile.
+// Profile names are hashed, as they may contain characters which aren't valid in file names.
+func credentialProcessLockName(profileName string) string {
+	hash := sha256.Sum256([]byte(profileName))
+	return "credential-process-" + hex.EncodeToString(hash[:8])
+}
+
 func printCredentials(creds aws.Credentials) error {
 	out := awsCredsStdOut{
 		Version:         1,
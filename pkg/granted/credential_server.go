@@ -0,0 +1,111 @@
+package granted
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/common-fate/clio"
+	"github.com/common-fate/granted/pkg/cfaws"
+	"github.com/common-fate/granted/pkg/config"
+	"github.com/common-fate/granted/pkg/credentialserver"
+	"github.com/urfave/cli/v2"
+)
+
+var CredentialServerCommand = cli.Command{
+	Name:  "credential-server",
+	Usage: "Serve refreshing AWS session credentials on a local endpoint compatible with AWS_CONTAINER_CREDENTIALS_FULL_URI",
+	Flags: []cli.Flag{
+		&cli.StringFlag{Name: "profile", Required: true},
+		&cli.IntFlag{Name: "port", Usage: "The port to listen on, a random port is used if not set"},
+		&cli.StringFlag{Name: "auth-token", Usage: "The authorization token clients must provide, a random token is generated if not set", EnvVars: []string{"GRANTED_CREDENTIAL_SERVER_TOKEN"}},
+		&cli.DurationFlag{Name: "window", Usage: "Refresh credentials when they are due to expire within this window", Value: 5 * time.Minute},
+		&cli.DurationFlag{Name: "duration", Usage: "Set session duration for your assumed role"},
+		&cli.BoolFlag{Name: "auto-login", Usage: "automatically open the configured browser to log in if needed"},
+		&cli.BoolFlag{Name: "no-cache", Usage: "Disables caching of session credentials and forces a refresh", EnvVars: []string{"GRANTED_NO_CACHE"}},
+	},
+	Action: func(c *cli.Context) error {
+		cfg, err := config.Load()
+		if err != nil {
+			return err
+		}
+
+		profileName := c.String("profile")
+
+		profiles, err := cfaws.LoadProfiles()
+		if err != nil {
+			return err
+		}
+
+		profile, err := profiles.LoadInitialisedProfile(c.Context, profileName)
+		if err != nil {
+			return err
+		}
+
+		duration := time.Hour
+		if profile.AWSConfig.RoleDurationSeconds != nil {
+			duration = *profile.AWSConfig.RoleDurationSeconds
+		}
+		if c.IsSet("duration") {
+			duration = c.Duration("duration")
+		}
+
+		configOpts := cfaws.ConfigOpts{
+			Duration:                   duration,
+			UsingCredentialProcess:     true,
+			CredentialProcessAutoLogin: c.Bool("auto-login") || cfg.CredentialProcessAutoLogin,
+		}
+
+		authToken := c.String("auth-token")
+		if authToken == "" {
+			authToken, err = credentialserver.GenerateAuthToken()
+			if err != nil {
+				return err
+			}
+		}
+
+		opts := credentialserver.Opts{
+			Retrieve: func(ctx context.Context) (aws.Credentials, error) {
+				return profile.AssumeTerminal(ctx, configOpts)
+			},
+			AuthToken:     authToken,
+			RefreshWindow: c.Duration("window"),
+			CacheKey:      profile.SessionCacheKey(configOpts),
+		}
+
+		if !cfg.DisableCredentialProcessCache && !c.Bool("no-cache") {
+			opts.Cache = profile.SessionCache()
+		}
+
+		srv, err := credentialserver.New(opts)
+		if err != nil {
+			return err
+		}
+
+		// retrieve the credentials before starting to serve them, so that
+		// any interactive login happens now rather than on the first request.
+		_, err = srv.Credentials(c.Context)
+		if err != nil {
+			return err
+		}
+
+		l, err := credentialserver.Listen(c.Int("port"))
+		if err != nil {
+			return err
+		}
+
+		ctx, cancel := signal.NotifyContext(c.Context, os.Interrupt, syscall.SIGTERM)
+		defer cancel()
+
+		clio.Infof("Serving credentials for %s on %s, set the following environment variables to use them:", profileName, credentialserver.URL(l))
+		for _, env := range credentialserver.EnvVars(credentialserver.URL(l), authToken) {
+			fmt.Printf("export %s\n", env)
+		}
+
+		return srv.Serve(ctx, l)
+	},
+}
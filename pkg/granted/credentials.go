@@ -78,28 +78,22 @@ var AddCredentialsCommand = cli.Command{
 //	[profile my-profile]
 //	credential_process = granted credential-process --profile=my-profile
 func updateOrCreateProfileWithCredentialProcess(profileName string) error {
-	configPath := cfaws.GetAWSConfigPath()
-	configFile, err := ini.LoadSources(ini.LoadOptions{
+	return cfaws.UpdateFile(cfaws.GetAWSConfigPath(), ini.LoadOptions{
 		AllowNonUniqueSections:  false,
 		SkipUnrecognizableLines: false,
 		AllowNestedValues:       true,
-	}, configPath)
-	if err != nil {
-		return err
-	}
-	sectionName := "profile " + profileName
-	section, err := configFile.GetSection(sectionName)
-	if err != nil {
-		section, err = configFile.NewSection(sectionName)
+	}, func(configFile *ini.File) error {
+		sectionName := "profile " + profileName
+		section, err := configFile.GetSection(sectionName)
 		if err != nil {
-			return err
+			section, err = configFile.NewSection(sectionName)
+			if err != nil {
+				return err
+			}
 		}
-	}
-	_, err = section.NewKey("credential_process", fmt.Sprintf("%s credential-process --profile=%s", build.GrantedBinaryName(), profileName))
-	if err != nil {
+		_, err = section.NewKey("credential_process", fmt.Sprintf("%s credential-process --profile=%s", build.GrantedBinaryName(), profileName))
 		return err
-	}
-	return configFile.SaveTo(configPath)
+	})
 }
 
 func validateProfileForImport(ctx context.Context, profiles *cfaws.Profiles, profileName string, overwrite bool) error {
@@ -181,31 +175,32 @@ var ImportCredentialsCommand = cli.Command{
 			return err
 		}
 
-		// remove the profile from the credentials file
-		credentialsFilePath := cfaws.GetAWSCredentialsPath()
-		credentialsFile, err := ini.LoadSources(ini.LoadOptions{
+		configFile, err := cfaws.OpenLocked(cfaws.GetAWSConfigPath(), ini.LoadOptions{
 			AllowNonUniqueSections:  false,
 			SkipUnrecognizableLines: false,
 			AllowNestedValues:       true,
-		}, credentialsFilePath)
+		})
 		if err != nil {
 			return err
 		}
+		defer func() { _ = configFile.Close() }()
 
-		items, err := credentialsFile.GetSection(profileName)
+		// remove the profile from the credentials file
+		credentialsFile, err := cfaws.OpenLocked(cfaws.GetAWSCredentialsPath(), ini.LoadOptions{
+			AllowNonUniqueSections:  false,
+			SkipUnrecognizableLines: false,
+			AllowNestedValues:       true,
+		})
 		if err != nil {
 			return err
 		}
+		defer func() { _ = credentialsFile.Close() }()
 
-		configPath := cfaws.GetAWSConfigPath()
-		configFile, err := ini.LoadSources(ini.LoadOptions{
-			AllowNonUniqueSections:  false,
-			SkipUnrecognizableLines: false,
-			AllowNestedValues:       true,
-		}, configPath)
+		items, err := credentialsFile.File.GetSection(profileName)
 		if err != nil {
 			return err
 		}
+
 		sectionName := "profile " + profileName
 
 		// Merge options from the credentials profile to the config file profile.
@@ -213,7 +208,7 @@ var ImportCredentialsCommand = cli.Command{
 		for _, key := range items.Keys() {
 			// omit sensitive values from the merge
 			if key.Name() != "aws_access_key_id" && key.Name() != "aws_secret_access_key" && key.Name() != "aws_session_token" {
-				section, err := configFile.GetSection(sectionName)
+				section, err := configFile.File.GetSection(sectionName)
 				if err != nil {
 					return err
 				}
@@ -226,17 +221,23 @@ var ImportCredentialsCommand = cli.Command{
 			}
 		}
 		// save the updated config file after merging
-		err = configFile.SaveTo(configPath)
+		err = configFile.Save()
 		if err != nil {
 			return err
 		}
 
-		// remove the plaintext profile from the credentials file
-		credentialsFile.DeleteSection(profileName)
-		err = credentialsFile.SaveTo(credentialsFilePath)
+		// remove the plaintext profile from the credentials file. The credentials file isn't backed up,
+		// and existing backups are removed, as they would keep a plaintext copy of the credentials which are now in secure storage.
+		credentialsFile.File.DeleteSection(profileName)
+		credentialsFile.SkipBackup = true
+		err = credentialsFile.Save()
 		if err != nil {
 			return err
 		}
+		err = cfaws.RemoveBackups(credentialsFile.Filename)
+		if err != nil {
+			return fmt.Errorf("removing backups of %s: %w", credentialsFile.Filename, err)
+		}
 		fmt.Printf("Saved %s to secure storage\n", profileName)
 
 		return nil
@@ -334,15 +335,6 @@ var RemoveCredentialsCommand = cli.Command{
 	},
 	Action: func(c *cli.Context) error {
 		secureIAMCredentialStorage := securestorage.NewSecureIAMCredentialStorage()
-		configPath := cfaws.GetAWSConfigPath()
-		configFile, err := ini.LoadSources(ini.LoadOptions{
-			AllowNonUniqueSections:  false,
-			SkipUnrecognizableLines: false,
-			AllowNestedValues:       true,
-		}, configPath)
-		if err != nil {
-			return err
-		}
 		profileName := c.Args().First()
 		secureProfileKeys, err := secureIAMCredentialStorage.SecureStorage.ListKeys()
 		if err != nil {
@@ -385,6 +377,16 @@ If you have already used 'granted credentials export-plaintext <profile name>' t
 			return nil
 		}
 
+		configFile, err := cfaws.OpenLocked(cfaws.GetAWSConfigPath(), ini.LoadOptions{
+			AllowNonUniqueSections:  false,
+			SkipUnrecognizableLines: false,
+			AllowNestedValues:       true,
+		})
+		if err != nil {
+			return err
+		}
+		defer func() { _ = configFile.Close() }()
+
 		for _, profileName := range profileNames {
 			fmt.Printf("Removing %s credentials from secure storage\n", profileName)
 			err = secureIAMCredentialStorage.SecureStorage.Clear(profileName)
@@ -392,16 +394,16 @@ If you have already used 'granted credentials export-plaintext <profile name>' t
 				return err
 			}
 			sectionName := "profile " + profileName
-			if section, _ := configFile.GetSection(sectionName); section != nil {
+			if section, _ := configFile.File.GetSection(sectionName); section != nil {
 				if key, _ := section.GetKey("credential_process"); key != nil {
 					if strings.HasPrefix(key.Value(), fmt.Sprintf("%s credential-process", build.GrantedBinaryName())) {
 						fmt.Printf("Removing profile %s AWS config file\n", profileName)
-						configFile.DeleteSection(sectionName)
+						configFile.File.DeleteSection(sectionName)
 					}
 				}
 			}
 		}
-		err = configFile.SaveTo(configPath)
+		err = configFile.Save()
 		if err != nil {
 			return err
 		}
@@ -448,64 +450,53 @@ var ExportCredentialsCommand = cli.Command{
 			if err != nil {
 				return err
 			}
-			// fetch parsed credentials file
 			credentialsFilePath := cfaws.GetAWSCredentialsPath()
-			credentialsFile, err := ini.LoadSources(ini.LoadOptions{
+			err = cfaws.UpdateFile(credentialsFilePath, ini.LoadOptions{
 				AllowNonUniqueSections:  false,
 				SkipUnrecognizableLines: false,
 				AllowNestedValues:       true,
-			}, credentialsFilePath)
-			if err != nil {
-				return err
-			}
-
-			section, err := credentialsFile.NewSection(profileName)
-			if err != nil {
-				return err
-			}
-			err = section.ReflectFrom(&struct {
-				AWSAccessKeyID     string `ini:"aws_access_key_id"`
-				AWSSecretAccessKey string `ini:"aws_secret_access_key"`
-				AWSSessionToken    string `ini:"aws_session_token,omitempty"`
-			}{
-				AWSAccessKeyID:     credentials.AccessKeyID,
-				AWSSecretAccessKey: credentials.SecretAccessKey,
-				AWSSessionToken:    credentials.SessionToken,
+			}, func(credentialsFile *ini.File) error {
+				section, err := credentialsFile.NewSection(profileName)
+				if err != nil {
+					return err
+				}
+				return section.ReflectFrom(&struct {
+					AWSAccessKeyID     string `ini:"aws_access_key_id"`
+					AWSSecretAccessKey string `ini:"aws_secret_access_key"`
+					AWSSessionToken    string `ini:"aws_session_token,omitempty"`
+				}{
+					AWSAccessKeyID:     credentials.AccessKeyID,
+					AWSSecretAccessKey: credentials.SecretAccessKey,
+					AWSSessionToken:    credentials.SessionToken,
+				})
 			})
 			if err != nil {
 				return err
 			}
-			err = credentialsFile.SaveTo(credentialsFilePath)
-			if err != nil {
-				return err
-			}
-			configPath := cfaws.GetAWSConfigPath()
-			configFile, err := ini.LoadSources(ini.LoadOptions{
+
+			err = cfaws.UpdateFile(cfaws.GetAWSConfigPath(), ini.LoadOptions{
 				AllowNonUniqueSections:  false,
 				SkipUnrecognizableLines: false,
 				AllowNestedValues:       true,
-			}, configPath)
-			if err != nil {
-				return err
-			}
-			sectionName := "profile " + profileName
-			if section, _ := configFile.GetSection(sectionName); section != nil {
-				if section.HasKey("credential_process") {
-					// if the result of removing the credential process is that the profile has no configuration, then just remove it completely.
-					// the profile in the credential file will suffice
-					// else just remove the credential process line.
-					// this avoids leaving the config file with an empty profile, which appears to be some kind of error when its not
-					if len(section.Keys()) > 1 {
-						section.DeleteKey("credential_process")
-					} else {
-						configFile.DeleteSection(sectionName)
-					}
-					err = configFile.SaveTo(configPath)
-					if err != nil {
-						return err
+			}, func(configFile *ini.File) error {
+				sectionName := "profile " + profileName
+				if section, _ := configFile.GetSection(sectionName); section != nil {
+					if section.HasKey("credential_process") {
+						// if the result of removing the credential process is that the profile has no configuration, then just remove it completely.
+						// the profile in the credential file will suffice
+						// else just remove the credential process line.
+						// this avoids leaving the config file with an empty profile, which appears to be some kind of error when its not
+						if len(section.Keys()) > 1 {
+							section.DeleteKey("credential_process")
+						} else {
+							configFile.DeleteSection(sectionName)
+						}
 					}
-
 				}
+				return nil
+			})
+			if err != nil {
+				return err
 			}
 
 			fmt.Printf("Exported %s in plaintext from secure storage to %s\n", profileName, credentialsFilePath)
@@ -593,6 +584,12 @@ var RotateCredentialsCommand = cli.Command{
 			}
 		}
 
+		// backups of the credentials file may contain the previous access key
+		err = cfaws.RemoveBackups(cfaws.GetAWSCredentialsPath())
+		if err != nil {
+			return fmt.Errorf("removing backups of the AWS credentials file: %w", err)
+		}
+
 		clio.Successf("Access Key of '%s' profile has been successfully rotated and updated in secure storage\n", profileName)
 
 		return nil
@@ -634,35 +631,26 @@ var ImportCredFromEnvCommand = cli.Command{
 				return err
 			}
 
-			// fetch parsed credentials file
-			credentialsFilePath := cfaws.GetAWSCredentialsPath()
-			credentialsFile, err := ini.LoadSources(ini.LoadOptions{
+			err = cfaws.UpdateFile(cfaws.GetAWSCredentialsPath(), ini.LoadOptions{
 				AllowNonUniqueSections:  false,
 				SkipUnrecognizableLines: false,
 				AllowNestedValues:       true,
-			}, credentialsFilePath)
-			if err != nil {
-				return err
-			}
-
-			section, err := credentialsFile.NewSection(profileName)
-			if err != nil {
-				return err
-			}
-			err = section.ReflectFrom(&struct {
-				AWSAccessKeyID     string `ini:"aws_access_key_id"`
-				AWSSecretAccessKey string `ini:"aws_secret_access_key"`
-			}{
-				AWSAccessKeyID:     accessKeyFromEnv,
-				AWSSecretAccessKey: secretAccessKeyFromEnv,
+			}, func(credentialsFile *ini.File) error {
+				section, err := credentialsFile.NewSection(profileName)
+				if err != nil {
+					return err
+				}
+				return section.ReflectFrom(&struct {
+					AWSAccessKeyID     string `ini:"aws_access_key_id"`
+					AWSSecretAccessKey string `ini:"aws_secret_access_key"`
+				}{
+					AWSAccessKeyID:     accessKeyFromEnv,
+					AWSSecretAccessKey: secretAccessKeyFromEnv,
+				})
 			})
 			if err != nil {
 				return err
 			}
-			err = credentialsFile.SaveTo(credentialsFilePath)
-			if err != nil {
-				return err
-			}
 
 			err = updateOrCreateProfileWithCredentialProcess(profileName)
 			if err != nil {
@@ -9,12 +9,14 @@ import (
 	"github.com/common-fate/clio"
 	"github.com/common-fate/clio/cliolog"
 	"github.com/common-fate/granted/internal/build"
+	"github.com/common-fate/granted/pkg/autosync"
 	"github.com/common-fate/granted/pkg/chromemsg"
 	"github.com/common-fate/granted/pkg/config"
 	"github.com/common-fate/granted/pkg/granted/doctor"
 	"github.com/common-fate/granted/pkg/granted/middleware"
 	"github.com/common-fate/granted/pkg/granted/registry"
 	"github.com/common-fate/granted/pkg/granted/settings"
+	"github.com/common-fate/granted/pkg/idclogin"
 	"github.com/urfave/cli/v2"
 	"go.uber.org/zap"
 )
@@ -27,6 +29,7 @@ func GetCliApp() *cli.App {
 	flags := []cli.Flag{
 		&cli.BoolFlag{Name: "verbose", Usage: "Log debug messages"},
 		&cli.StringFlag{Name: "aws-config-file"},
+		&cli.BoolFlag{Name: "no-browser", Usage: "Print the login URL for IAM Identity Center rather than opening a browser. Can also be set using GRANTED_HEADLESS=true"},
 	}
 
 	app := &cli.App{
@@ -46,10 +49,14 @@ func GetCliApp() *cli.App {
 			&SSOCommand,
 			&CredentialsCommand,
 			middleware.WithBeforeFuncs(&CredentialProcess, middleware.WithAutosync()),
+			&CredentialServerCommand,
+			&ExecCommand,
 			&registry.ProfileRegistryCommand,
 			&ConsoleCommand,
 			&CacheCommand,
+			&ConfigCommand,
 			&doctor.Command,
+			&autosync.BackgroundSyncCommand,
 		},
 		// Granted may be invoked via our browser extension, which uses the Native Messaging
 		// protocol to communicate with the Granted CLI. If invoked this way, the browser calls
@@ -70,6 +77,10 @@ func GetCliApp() *cli.App {
 			if c.String("aws-config-file") != "" {
 				_ = os.Setenv("AWS_CONFIG_FILE", c.String("aws-config-file"))
 			}
+			if c.Bool("no-browser") {
+				// set for the login flow, and any credential process invoked by the AWS SDK
+				_ = os.Setenv(idclogin.EnvHeadless, "true")
+			}
 			clio.SetLevelFromEnv("GRANTED_LOG")
 
 			grantedFolder, err := config.GrantedStateFolder()
@@ -0,0 +1,137 @@
+package granted
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/common-fate/clio"
+	"github.com/common-fate/granted/pkg/assume"
+	"github.com/common-fate/granted/pkg/cfaws"
+	"github.com/common-fate/granted/pkg/config"
+	"github.com/common-fate/granted/pkg/credentialserver"
+	"github.com/urfave/cli/v2"
+)
+
+var ExecCommand = cli.Command{
+	Name:      "exec",
+	Usage:     "Run a command with credentials for a profile",
+	UsageText: "granted exec --profile <profile> [--refresh] -- <command> [args...]",
+	Flags: []cli.Flag{
+		&cli.StringFlag{Name: "profile", Required: true},
+		&cli.StringFlag{Name: "region", Aliases: []string{"r"}, Usage: "The region to set for the command, defaults to the profile region"},
+		&cli.DurationFlag{Name: "duration", Aliases: []string{"d"}, Usage: "Set session duration for your assumed role"},
+		&cli.BoolFlag{Name: "refresh", Usage: "Serve credentials to the command from a local credential endpoint, so that they are refreshed before they expire. Use this for long-running commands"},
+		&cli.DurationFlag{Name: "window", Usage: "When using --refresh, refresh credentials when they are due to expire within this window", Value: 5 * time.Minute},
+		&cli.BoolFlag{Name: "no-cache", Usage: "Disables caching of session credentials and forces a refresh", EnvVars: []string{"GRANTED_NO_CACHE"}},
+	},
+	Action: func(c *cli.Context) error {
+		if c.Args().Len() == 0 {
+			return errors.New("a command to run must be provided, for example: 'granted exec --profile my-profile -- aws sts get-caller-identity'")
+		}
+
+		cfg, err := config.Load()
+		if err != nil {
+			return err
+		}
+
+		profileName := c.String("profile")
+
+		profiles, err := cfaws.LoadProfiles()
+		if err != nil {
+			return err
+		}
+
+		profile, err := profiles.LoadInitialisedProfile(c.Context, profileName)
+		if err != nil {
+			return err
+		}
+
+		region := c.String("region")
+		if region != "" {
+			region, err = cfaws.ExpandRegion(region)
+			if err != nil {
+				return fmt.Errorf("couldn't parse region %s: %v", c.String("region"), err)
+			}
+		} else {
+			region, err = profile.Region(c.Context)
+			if err != nil {
+				return err
+			}
+		}
+
+		duration := time.Hour
+		if profile.AWSConfig.RoleDurationSeconds != nil {
+			duration = *profile.AWSConfig.RoleDurationSeconds
+		}
+		if c.IsSet("duration") {
+			duration = c.Duration("duration")
+		}
+
+		configOpts := cfaws.ConfigOpts{
+			Duration:     duration,
+			DisableCache: c.Bool("no-cache"),
+		}
+
+		authToken, err := credentialserver.GenerateAuthToken()
+		if err != nil {
+			return err
+		}
+
+		opts := credentialserver.Opts{
+			Retrieve: func(ctx context.Context) (aws.Credentials, error) {
+				return profile.AssumeTerminal(ctx, configOpts)
+			},
+			AuthToken:     authToken,
+			RefreshWindow: c.Duration("window"),
+			CacheKey:      profile.SessionCacheKey(configOpts),
+		}
+
+		if !cfg.DisableCredentialProcessCache && !c.Bool("no-cache") {
+			opts.Cache = profile.SessionCache()
+		}
+
+		srv, err := credentialserver.New(opts)
+		if err != nil {
+			return err
+		}
+
+		creds, err := srv.Credentials(c.Context)
+		if err != nil {
+			return err
+		}
+
+		env := assume.ExecEnv(assume.EnvKeys(creds, region)...)
+
+		if c.Bool("refresh") {
+			l, err := credentialserver.Listen(0)
+			if err != nil {
+				return err
+			}
+
+			ctx, cancel := context.WithCancel(c.Context)
+			defer cancel()
+
+			go func() {
+				err := srv.Serve(ctx, l)
+				if err != nil {
+					clio.Errorf("error serving credentials: %s", err.Error())
+				}
+			}()
+
+			clio.Debugw("serving credentials to command", "url", credentialserver.URL(l))
+			env = assume.ExecEnv(append(credentialserver.EnvVars(credentialserver.URL(l), authToken), "AWS_REGION="+region)...)
+		}
+
+		code, err := assume.RunSupervised(env, c.Args().First(), c.Args().Tail()...)
+		if err != nil {
+			return err
+		}
+		if code != 0 {
+			return cli.Exit("", code)
+		}
+		return nil
+	},
+}
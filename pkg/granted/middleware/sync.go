@@ -7,7 +7,7 @@ import (
 
 func WithAutosync() cli.BeforeFunc {
 	return func(c *cli.Context) error {
-		autosync.Run(c.Context, false)
+		autosync.Run(c.Context, autosync.Opts{Interactive: false})
 		return nil
 	}
 }
@@ -4,12 +4,9 @@ import (
 	"errors"
 	"fmt"
 
-	"github.com/AlecAivazis/survey/v2"
 	"github.com/common-fate/clio"
 	grantedConfig "github.com/common-fate/granted/pkg/config"
 	"github.com/common-fate/granted/pkg/granted/awsmerge"
-	"github.com/common-fate/granted/pkg/granted/registry/gitregistry"
-	"github.com/common-fate/granted/pkg/testable"
 
 	"github.com/urfave/cli/v2"
 )
@@ -25,16 +22,21 @@ var AddCommand = cli.Command{
 	Usage:       "Provide git repository you want to sync with aws config file",
 	Flags: []cli.Flag{
 		&cli.StringFlag{Name: "name", Required: true, Usage: "A unique name for the profile registry", Aliases: []string{"n"}},
-		&cli.StringFlag{Name: "url", Required: true, Usage: "The URL for the registry", Aliases: []string{"u"}},
-		&cli.StringFlag{Name: "path", Usage: "For git registries: provide path if only the subfolder needs to be synced", Aliases: []string{"p"}},
-		&cli.StringFlag{Name: "filename", Aliases: []string{"f"}, Usage: "For git registries:  provide filename if yml file is not granted.yml", DefaultText: "granted.yml"},
+		&cli.StringFlag{Name: "url", Required: true, Usage: "The URL for the registry. For file registries, the local directory containing the registry", Aliases: []string{"u"}},
+		&cli.StringFlag{Name: "path", Usage: "Provide path if only the subfolder needs to be synced", Aliases: []string{"p"}},
+		&cli.StringFlag{Name: "filename", Aliases: []string{"f"}, Usage: "Provide filename if yml file is not granted.yml", DefaultText: "granted.yml"},
 		&cli.IntFlag{Name: "priority", Usage: "The priority for the profile registry", Value: 0},
 		&cli.StringFlag{Name: "ref", Usage: "Git ref (branch, tag, or commit) to checkout"},
 		&cli.BoolFlag{Name: "prefix-all-profiles", Aliases: []string{"pap"}, Usage: "Provide this flag if you want to append registry name to all profiles"},
 		&cli.BoolFlag{Name: "prefix-duplicate-profiles", Aliases: []string{"pdp"}, Usage: "Provide this flag if you want to append registry name to duplicate profiles"},
 		&cli.BoolFlag{Name: "write-on-sync-failure", Aliases: []string{"wosf"}, Usage: "Always overwrite AWS config, even if sync fails (DEPRECATED)"},
 		&cli.StringSliceFlag{Name: "required-key", Aliases: []string{"r", "requiredKey"}, Usage: "Used to bypass the prompt or override user specific values"},
-		&cli.StringFlag{Name: "type", Value: "git", Usage: "specify the type of granted registry source you want to set up. Default: git"}},
+		&cli.StringSliceFlag{Name: "trusted-key", Usage: "An SSH or minisign public key which must sign the registry manifest. Can be provided multiple times"},
+		&cli.StringSliceFlag{Name: "allow-key", Usage: "Only allow profiles from the registry to contain these keys. Glob patterns such as 'granted_*' are supported. Can be provided multiple times"},
+		&cli.StringSliceFlag{Name: "deny-key", Usage: "Quarantine profiles from the registry which contain these keys, such as 'ca_bundle' or 'endpoint_url'. Can be provided multiple times"},
+		&cli.BoolFlag{Name: "granted-credential-process-only", Usage: "Quarantine profiles from the registry with a credential_process other than 'granted credential-process --profile <profile name>'"},
+		&cli.DurationFlag{Name: "sync-interval", Usage: "How often the registry is automatically synced, for example '15m'", DefaultText: "24h"},
+		&cli.StringFlag{Name: "type", Value: "git", Usage: "specify the type of granted registry source you want to set up: 'git', 'https' or 'file'. Default: git"}},
 
 	ArgsUsage: "--name <registry_name> --url <repository_url> --type <registry_type>",
 	Action: func(c *cli.Context) error {
@@ -61,11 +63,7 @@ var AddCommand = cli.Command{
 		registryType := c.String("type")
 
 		if registryType == "http" {
-			return fmt.Errorf("HTTP registries are not longer supported in this version of Granted: if you are impacted by this please raise an issue: https://github.com/common-fate/granted/issues/new")
-		}
-
-		if registryType != "git" {
-			return fmt.Errorf("invalid registry type provided: %s. must be 'git'", c.String("type"))
+			return fmt.Errorf("HTTP registries are not longer supported in this version of Granted: use '--type %s' to fetch a registry from an https:// URL", TypeHTTPS)
 		}
 
 		for _, r := range gConf.ProfileRegistry.Registries {
@@ -86,71 +84,61 @@ var AddCommand = cli.Command{
 			PrefixDuplicateProfiles: prefixDuplicateProfiles,
 			PrefixAllProfiles:       prefixAllProfiles,
 			Type:                    registryType,
+			TrustedKeys:             c.StringSlice("trusted-key"),
 		}
 
-		registry, err := gitregistry.New(gitregistry.Opts{
-			Name:         name,
-			URL:          URL,
-			Path:         pathFlag,
-			Filename:     configFileName,
-			Ref:          ref,
-			RequiredKeys: requiredKey,
-			Interactive:  true,
-		})
+		if c.IsSet("sync-interval") {
+			registryConfig.SyncInterval = c.Duration("sync-interval").String()
+		}
 
-		if err != nil {
-			return err
+		if c.IsSet("allow-key") || c.IsSet("deny-key") || c.Bool("granted-credential-process-only") {
+			registryConfig.Policy = &grantedConfig.RegistryPolicy{
+				AllowKeys:                    c.StringSlice("allow-key"),
+				DenyKeys:                     c.StringSlice("deny-key"),
+				GrantedCredentialProcessOnly: c.Bool("granted-credential-process-only"),
+			}
 		}
-		src, err := registry.AWSProfiles(ctx, true)
+
+		registry, err := newRegistry(registryConfig, requiredKey, true)
 		if err != nil {
 			return err
 		}
-
-		dst, filepath, err := loadAWSConfigFile()
+		src, err := registry.AWSProfiles(ctx, true)
 		if err != nil {
 			return err
 		}
 
-		merged, err := awsmerge.WithRegistry(src, dst, awsmerge.RegistryOpts{
+		opts := awsmerge.RegistryOpts{
 			Name:                    name,
 			PrefixAllProfiles:       prefixAllProfiles,
 			PrefixDuplicateProfiles: prefixDuplicateProfiles,
-		})
-		var dpe awsmerge.DuplicateProfileError
-		if errors.As(err, &dpe) {
-			clio.Warnf(err.Error())
-
-			const (
-				DUPLICATE = "Add registry name as prefix to all duplicate profiles for this registry"
-				ABORT     = "Abort, I will manually fix this"
-			)
-
-			options := []string{DUPLICATE, ABORT}
-
-			in := survey.Select{Message: "Please select which option would you like to choose to resolve: ", Options: options}
-			var selected string
-			err = testable.AskOne(&in, &selected)
-			if err != nil {
-				return err
-			}
+		}
 
-			if selected == ABORT {
-				return fmt.Errorf("aborting sync for registry %s", name)
-			}
+		// check for duplicate profiles before locking the AWS config file, so that it isn't locked while we prompt
+		configFile, err := readAWSConfigFile()
+		if err != nil {
+			return err
+		}
+		_, err = resolveDuplicateProfiles(src, configFile, &opts, true)
+		if err != nil {
+			return err
+		}
+		registryConfig.PrefixDuplicateProfiles = opts.PrefixDuplicateProfiles
 
-			registryConfig.PrefixDuplicateProfiles = true
+		awsConfig, err := loadAWSConfigFile()
+		if err != nil {
+			return err
+		}
+		defer func() { _ = awsConfig.Close() }()
 
-			// try and merge again
-			merged, err = awsmerge.WithRegistry(src, dst, awsmerge.RegistryOpts{
-				Name:                    name,
-				PrefixAllProfiles:       prefixAllProfiles,
-				PrefixDuplicateProfiles: true,
-			})
-			if err != nil {
-				return fmt.Errorf("error after trying to merge profiles again: %w", err)
-			}
+		merged, err := awsmerge.WithRegistry(src, awsConfig.File, opts)
+		if err != nil {
+			return err
 		}
 
+		violations := awsmerge.ApplyPolicy(merged, name, registryConfig.Policy)
+		warnPolicyViolations(name, violations)
+
 		// we have verified that this registry is a valid one and sync is completed.
 		// so save the new registry to config file.
 		gConf.ProfileRegistry.Registries = append(gConf.ProfileRegistry.Registries, registryConfig)
@@ -159,11 +147,17 @@ var AddCommand = cli.Command{
 			return err
 		}
 
-		err = merged.SaveTo(filepath)
+		awsConfig.File = merged
+		err = awsConfig.Save()
+		if err != nil {
+			return err
+		}
+
+		err = writeQuarantine(name, violations)
 		if err != nil {
 			return err
 		}
 
-		return nil
+		return recordSync([]string{name})
 	},
-}
\ No newline at end of file
+}
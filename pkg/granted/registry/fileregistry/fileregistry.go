@@ -0,0 +1,140 @@
+// Package fileregistry implements a profile registry which is read from a local directory,
+// for example a directory synced by a configuration management tool or a network share.
+package fileregistry
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/common-fate/clio"
+	"github.com/common-fate/granted/pkg/granted/registry/gitregistry"
+	"github.com/common-fate/granted/pkg/granted/registry/manifest"
+	"gopkg.in/ini.v1"
+)
+
+type Registry struct {
+	opts Opts
+	// dir is the directory containing the 'granted.yml' file.
+	dir string
+}
+
+type Opts struct {
+	Name string
+	// Directory is the local directory containing the registry. A 'file://' prefix is allowed.
+	Directory    string
+	Path         string
+	Filename     string
+	RequiredKeys []string
+	Interactive  bool
+	// TrustedKeys, if set, require the registry files to match a manifest signed by one of the keys.
+	TrustedKeys []string
+}
+
+func New(opts Opts) (*Registry, error) {
+	dir, err := expandDirectory(opts.Directory)
+	if err != nil {
+		return nil, err
+	}
+
+	if opts.Path != "" {
+		dir = filepath.Join(dir, opts.Path)
+	}
+
+	p := Registry{
+		opts: opts,
+		dir:  dir,
+	}
+
+	return &p, nil
+}
+
+// expandDirectory strips a 'file://' prefix from the directory and expands a leading '~' to the user's home directory.
+func expandDirectory(dir string) (string, error) {
+	dir = strings.TrimPrefix(dir, "file://")
+	if dir == "~" || strings.HasPrefix(dir, "~/") {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", err
+		}
+		dir = filepath.Join(home, strings.TrimPrefix(dir, "~"))
+	}
+	return dir, nil
+}
+
+func (r Registry) AWSProfiles(ctx context.Context, interactive bool) (*ini.File, error) {
+	fileName := "granted.yml"
+	if r.opts.Filename != "" {
+		fileName = r.opts.Filename
+	}
+
+	var m *manifest.Manifest
+	if len(r.opts.TrustedKeys) > 0 {
+		var err error
+		m, err = manifest.Load(r.read, r.opts.TrustedKeys)
+		if err != nil {
+			return nil, fmt.Errorf("verifying registry %s: %w", r.opts.Name, err)
+		}
+	}
+
+	file, err := r.read(fileName)
+	if err != nil {
+		return nil, err
+	}
+
+	if m != nil {
+		err = m.Verify(fileName, file)
+		if err != nil {
+			return nil, fmt.Errorf("verifying registry %s: %w", r.opts.Name, err)
+		}
+	}
+
+	cfg, err := gitregistry.ParseConfigYAML(file)
+	if err != nil {
+		return nil, err
+	}
+
+	err = cfg.PromptRequiredKeys(r.opts.RequiredKeys, r.opts.Interactive, r.opts.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	// load all configs of the registry into one ini object.
+	// this will overwrite if there are duplicate profiles with same name.
+	result := ini.Empty()
+
+	for _, cfile := range cfg.AwsConfigPaths {
+		data, err := r.read(cfile)
+		if err != nil {
+			return nil, err
+		}
+
+		if m != nil {
+			err = m.Verify(cfile, data)
+			if err != nil {
+				return nil, fmt.Errorf("verifying registry %s: %w", r.opts.Name, err)
+			}
+		}
+
+		err = result.Append(data)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing %s: %w", cfile, err)
+		}
+	}
+
+	err = cfg.FilterProfiles(result)
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// read reads a file from the directory containing the 'granted.yml' file.
+func (r Registry) read(name string) ([]byte, error) {
+	p := filepath.Join(r.dir, name)
+	clio.Debugf("reading registry file %s", p)
+	return os.ReadFile(p)
+}
@@ -15,33 +15,40 @@ import (
 type ConfigYAML struct {
 	AwsConfigPaths []string                         `yaml:"awsConfig"`
 	TemplateValues []map[string][]map[string]string `yaml:"templateValues"`
+	// Variables are typed template variables, which replace TemplateValues.
+	Variables map[string]Variable `yaml:"variables"`
+	// ProfileConditions include or exclude profiles based on the values of variables.
+	ProfileConditions []ProfileCondition `yaml:"profileConditions"`
 }
 
-// parseGrantedYAML unmarshals a 'granted.yml' file contained in the git profile registry.
-func (p Registry) parseGrantedYAML() (*ConfigYAML, error) {
-	fileName := "granted.yml"
-
+// grantedYAMLFilename returns the name of the 'granted.yml' file in the registry.
+func (p Registry) grantedYAMLFilename() string {
 	if p.opts.Filename != "" {
-		fileName = p.opts.Filename
+		return p.opts.Filename
 	}
+	return "granted.yml"
+}
 
+// read reads a file from the directory containing the 'granted.yml' file in the cloned repo.
+func (p Registry) read(name string) ([]byte, error) {
 	dirPath := p.clonedTo
 
 	if p.opts.Path != "" {
 		dirPath = path.Join(dirPath, p.opts.Path)
 	}
 
-	filepath := path.Join(dirPath, fileName)
+	filepath := path.Join(dirPath, name)
 
-	clio.Debugf("verifying if valid config exists in %s", filepath)
-	file, err := os.ReadFile(filepath)
-	if err != nil {
-		return nil, err
-	}
+	clio.Debugf("reading registry file %s", filepath)
+	return os.ReadFile(filepath)
+}
 
+// ParseConfigYAML parses the contents of a 'granted.yml' file. It is shared by the
+// other registry types, which use the same 'granted.yml' format as git registries.
+func ParseConfigYAML(file []byte) (*ConfigYAML, error) {
 	var cfg ConfigYAML
 
-	err = yaml.Unmarshal(file, &cfg)
+	err := yaml.Unmarshal(file, &cfg)
 	if err != nil {
 		return nil, err
 	}
@@ -164,7 +171,16 @@ func (c ConfigYAML) PromptRequiredKeys(passedKeys []string, interactive bool, re
 		}
 	}
 
-	return nil
+	passedValues := make(map[string]string)
+	for _, val := range passedKeys {
+		key, value, err := formatKey(val)
+		if err != nil {
+			return err
+		}
+		passedValues[key] = value
+	}
+
+	return c.promptVariables(gConf, passedValues, interactive, registryName)
 }
 
 // This is used when user enters the required key through cli prompts.
@@ -2,10 +2,11 @@ package gitregistry
 
 import (
 	"context"
+	"fmt"
 	"path"
 
-	"github.com/common-fate/clio"
 	grantedConfig "github.com/common-fate/granted/pkg/config"
+	"github.com/common-fate/granted/pkg/granted/registry/manifest"
 	"gopkg.in/ini.v1"
 )
 
@@ -23,6 +24,8 @@ type Opts struct {
 	Ref          string
 	RequiredKeys []string
 	Interactive  bool
+	// TrustedKeys, if set, require the registry files to match a manifest signed by one of the keys.
+	TrustedKeys []string
 }
 
 func New(opts Opts) (*Registry, error) {
@@ -47,7 +50,28 @@ func (r Registry) AWSProfiles(ctx context.Context, interactive bool) (*ini.File,
 		return nil, err
 	}
 
-	cfg, err := r.parseGrantedYAML()
+	var m *manifest.Manifest
+	if len(r.opts.TrustedKeys) > 0 {
+		m, err = manifest.Load(r.read, r.opts.TrustedKeys)
+		if err != nil {
+			return nil, fmt.Errorf("verifying registry %s: %w", r.opts.Name, err)
+		}
+	}
+
+	fileName := r.grantedYAMLFilename()
+	file, err := r.read(fileName)
+	if err != nil {
+		return nil, err
+	}
+
+	if m != nil {
+		err = m.Verify(fileName, file)
+		if err != nil {
+			return nil, fmt.Errorf("verifying registry %s: %w", r.opts.Name, err)
+		}
+	}
+
+	cfg, err := ParseConfigYAML(file)
 	if err != nil {
 		return nil, err
 	}
@@ -62,19 +86,28 @@ func (r Registry) AWSProfiles(ctx context.Context, interactive bool) (*ini.File,
 	result := ini.Empty()
 
 	for _, cfile := range cfg.AwsConfigPaths {
-		var filepath string
-		if r.opts.Path != "" {
-			filepath = path.Join(r.clonedTo, r.opts.Path, cfile)
-		} else {
-			filepath = path.Join(r.clonedTo, cfile)
+		data, err := r.read(cfile)
+		if err != nil {
+			return nil, err
+		}
+
+		if m != nil {
+			err = m.Verify(cfile, data)
+			if err != nil {
+				return nil, fmt.Errorf("verifying registry %s: %w", r.opts.Name, err)
+			}
 		}
 
-		clio.Debugf("loading aws config file from %s", filepath)
-		err := result.Append(filepath)
+		err = result.Append(data)
 		if err != nil {
-			return nil, err
+			return nil, fmt.Errorf("error parsing %s: %w", cfile, err)
 		}
 	}
 
+	err = cfg.FilterProfiles(result)
+	if err != nil {
+		return nil, err
+	}
+
 	return result, nil
 }
@@ -0,0 +1,275 @@
+package gitregistry
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/AlecAivazis/survey/v2"
+	"github.com/common-fate/clio"
+	grantedConfig "github.com/common-fate/granted/pkg/config"
+	"github.com/common-fate/granted/pkg/granted/awsmerge"
+	"gopkg.in/ini.v1"
+)
+
+type VariableType string
+
+const (
+	VariableTypeString VariableType = "string"
+	VariableTypeInt    VariableType = "int"
+	VariableTypeBool   VariableType = "bool"
+)
+
+// Variable is a typed template variable declared in the 'variables' section of 'granted.yml':
+//
+//	variables:
+//	  team:
+//	    description: The team you are a member of
+//	    required: true
+//	    choices: [platform, data]
+//	  region:
+//	    default: us-east-1
+//	    regex: ^[a-z]{2}-[a-z]+-[0-9]$
+//
+// Values are available in profile templates as '{{ .Vars.team }}'.
+type Variable struct {
+	// Type is 'string', 'int' or 'bool'. Defaults to 'string'.
+	Type        VariableType `yaml:"type"`
+	Description string       `yaml:"description"`
+	// Default is used if the user hasn't provided a value.
+	Default string `yaml:"default"`
+	// Required variables without a default must be provided by the user before the registry is synced.
+	Required bool `yaml:"required"`
+	// Regex, if set, must match the value.
+	Regex string `yaml:"regex"`
+	// Choices, if set, are the only allowed values.
+	Choices []string `yaml:"choices"`
+}
+
+// Validate returns an error if the value isn't valid for the variable.
+func (v Variable) Validate(value string) error {
+	switch v.Type {
+	case VariableTypeString, "":
+	case VariableTypeInt:
+		if _, err := strconv.Atoi(value); err != nil {
+			return fmt.Errorf("'%s' is not an integer", value)
+		}
+	case VariableTypeBool:
+		if _, err := strconv.ParseBool(value); err != nil {
+			return fmt.Errorf("'%s' is not true or false", value)
+		}
+	default:
+		return fmt.Errorf("unsupported variable type '%s': must be one of '%s', '%s' or '%s'", v.Type, VariableTypeString, VariableTypeInt, VariableTypeBool)
+	}
+
+	if v.Regex != "" {
+		re, err := regexp.Compile(v.Regex)
+		if err != nil {
+			return fmt.Errorf("invalid regex '%s': %w", v.Regex, err)
+		}
+		if !re.MatchString(value) {
+			return fmt.Errorf("'%s' does not match the regex '%s'", value, v.Regex)
+		}
+	}
+
+	if len(v.Choices) > 0 && !slices.Contains(v.Choices, value) {
+		return fmt.Errorf("'%s' must be one of: %s", value, strings.Join(v.Choices, ", "))
+	}
+
+	return nil
+}
+
+// normalize converts boolean values such as 'yes' or '1' to 'true' or 'false', so that they can be compared in templates.
+func (v Variable) normalize(value string) string {
+	if v.Type == VariableTypeBool {
+		if b, err := strconv.ParseBool(value); err == nil {
+			return strconv.FormatBool(b)
+		}
+	}
+	return value
+}
+
+// ProfileCondition includes or excludes profiles from the registry based on the values of variables:
+//
+//	profileConditions:
+//	  - profiles: ["platform-*"]
+//	    includeWhen:
+//	      team: [platform]
+type ProfileCondition struct {
+	// Profiles are glob patterns matching the names of the profiles the condition applies to.
+	Profiles []string `yaml:"profiles"`
+	// IncludeWhen includes the profiles only if every variable listed has one of the listed values.
+	IncludeWhen map[string][]string `yaml:"includeWhen"`
+	// ExcludeWhen excludes the profiles if any variable listed has one of the listed values.
+	ExcludeWhen map[string][]string `yaml:"excludeWhen"`
+}
+
+func (pc ProfileCondition) matches(profileName string) bool {
+	for _, p := range pc.Profiles {
+		if ok, _ := path.Match(p, profileName); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// included returns whether profiles matching the condition should be included.
+func (pc ProfileCondition) included(values map[string]string) bool {
+	for name, allowed := range pc.IncludeWhen {
+		if !slices.Contains(allowed, values[name]) {
+			return false
+		}
+	}
+	for name, denied := range pc.ExcludeWhen {
+		if slices.Contains(denied, values[name]) {
+			return false
+		}
+	}
+	return true
+}
+
+// promptVariables ensures that each typed variable has a valid value. Values passed through flags
+// are saved, and values for required variables are prompted for if missing or invalid.
+// Defaults are saved as registry variables, so that they are available to templates.
+func (c ConfigYAML) promptVariables(gConf *grantedConfig.Config, passedKeys map[string]string, interactive bool, registryName string) error {
+	names := make([]string, 0, len(c.Variables))
+	for name := range c.Variables {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		v := c.Variables[name]
+
+		if value, ok := passedKeys[name]; ok {
+			if err := v.Validate(value); err != nil {
+				return fmt.Errorf("invalid value for variable '%s': %w", name, err)
+			}
+			err := SaveKey(gConf, name, v.normalize(value))
+			if err != nil {
+				return err
+			}
+			continue
+		}
+
+		value, ok := gConf.ProfileRegistry.RequiredKeys[name]
+		if ok {
+			err := v.Validate(value)
+			if err == nil {
+				continue
+			}
+			clio.Warnf("The value for variable '%s' is no longer valid: %s", name, err.Error())
+		}
+
+		if !ok && v.Default != "" {
+			if err := v.Validate(v.Default); err != nil {
+				return fmt.Errorf("invalid default for variable '%s' in registry '%s': %w", name, registryName, err)
+			}
+			if gConf.ProfileRegistry.Variables == nil {
+				gConf.ProfileRegistry.Variables = make(map[string]string)
+			}
+			gConf.ProfileRegistry.Variables[name] = v.normalize(v.Default)
+			err := gConf.Save()
+			if err != nil {
+				return err
+			}
+			continue
+		}
+
+		if !ok && !v.Required {
+			continue
+		}
+
+		// see the comment in PromptRequiredKeys on why we can't prompt when called non-interactively.
+		if !interactive {
+			clio.Errorf("Error syncing registry '%s'. You need to enter a valid value for variable: '%s' before you can proceed.", registryName, name)
+			clio.Errorf("run 'granted registry sync' to enter a value for the variable")
+
+			return fmt.Errorf("sync failed")
+		}
+
+		answer, err := promptVariable(name, v)
+		if err != nil {
+			return err
+		}
+
+		err = SaveKey(gConf, name, v.normalize(answer))
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func promptVariable(name string, v Variable) (string, error) {
+	withStdio := survey.WithStdio(os.Stdin, os.Stderr, os.Stderr)
+	message := fmt.Sprintf("'%s':", name)
+	if v.Description != "" {
+		message = fmt.Sprintf("'%s': %s", name, v.Description)
+	}
+
+	var prompt survey.Prompt
+	switch {
+	case len(v.Choices) > 0:
+		prompt = &survey.Select{Message: message, Options: v.Choices}
+	case v.Type == VariableTypeBool:
+		var answer bool
+		err := survey.AskOne(&survey.Confirm{Message: message}, &answer, withStdio)
+		return strconv.FormatBool(answer), err
+	default:
+		prompt = &survey.Input{Message: message}
+	}
+
+	var answer string
+	err := survey.AskOne(prompt, &answer, withStdio, survey.WithValidator(func(ans interface{}) error {
+		s, ok := ans.(string)
+		if !ok {
+			// survey.Select passes an OptionAnswer, which is always one of the choices
+			return nil
+		}
+		if s == "" {
+			return errors.New("a value is required")
+		}
+		return v.Validate(s)
+	}))
+	return answer, err
+}
+
+// FilterProfiles removes profiles which are excluded by the registry's profile conditions from the config file.
+func (c ConfigYAML) FilterProfiles(f *ini.File) error {
+	if len(c.ProfileConditions) == 0 {
+		return nil
+	}
+
+	gConf, err := grantedConfig.Load()
+	if err != nil {
+		return err
+	}
+
+	c.filterProfiles(f, awsmerge.TemplateValues(gConf))
+	return nil
+}
+
+func (c ConfigYAML) filterProfiles(f *ini.File, values map[string]string) {
+	for _, sec := range f.Sections() {
+		profileName, ok := strings.CutPrefix(sec.Name(), "profile ")
+		if !ok {
+			continue
+		}
+
+		for _, pc := range c.ProfileConditions {
+			if pc.matches(profileName) && !pc.included(values) {
+				clio.Debugf("excluding profile %s based on the registry profile conditions", profileName)
+				f.DeleteSection(sec.Name())
+				break
+			}
+		}
+	}
+}
@@ -0,0 +1,78 @@
+package gitregistry
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/ini.v1"
+)
+
+func TestVariableValidate(t *testing.T) {
+	tests := []struct {
+		name     string
+		variable Variable
+		value    string
+		wantErr  bool
+	}{
+		{name: "string", variable: Variable{}, value: "anything"},
+		{name: "int", variable: Variable{Type: VariableTypeInt}, value: "42"},
+		{name: "invalid int", variable: Variable{Type: VariableTypeInt}, value: "forty-two", wantErr: true},
+		{name: "bool", variable: Variable{Type: VariableTypeBool}, value: "true"},
+		{name: "invalid bool", variable: Variable{Type: VariableTypeBool}, value: "maybe", wantErr: true},
+		{name: "regex", variable: Variable{Regex: `^[a-z]{2}-[a-z]+-[0-9]$`}, value: "us-east-1"},
+		{name: "regex mismatch", variable: Variable{Regex: `^[a-z]{2}-[a-z]+-[0-9]$`}, value: "US", wantErr: true},
+		{name: "choice", variable: Variable{Choices: []string{"platform", "data"}}, value: "data"},
+		{name: "invalid choice", variable: Variable{Choices: []string{"platform", "data"}}, value: "sales", wantErr: true},
+		{name: "unsupported type", variable: Variable{Type: "float"}, value: "1.0", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.variable.Validate(tt.value)
+			if tt.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestFilterProfiles(t *testing.T) {
+	cfg, err := ParseConfigYAML([]byte(`
+awsConfig:
+  - config
+variables:
+  team:
+    required: true
+    choices: [platform, data]
+profileConditions:
+  - profiles: ["platform-*"]
+    includeWhen:
+      team: [platform]
+  - profiles: ["sandbox"]
+    excludeWhen:
+      team: [data]
+`))
+	require.NoError(t, err)
+	assert.True(t, cfg.Variables["team"].Required)
+
+	load := func() *ini.File {
+		f, err := ini.Load([]byte(`
+[profile platform-prod]
+[profile platform-dev]
+[profile sandbox]
+[profile shared]
+`))
+		require.NoError(t, err)
+		return f
+	}
+
+	f := load()
+	cfg.filterProfiles(f, map[string]string{"team": "platform"})
+	assert.Equal(t, []string{ini.DefaultSection, "profile platform-prod", "profile platform-dev", "profile sandbox", "profile shared"}, f.SectionStrings())
+
+	f = load()
+	cfg.filterProfiles(f, map[string]string{"team": "data"})
+	assert.Equal(t, []string{ini.DefaultSection, "profile shared"}, f.SectionStrings())
+}
@@ -0,0 +1,321 @@
+// Package httpregistry implements a profile registry which is fetched over HTTPS.
+//
+// The 'granted.yml' file and the AWS config files it references are downloaded relative
+// to the registry URL, and cached locally along with their ETags. Subsequent syncs send
+// the ETag in an If-None-Match header, so unchanged files aren't downloaded again.
+package httpregistry
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/common-fate/clio"
+	grantedConfig "github.com/common-fate/granted/pkg/config"
+	"github.com/common-fate/granted/pkg/granted/registry/gitregistry"
+	"github.com/common-fate/granted/pkg/granted/registry/manifest"
+	"gopkg.in/ini.v1"
+)
+
+// DefaultClient is the HTTP client used to fetch registries.
+var DefaultClient = &http.Client{Timeout: 30 * time.Second}
+
+type Registry struct {
+	opts Opts
+	// base is the URL of the directory containing the 'granted.yml' file.
+	base *url.URL
+	// cacheDir is the directory that fetched files and their ETags are cached in.
+	cacheDir string
+}
+
+type Opts struct {
+	Name         string
+	URL          string
+	Path         string
+	Filename     string
+	RequiredKeys []string
+	Interactive  bool
+	// TrustedKeys, if set, require the registry files to match a manifest signed by one of the keys.
+	TrustedKeys []string
+	// Client is the HTTP client used to fetch the registry, DefaultClient is used if nil.
+	Client *http.Client
+}
+
+func New(opts Opts) (*Registry, error) {
+	base, err := url.Parse(opts.URL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid registry URL %s: %w", opts.URL, err)
+	}
+
+	// plain HTTP is only allowed for loopback addresses, such as a local development server
+	if base.Scheme != "https" && !(base.Scheme == "http" && isLoopback(base.Hostname())) {
+		return nil, fmt.Errorf("invalid registry URL %s: https registries must use an https:// URL", opts.URL)
+	}
+
+	if opts.Path != "" {
+		base = base.JoinPath(opts.Path)
+	}
+	// ensure that files are resolved relative to the directory, rather than replacing the last path segment
+	if !strings.HasSuffix(base.Path, "/") {
+		base.Path += "/"
+	}
+
+	if opts.Client == nil {
+		opts.Client = DefaultClient
+	}
+
+	gConfigPath, err := grantedConfig.GrantedConfigFolder()
+	if err != nil {
+		return nil, err
+	}
+
+	p := Registry{
+		opts:     opts,
+		base:     base,
+		cacheDir: path.Join(gConfigPath, "registries", opts.Name),
+	}
+
+	return &p, nil
+}
+
+func isLoopback(host string) bool {
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+func (r Registry) AWSProfiles(ctx context.Context, interactive bool) (*ini.File, error) {
+	fileName := "granted.yml"
+	if r.opts.Filename != "" {
+		fileName = r.opts.Filename
+	}
+
+	c, err := r.loadCache()
+	if err != nil {
+		return nil, err
+	}
+
+	read := func(name string) ([]byte, error) {
+		return r.fetch(ctx, c, name)
+	}
+
+	var m *manifest.Manifest
+	if len(r.opts.TrustedKeys) > 0 {
+		m, err = manifest.Load(read, r.opts.TrustedKeys)
+		if err != nil {
+			return nil, fmt.Errorf("verifying registry %s: %w", r.opts.Name, err)
+		}
+	}
+
+	file, err := read(fileName)
+	if err != nil {
+		return nil, err
+	}
+
+	if m != nil {
+		err = m.Verify(fileName, file)
+		if err != nil {
+			return nil, fmt.Errorf("verifying registry %s: %w", r.opts.Name, err)
+		}
+	}
+
+	cfg, err := gitregistry.ParseConfigYAML(file)
+	if err != nil {
+		return nil, err
+	}
+
+	err = cfg.PromptRequiredKeys(r.opts.RequiredKeys, r.opts.Interactive, r.opts.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	// load all configs of the registry into one ini object.
+	// this will overwrite if there are duplicate profiles with same name.
+	result := ini.Empty()
+
+	for _, cfile := range cfg.AwsConfigPaths {
+		data, err := read(cfile)
+		if err != nil {
+			return nil, err
+		}
+
+		if m != nil {
+			err = m.Verify(cfile, data)
+			if err != nil {
+				return nil, fmt.Errorf("verifying registry %s: %w", r.opts.Name, err)
+			}
+		}
+
+		err = result.Append(data)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing %s: %w", cfile, err)
+		}
+	}
+
+	err = r.saveCache(c)
+	if err != nil {
+		return nil, err
+	}
+
+	err = cfg.FilterProfiles(result)
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// Delete the local cache of the registry.
+func (r Registry) Delete() error {
+	return os.RemoveAll(r.cacheDir)
+}
+
+// resolve returns the URL of a file in the registry. Files must be hosted
+// alongside the registry, so absolute URLs to other hosts are rejected.
+func (r Registry) resolve(name string) (*url.URL, error) {
+	ref, err := url.Parse(name)
+	if err != nil {
+		return nil, err
+	}
+
+	u := r.base.ResolveReference(ref)
+	if u.Scheme != r.base.Scheme || u.Host != r.base.Host {
+		return nil, fmt.Errorf("registry file %s must be hosted on %s", name, r.base.Host)
+	}
+
+	return u, nil
+}
+
+// cache holds the ETag of each cached file, keyed by URL.
+type cache struct {
+	ETags map[string]string `json:"etags"`
+}
+
+func (r Registry) cacheIndexPath() string {
+	return path.Join(r.cacheDir, "etags.json")
+}
+
+// cacheFilePath returns the path a file fetched from the URL is cached at.
+func (r Registry) cacheFilePath(u string) string {
+	sum := sha256.Sum256([]byte(u))
+	return path.Join(r.cacheDir, "files", hex.EncodeToString(sum[:]))
+}
+
+func (r Registry) loadCache() (*cache, error) {
+	c := cache{ETags: map[string]string{}}
+
+	b, err := os.ReadFile(r.cacheIndexPath())
+	if errors.Is(err, os.ErrNotExist) {
+		return &c, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	err = json.Unmarshal(b, &c)
+	if err != nil {
+		// the cache will be rebuilt by downloading each file again
+		clio.Debugw("ignoring invalid registry cache", "registry", r.opts.Name, "error", err)
+		return &cache{ETags: map[string]string{}}, nil
+	}
+	if c.ETags == nil {
+		c.ETags = map[string]string{}
+	}
+	return &c, nil
+}
+
+func (r Registry) saveCache(c *cache) error {
+	b, err := json.Marshal(c)
+	if err != nil {
+		return err
+	}
+	err = os.MkdirAll(r.cacheDir, 0700)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(r.cacheIndexPath(), b, 0600)
+}
+
+// fetch returns the contents of a file in the registry. If the file hasn't changed since it
+// was cached, the cached contents are returned. If the registry can't be reached, the cached
+// contents are returned with a warning.
+func (r Registry) fetch(ctx context.Context, c *cache, name string) ([]byte, error) {
+	u, err := r.resolve(name)
+	if err != nil {
+		return nil, err
+	}
+	key := u.String()
+	cachePath := r.cacheFilePath(key)
+
+	cached, cacheErr := os.ReadFile(cachePath)
+	hasCache := cacheErr == nil
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, key, nil)
+	if err != nil {
+		return nil, err
+	}
+	if etag := c.ETags[key]; etag != "" && hasCache {
+		req.Header.Set("If-None-Match", etag)
+	}
+
+	clio.Debugf("fetching registry file %s", key)
+	res, err := r.opts.Client.Do(req)
+	if err != nil {
+		if hasCache {
+			clio.Warnf("Unable to fetch %s for registry %s, using the cached copy: %s", key, r.opts.Name, err.Error())
+			return cached, nil
+		}
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	switch res.StatusCode {
+	case http.StatusNotModified:
+		if !hasCache {
+			return nil, fmt.Errorf("received a 304 Not Modified response for %s, but it isn't cached", key)
+		}
+		clio.Debugf("registry file %s is unchanged", key)
+		return cached, nil
+	case http.StatusOK:
+	case http.StatusNotFound:
+		return nil, fmt.Errorf("error fetching %s: %w", key, fs.ErrNotExist)
+	default:
+		return nil, fmt.Errorf("error fetching %s: unexpected status %s", key, res.Status)
+	}
+
+	data, err := io.ReadAll(res.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	err = os.MkdirAll(path.Dir(cachePath), 0700)
+	if err != nil {
+		return nil, err
+	}
+	err = os.WriteFile(cachePath, data, 0600)
+	if err != nil {
+		return nil, err
+	}
+
+	if etag := res.Header.Get("ETag"); etag != "" {
+		c.ETags[key] = etag
+	} else {
+		delete(c.ETags, key)
+	}
+
+	return data, nil
+}
@@ -0,0 +1,74 @@
+package httpregistry
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAWSProfilesETagCaching(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("XDG_CONFIG_HOME", "")
+
+	files := map[string]string{
+		"/registry/granted.yml": "awsConfig:\n  - ./config\n",
+		"/registry/config":      "[profile dev]\nregion = us-east-1\n",
+	}
+	downloads := map[string]int{}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, ok := files[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		etag := `"` + r.URL.Path + `"`
+		w.Header().Set("ETag", etag)
+		if r.Header.Get("If-None-Match") == etag {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		downloads[r.URL.Path]++
+		_, _ = w.Write([]byte(body))
+	}))
+	defer server.Close()
+
+	reg, err := New(Opts{Name: "test", URL: server.URL + "/registry", Client: server.Client()})
+	require.NoError(t, err)
+
+	for i := 0; i < 2; i++ {
+		profiles, err := reg.AWSProfiles(context.Background(), false)
+		require.NoError(t, err)
+		assert.Equal(t, "us-east-1", profiles.Section("profile dev").Key("region").String())
+	}
+
+	// the second sync should use the cached files
+	assert.Equal(t, map[string]int{"/registry/granted.yml": 1, "/registry/config": 1}, downloads)
+
+	// if the registry is unreachable, the cached files are used
+	server.Close()
+	profiles, err := reg.AWSProfiles(context.Background(), false)
+	require.NoError(t, err)
+	assert.True(t, profiles.HasSection("profile dev"))
+}
+
+func TestNewRequiresHTTPS(t *testing.T) {
+	_, err := New(Opts{Name: "test", URL: "http://example.com/registry"})
+	assert.Error(t, err)
+}
+
+func TestResolveRejectsOtherHosts(t *testing.T) {
+	reg, err := New(Opts{Name: "test", URL: "https://example.com/registry"})
+	require.NoError(t, err)
+
+	u, err := reg.resolve("profiles/config")
+	require.NoError(t, err)
+	assert.Equal(t, "https://example.com/registry/profiles/config", u.String())
+
+	_, err = reg.resolve("https://attacker.example.com/config")
+	assert.Error(t, err)
+}
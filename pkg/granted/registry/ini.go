@@ -7,6 +7,7 @@ import (
 	"path/filepath"
 
 	"github.com/common-fate/clio"
+	"github.com/common-fate/granted/pkg/cfaws"
 	"gopkg.in/ini.v1"
 )
 
@@ -21,11 +22,38 @@ func getDefaultAWSConfigLocation() (string, error) {
 	return configPath, nil
 }
 
-// loadAWSConfigFile loads the `~/.aws/config` file, and creates it if it doesn't exist.
-func loadAWSConfigFile() (*ini.File, string, error) {
+// awsConfigLoadOptions are the options used to load the AWS config file when merging profile registries.
+var awsConfigLoadOptions = ini.LoadOptions{
+	SkipUnrecognizableLines: true,
+	AllowNonUniqueSections:  true,
+	AllowNestedValues:       true,
+}
+
+// loadAWSConfigFile locks and loads the `~/.aws/config` file, and creates it if it doesn't exist.
+// The returned file must be closed to release the lock.
+func loadAWSConfigFile() (*cfaws.LockedFile, error) {
+	filepath, err := createAWSConfigFile()
+	if err != nil {
+		return nil, err
+	}
+	return cfaws.OpenLocked(filepath, awsConfigLoadOptions)
+}
+
+// readAWSConfigFile loads the `~/.aws/config` file without locking it, and creates it if it doesn't exist.
+// It is used to preview a merge before prompting, and changes to it must not be saved.
+func readAWSConfigFile() (*ini.File, error) {
+	filepath, err := createAWSConfigFile()
+	if err != nil {
+		return nil, err
+	}
+	return ini.LoadSources(awsConfigLoadOptions, filepath)
+}
+
+// createAWSConfigFile creates the `~/.aws/config` file if it doesn't exist, and returns its path.
+func createAWSConfigFile() (string, error) {
 	filepath, err := getDefaultAWSConfigLocation()
 	if err != nil {
-		return nil, "", err
+		return "", err
 	}
 
 	if _, err := os.Stat(filepath); os.IsNotExist(err) {
@@ -34,31 +62,14 @@ func loadAWSConfigFile() (*ini.File, string, error) {
 		// create all parent directory if necessary.
 		err := os.MkdirAll(path.Dir(filepath), USER_READ_WRITE_PERM)
 		if err != nil {
-			return nil, "", err
+			return "", err
 		}
 
 		_, err = os.Create(filepath)
 		if err != nil {
-			return nil, "", fmt.Errorf("unable to create AWS config file: %w", err)
+			return "", fmt.Errorf("unable to create AWS config file: %w", err)
 		}
 	}
 
-	awsConfig, err := loadAWSConfigFileFromPath(filepath)
-	if err != nil {
-		return nil, "", err
-	}
-	return awsConfig, filepath, nil
-}
-
-func loadAWSConfigFileFromPath(filepath string) (*ini.File, error) {
-	awsConfig, err := ini.LoadSources(ini.LoadOptions{
-		SkipUnrecognizableLines: true,
-		AllowNonUniqueSections:  true,
-		AllowNestedValues:       true,
-	}, filepath)
-	if err != nil {
-		return nil, err
-	}
-
-	return awsConfig, nil
+	return filepath, nil
 }
@@ -0,0 +1,180 @@
+// Package manifest verifies signed profile registry manifests.
+//
+// Profile registries can add 'credential_process' entries to the AWS config file, so a
+// registry with trusted keys configured must contain a signed manifest listing the SHA256
+// hash of 'granted.yml' and every AWS config file it references. The manifest uses the
+// format produced by sha256sum:
+//
+//	sha256sum granted.yml config > granted.manifest
+//
+// The manifest must then be signed with either an SSH key, creating 'granted.manifest.sig':
+//
+//	ssh-keygen -Y sign -n granted -f ~/.ssh/id_ed25519 granted.manifest
+//
+// or with minisign, creating 'granted.manifest.minisig':
+//
+//	minisign -Sm granted.manifest
+package manifest
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"strings"
+
+	"github.com/jedisct1/go-minisign"
+)
+
+const (
+	// Filename is the name of the manifest file in the registry.
+	Filename = "granted.manifest"
+	// SSHSignatureFilename is the name of an SSH signature over the manifest, created by 'ssh-keygen -Y sign'.
+	SSHSignatureFilename = Filename + ".sig"
+	// MinisignSignatureFilename is the name of a minisign signature over the manifest, created by 'minisign -S'.
+	MinisignSignatureFilename = Filename + ".minisig"
+	// SSHNamespace is the namespace that SSH signatures must be created with.
+	SSHNamespace = "granted"
+)
+
+// ReadFunc reads a file from the registry, relative to the directory containing 'granted.yml'.
+// It must return an error wrapping fs.ErrNotExist if the file doesn't exist.
+type ReadFunc func(name string) ([]byte, error)
+
+// Manifest is a verified registry manifest.
+type Manifest struct {
+	// hashes are the hex-encoded SHA256 hashes of each file, keyed by the cleaned file path.
+	hashes map[string]string
+}
+
+// Load reads the manifest and its signature from the registry, and verifies the
+// signature against the trusted keys. Trusted keys are either SSH public keys in
+// authorized_keys format, or minisign public keys.
+func Load(read ReadFunc, trustedKeys []string) (*Manifest, error) {
+	if len(trustedKeys) == 0 {
+		return nil, errors.New("no trusted keys are configured")
+	}
+
+	data, err := read(Filename)
+	if err != nil {
+		return nil, fmt.Errorf("the registry does not contain a readable %s file: %w", Filename, err)
+	}
+
+	var verifyErrs []error
+
+	sshSig, err := read(SSHSignatureFilename)
+	if err == nil {
+		err = verifySSH(data, sshSig, trustedKeys)
+		if err == nil {
+			return parse(data)
+		}
+		verifyErrs = append(verifyErrs, fmt.Errorf("%s: %w", SSHSignatureFilename, err))
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+
+	minisig, err := read(MinisignSignatureFilename)
+	if err == nil {
+		err = verifyMinisign(data, minisig, trustedKeys)
+		if err == nil {
+			return parse(data)
+		}
+		verifyErrs = append(verifyErrs, fmt.Errorf("%s: %w", MinisignSignatureFilename, err))
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+
+	if len(verifyErrs) == 0 {
+		return nil, fmt.Errorf("the registry does not contain a %s or %s signature over %s", SSHSignatureFilename, MinisignSignatureFilename, Filename)
+	}
+
+	return nil, fmt.Errorf("the registry manifest is not signed by a trusted key: %w", errors.Join(verifyErrs...))
+}
+
+// parse parses a manifest in the format produced by sha256sum.
+func parse(data []byte) (*Manifest, error) {
+	m := Manifest{hashes: map[string]string{}}
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		hash, name, ok := strings.Cut(line, " ")
+		if !ok {
+			return nil, fmt.Errorf("invalid manifest line: %s", line)
+		}
+		// sha256sum marks files read in binary mode with a '*'
+		name = strings.TrimPrefix(strings.TrimSpace(name), "*")
+
+		if _, err := hex.DecodeString(hash); err != nil || len(hash) != sha256.Size*2 {
+			return nil, fmt.Errorf("invalid SHA256 hash for %s in manifest", name)
+		}
+
+		m.hashes[path.Clean(name)] = strings.ToLower(hash)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return &m, nil
+}
+
+// Verify returns an error if the file isn't listed in the manifest, or if its contents don't match the manifest.
+func (m *Manifest) Verify(name string, data []byte) error {
+	want, ok := m.hashes[path.Clean(name)]
+	if !ok {
+		return fmt.Errorf("%s is not listed in the signed registry manifest", name)
+	}
+
+	sum := sha256.Sum256(data)
+	if hex.EncodeToString(sum[:]) != want {
+		return fmt.Errorf("%s does not match the SHA256 hash in the signed registry manifest", name)
+	}
+
+	return nil
+}
+
+func isSSHKey(key string) bool {
+	return strings.HasPrefix(key, "ssh-") || strings.HasPrefix(key, "ecdsa-") || strings.HasPrefix(key, "sk-")
+}
+
+func verifyMinisign(data []byte, sig []byte, trustedKeys []string) error {
+	signature, err := minisign.DecodeSignature(string(sig))
+	if err != nil {
+		return err
+	}
+
+	for _, k := range trustedKeys {
+		if isSSHKey(k) {
+			continue
+		}
+
+		// allow the full contents of a minisign.pub file, including the untrusted comment
+		lines := strings.Split(strings.TrimSpace(k), "\n")
+		pub, err := minisign.NewPublicKey(strings.TrimSpace(lines[len(lines)-1]))
+		if err != nil {
+			return fmt.Errorf("invalid trusted key %q: %w", k, err)
+		}
+
+		if pub.KeyId != signature.KeyId {
+			continue
+		}
+
+		ok, err := pub.Verify(data, signature)
+		if err != nil {
+			return err
+		}
+		if ok {
+			return nil
+		}
+	}
+
+	return errors.New("the signature was not created by a trusted minisign key")
+}
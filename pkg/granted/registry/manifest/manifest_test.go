@@ -0,0 +1,158 @@
+package manifest
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"io/fs"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/blake2b"
+	"golang.org/x/crypto/ssh"
+)
+
+func sha256Hex(data string) string {
+	sum := sha256.Sum256([]byte(data))
+	return hex.EncodeToString(sum[:])
+}
+
+// signSSH creates an armored SSH signature in the format produced by 'ssh-keygen -Y sign'.
+func signSSH(t *testing.T, key ed25519.PrivateKey, namespace string, data []byte) []byte {
+	signer, err := ssh.NewSignerFromKey(key)
+	require.NoError(t, err)
+
+	h := sha512.Sum512(data)
+	signed := append([]byte(sshSigMagic), ssh.Marshal(signedData{
+		Namespace:     namespace,
+		HashAlgorithm: "sha512",
+		Hash:          h[:],
+	})...)
+
+	sig, err := signer.Sign(rand.Reader, signed)
+	require.NoError(t, err)
+
+	blob := append([]byte(sshSigMagic), ssh.Marshal(sshSignature{
+		Version:       sshSigVersion,
+		PublicKey:     signer.PublicKey().Marshal(),
+		Namespace:     namespace,
+		HashAlgorithm: "sha512",
+		Signature:     ssh.Marshal(sig),
+	})...)
+
+	return []byte(fmt.Sprintf("%s\n%s\n%s\n", sshSigArmorBegin, base64.StdEncoding.EncodeToString(blob), sshSigArmorEnd))
+}
+
+// signMinisign creates a prehashed minisign signature and returns it along with the encoded public key.
+func signMinisign(key ed25519.PrivateKey, data []byte) (sig []byte, pub string) {
+	keyID := []byte("testkey1")
+
+	h, _ := blake2b.New512(nil)
+	h.Write(data)
+	signature := ed25519.Sign(key, h.Sum(nil))
+
+	trustedComment := "timestamp:0"
+	global := ed25519.Sign(key, append(signature, []byte(trustedComment)...))
+
+	pub = base64.StdEncoding.EncodeToString(append(append([]byte("Ed"), keyID...), key.Public().(ed25519.PublicKey)...))
+	sig = []byte(fmt.Sprintf("untrusted comment: test\n%s\ntrusted comment: %s\n%s\n",
+		base64.StdEncoding.EncodeToString(append(append([]byte("ED"), keyID...), signature...)),
+		trustedComment,
+		base64.StdEncoding.EncodeToString(global),
+	))
+	return sig, pub
+}
+
+func TestLoad(t *testing.T) {
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	_, otherKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+
+	sshPub, err := ssh.NewPublicKey(key.Public())
+	require.NoError(t, err)
+	trustedSSHKey := string(ssh.MarshalAuthorizedKey(sshPub))
+
+	grantedYAML := "awsConfig:\n  - config\n"
+	config := "[profile dev]\nregion = us-east-1\n"
+	manifest := []byte(fmt.Sprintf("%s  granted.yml\n%s  ./config\n", sha256Hex(grantedYAML), sha256Hex(config)))
+
+	minisig, minisignPub := signMinisign(key, manifest)
+	_, otherMinisignPub := signMinisign(otherKey, manifest)
+
+	tests := []struct {
+		name        string
+		files       map[string][]byte
+		trustedKeys []string
+		wantErr     bool
+	}{
+		{
+			name:        "ssh signature",
+			files:       map[string][]byte{Filename: manifest, SSHSignatureFilename: signSSH(t, key, SSHNamespace, manifest)},
+			trustedKeys: []string{trustedSSHKey},
+		},
+		{
+			name:        "ssh signature by untrusted key",
+			files:       map[string][]byte{Filename: manifest, SSHSignatureFilename: signSSH(t, otherKey, SSHNamespace, manifest)},
+			trustedKeys: []string{trustedSSHKey},
+			wantErr:     true,
+		},
+		{
+			name:        "ssh signature with wrong namespace",
+			files:       map[string][]byte{Filename: manifest, SSHSignatureFilename: signSSH(t, key, "file", manifest)},
+			trustedKeys: []string{trustedSSHKey},
+			wantErr:     true,
+		},
+		{
+			name:        "minisign signature",
+			files:       map[string][]byte{Filename: manifest, MinisignSignatureFilename: minisig},
+			trustedKeys: []string{trustedSSHKey, minisignPub},
+		},
+		{
+			name:        "minisign signature by untrusted key",
+			files:       map[string][]byte{Filename: manifest, MinisignSignatureFilename: minisig},
+			trustedKeys: []string{otherMinisignPub},
+			wantErr:     true,
+		},
+		{
+			name:        "unsigned manifest",
+			files:       map[string][]byte{Filename: manifest},
+			trustedKeys: []string{trustedSSHKey},
+			wantErr:     true,
+		},
+		{
+			name:        "no manifest",
+			files:       map[string][]byte{},
+			trustedKeys: []string{trustedSSHKey},
+			wantErr:     true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			read := func(name string) ([]byte, error) {
+				data, ok := tt.files[name]
+				if !ok {
+					return nil, fs.ErrNotExist
+				}
+				return data, nil
+			}
+
+			m, err := Load(read, tt.trustedKeys)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+
+			assert.NoError(t, m.Verify("granted.yml", []byte(grantedYAML)))
+			assert.NoError(t, m.Verify("config", []byte(config)))
+			assert.Error(t, m.Verify("config", []byte(config+"credential_process = evil\n")))
+			assert.Error(t, m.Verify("other", []byte(config)))
+		})
+	}
+}
@@ -0,0 +1,130 @@
+package manifest
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"hash"
+	"strings"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// SSH signatures use the SSHSIG format described in
+// https://github.com/openssh/openssh-portable/blob/master/PROTOCOL.sshsig
+const (
+	sshSigMagic      = "SSHSIG"
+	sshSigVersion    = 1
+	sshSigArmorBegin = "-----BEGIN SSH SIGNATURE-----"
+	sshSigArmorEnd   = "-----END SSH SIGNATURE-----"
+)
+
+// sshSignature is the blob inside an armored SSH signature, following the magic preamble.
+type sshSignature struct {
+	Version       uint32
+	PublicKey     []byte
+	Namespace     string
+	Reserved      []byte
+	HashAlgorithm string
+	Signature     []byte
+}
+
+// signedData is the data that the SSH key signs.
+type signedData struct {
+	Namespace     string
+	Reserved      []byte
+	HashAlgorithm string
+	Hash          []byte
+}
+
+func decodeSSHSignature(armored []byte) (*sshSignature, error) {
+	s := strings.TrimSpace(string(armored))
+	if !strings.HasPrefix(s, sshSigArmorBegin) || !strings.HasSuffix(s, sshSigArmorEnd) {
+		return nil, errors.New("not an armored SSH signature")
+	}
+	s = strings.TrimSuffix(strings.TrimPrefix(s, sshSigArmorBegin), sshSigArmorEnd)
+	s = strings.Join(strings.Fields(s), "")
+
+	blob, err := base64.StdEncoding.DecodeString(s)
+	if err != nil {
+		return nil, fmt.Errorf("decoding SSH signature: %w", err)
+	}
+
+	if !bytes.HasPrefix(blob, []byte(sshSigMagic)) {
+		return nil, errors.New("SSH signature is missing the SSHSIG preamble")
+	}
+
+	var sig sshSignature
+	err = ssh.Unmarshal(blob[len(sshSigMagic):], &sig)
+	if err != nil {
+		return nil, fmt.Errorf("decoding SSH signature: %w", err)
+	}
+	if sig.Version != sshSigVersion {
+		return nil, fmt.Errorf("unsupported SSH signature version %d", sig.Version)
+	}
+
+	return &sig, nil
+}
+
+func verifySSH(data []byte, armored []byte, trustedKeys []string) error {
+	sig, err := decodeSSHSignature(armored)
+	if err != nil {
+		return err
+	}
+
+	if sig.Namespace != SSHNamespace {
+		return fmt.Errorf("the SSH signature must be created with the '%s' namespace, got '%s'", SSHNamespace, sig.Namespace)
+	}
+
+	signer, err := ssh.ParsePublicKey(sig.PublicKey)
+	if err != nil {
+		return fmt.Errorf("parsing SSH signature public key: %w", err)
+	}
+
+	trusted := false
+	for _, k := range trustedKeys {
+		if !isSSHKey(k) {
+			continue
+		}
+		pub, _, _, _, err := ssh.ParseAuthorizedKey([]byte(k))
+		if err != nil {
+			return fmt.Errorf("invalid trusted key %q: %w", k, err)
+		}
+		if bytes.Equal(pub.Marshal(), signer.Marshal()) {
+			trusted = true
+			break
+		}
+	}
+	if !trusted {
+		return fmt.Errorf("the signature was created by %s, which is not a trusted key", ssh.FingerprintSHA256(signer))
+	}
+
+	var h hash.Hash
+	switch sig.HashAlgorithm {
+	case "sha256":
+		h = sha256.New()
+	case "sha512":
+		h = sha512.New()
+	default:
+		return fmt.Errorf("unsupported SSH signature hash algorithm %s", sig.HashAlgorithm)
+	}
+	h.Write(data)
+
+	signed := append([]byte(sshSigMagic), ssh.Marshal(signedData{
+		Namespace:     sig.Namespace,
+		Reserved:      sig.Reserved,
+		HashAlgorithm: sig.HashAlgorithm,
+		Hash:          h.Sum(nil),
+	})...)
+
+	var signature ssh.Signature
+	err = ssh.Unmarshal(sig.Signature, &signature)
+	if err != nil {
+		return fmt.Errorf("decoding SSH signature: %w", err)
+	}
+
+	return signer.Verify(signed, &signature)
+}
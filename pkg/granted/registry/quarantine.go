@@ -0,0 +1,73 @@
+package registry
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/common-fate/clio"
+	grantedConfig "github.com/common-fate/granted/pkg/config"
+	"github.com/common-fate/granted/pkg/granted/awsmerge"
+	"gopkg.in/ini.v1"
+)
+
+// quarantinePath returns the file that sections which violate a registry's policy are written to, so that they can be reviewed.
+func quarantinePath(registryName string) (string, error) {
+	gConfigPath, err := grantedConfig.GrantedConfigFolder()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(gConfigPath, "quarantine", registryName+".ini"), nil
+}
+
+// warnPolicyViolations logs a warning for each section which was quarantined rather than merged.
+func warnPolicyViolations(registryName string, violations []awsmerge.PolicyViolation) {
+	if len(violations) == 0 {
+		return
+	}
+
+	p, _ := quarantinePath(registryName)
+	for _, v := range violations {
+		clio.Warnf("Quarantined [%s] from registry %s instead of syncing it: %s", v.Section.Name(), registryName, strings.ReplaceAll(v.Err.Error(), "\n", "; "))
+	}
+	clio.Warnf("Quarantined sections are not added to your AWS config file. They are written to %s for review", p)
+}
+
+// writeQuarantine writes the quarantined sections for the registry, replacing any previously
+// quarantined sections. The file is removed if there are no violations.
+func writeQuarantine(registryName string, violations []awsmerge.PolicyViolation) error {
+	p, err := quarantinePath(registryName)
+	if err != nil {
+		return err
+	}
+
+	if len(violations) == 0 {
+		err = os.Remove(p)
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+
+	f := ini.Empty()
+	for _, v := range violations {
+		sec, err := f.NewSection(v.Section.Name())
+		if err != nil {
+			return err
+		}
+		sec.Comment = "# quarantined: " + strings.ReplaceAll(v.Err.Error(), "\n", "; ")
+		for _, k := range v.Section.Keys() {
+			_, err = sec.NewKey(k.Name(), k.Value())
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	err = os.MkdirAll(filepath.Dir(p), USER_READ_WRITE_PERM)
+	if err != nil {
+		return err
+	}
+	return f.SaveTo(p)
+}
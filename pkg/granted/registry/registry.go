@@ -2,10 +2,15 @@ package registry
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"sort"
 
+	"github.com/common-fate/clio"
 	grantedConfig "github.com/common-fate/granted/pkg/config"
+	"github.com/common-fate/granted/pkg/granted/registry/fileregistry"
 	"github.com/common-fate/granted/pkg/granted/registry/gitregistry"
+	"github.com/common-fate/granted/pkg/granted/registry/httpregistry"
 	"gopkg.in/ini.v1"
 )
 
@@ -18,6 +23,52 @@ type loadedRegistry struct {
 	Registry Registry
 }
 
+const (
+	TypeGit   = "git"
+	TypeHTTPS = "https"
+	TypeFile  = "file"
+)
+
+var errUnsupportedType = errors.New("unsupported registry type")
+
+// newRegistry builds a registry from its configuration in the Granted config file.
+func newRegistry(r grantedConfig.Registry, requiredKeys []string, interactive bool) (Registry, error) {
+	switch r.Type {
+	case TypeGit, "":
+		return gitregistry.New(gitregistry.Opts{
+			Name:         r.Name,
+			URL:          r.URL,
+			Path:         r.Path,
+			Filename:     r.Filename,
+			Ref:          r.Ref,
+			RequiredKeys: requiredKeys,
+			Interactive:  interactive,
+			TrustedKeys:  r.TrustedKeys,
+		})
+	case TypeHTTPS:
+		return httpregistry.New(httpregistry.Opts{
+			Name:         r.Name,
+			URL:          r.URL,
+			Path:         r.Path,
+			Filename:     r.Filename,
+			RequiredKeys: requiredKeys,
+			Interactive:  interactive,
+			TrustedKeys:  r.TrustedKeys,
+		})
+	case TypeFile:
+		return fileregistry.New(fileregistry.Opts{
+			Name:         r.Name,
+			Directory:    r.URL,
+			Path:         r.Path,
+			Filename:     r.Filename,
+			RequiredKeys: requiredKeys,
+			Interactive:  interactive,
+			TrustedKeys:  r.TrustedKeys,
+		})
+	}
+	return nil, fmt.Errorf("%w '%s': must be one of '%s', '%s' or '%s'", errUnsupportedType, r.Type, TypeGit, TypeHTTPS, TypeFile)
+}
+
 func GetProfileRegistries(interactive bool) ([]loadedRegistry, error) {
 	gConf, err := grantedConfig.Load()
 	if err != nil {
@@ -30,25 +81,19 @@ func GetProfileRegistries(interactive bool) ([]loadedRegistry, error) {
 
 	var registries []loadedRegistry
 	for _, r := range gConf.ProfileRegistry.Registries {
-
-		if r.Type == "git" || r.Type == "" {
-			reg, err := gitregistry.New(gitregistry.Opts{
-				Name:        r.Name,
-				URL:         r.URL,
-				Path:        r.Path,
-				Filename:    r.Filename,
-				Ref:         r.Ref,
-				Interactive: interactive,
-			})
-
-			if err != nil {
-				return nil, err
-			}
-			registries = append(registries, loadedRegistry{
-				Config:   r,
-				Registry: reg,
-			})
+		reg, err := newRegistry(r, nil, interactive)
+		if errors.Is(err, errUnsupportedType) {
+			clio.Warnf("Skipping profile registry '%s': %s", r.Name, err.Error())
+			continue
 		}
+		if err != nil {
+			return nil, err
+		}
+
+		registries = append(registries, loadedRegistry{
+			Config:   r,
+			Registry: reg,
+		})
 	}
 
 	// this will sort the registry based on priority.
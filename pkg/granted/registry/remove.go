@@ -57,14 +57,15 @@ var RemoveCommand = cli.Command{
 			return err
 		}
 
-		configFile, awsConfigPath, err := loadAWSConfigFile()
+		configFile, err := loadAWSConfigFile()
 		if err != nil {
 			return err
 		}
+		defer func() { _ = configFile.Close() }()
 
-		awsmerge.RemoveRegistry(configFile, selectedRegistry.Name)
+		awsmerge.RemoveRegistry(configFile.File, selectedRegistry.Name)
 
-		err = configFile.SaveTo(awsConfigPath)
+		err = configFile.Save()
 		if err != nil {
 			return err
 		}
@@ -34,10 +34,12 @@ var SetupCommand = cli.Command{
 		}
 
 		// copy ~/.aws/config to ./config
-		configFile, _, err := loadAWSConfigFile()
+		configFile, err := loadAWSConfigFile()
 		if err != nil {
 			return err
 		}
+		// the AWS config file is only read, so it doesn't need to stay locked
+		_ = configFile.Close()
 
 		var confirm bool
 		s := &survey.Confirm{
@@ -55,7 +57,7 @@ var SetupCommand = cli.Command{
 
 		// now save cfg contents to ./config
 
-		err = configFile.SaveTo(path.Join(dir, "config"))
+		err = configFile.File.SaveTo(path.Join(dir, "config"))
 		if err != nil {
 			return err
 		}
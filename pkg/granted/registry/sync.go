@@ -5,12 +5,16 @@ import (
 	"errors"
 	"fmt"
 	"os"
+	"slices"
+	"time"
 
 	"github.com/AlecAivazis/survey/v2"
 	"github.com/common-fate/clio"
+	grantedConfig "github.com/common-fate/granted/pkg/config"
 	"github.com/common-fate/granted/pkg/granted/awsmerge"
 	"github.com/common-fate/granted/pkg/testable"
 	"github.com/urfave/cli/v2"
+	"gopkg.in/ini.v1"
 )
 
 const (
@@ -21,96 +25,271 @@ var SyncCommand = cli.Command{
 	Name:        "sync",
 	Usage:       "Pull the latest change from remote origin and sync aws profiles in aws config files",
 	Description: "Pull the latest change from remote origin and sync aws profiles in aws config files",
+	Flags: []cli.Flag{
+		&cli.BoolFlag{Name: "dry-run", Usage: "Print the changes that syncing would make to the AWS config file, without writing them"},
+		&cli.BoolFlag{Name: "confirm", Usage: "Print the changes that syncing would make to the AWS config file, and prompt for confirmation before writing them"},
+		&cli.BoolFlag{Name: "force", Usage: "Sync every registry, even if it has been synced within its sync interval"},
+	},
 	Action: func(c *cli.Context) error {
-		if err := SyncProfileRegistries(c.Context, true); err != nil {
+		names, err := dueRegistryNames(c.Bool("force"))
+		if err != nil {
+			return err
+		}
+		if names != nil && len(names) == 0 {
+			clio.Info("All Profile Registries have been synced within their sync interval. Use 'granted registry sync --force' to sync them again")
+			return nil
+		}
+
+		if !c.Bool("dry-run") && !c.Bool("confirm") {
+			return SyncNamedProfileRegistries(c.Context, true, names)
+		}
+
+		fetched, err := fetchProfileRegistries(c.Context, true, names)
+		if err != nil {
+			return err
+		}
+
+		// preview the merge without locking the AWS config file, so that it isn't locked while we prompt
+		configFile, err := readAWSConfigFile()
+		if err != nil {
+			return err
+		}
+		preview, err := mergeProfileRegistries(configFile, fetched, true)
+		if err != nil {
+			return err
+		}
+
+		changes := awsmerge.Diff(preview.before, preview.merged, preview.registryNames)
+		if len(changes) == 0 {
+			clio.Info("No changes to the AWS config file")
+			return nil
+		}
+
+		configPath, err := getDefaultAWSConfigLocation()
+		if err != nil {
+			return err
+		}
+		clio.Infof("Syncing profile registries will make the following changes to %s:", configPath)
+		awsmerge.PrintDiff(os.Stdout, changes)
+
+		if c.Bool("dry-run") {
+			return nil
+		}
+
+		in := survey.Confirm{Message: "Apply these changes?"}
+		var confirm bool
+		withStdio := survey.WithStdio(os.Stdin, os.Stderr, os.Stderr)
+		err = testable.AskOne(&in, &confirm, withStdio)
+		if err != nil {
 			return err
 		}
+		if !confirm {
+			return errors.New("sync cancelled, the AWS config file has not been changed")
+		}
 
-		return nil
+		// the policy violations were logged with the preview
+		return saveProfileRegistries(fetched, false)
 	},
 }
 
+// fetchedRegistry is a profile registry whose profiles have been pulled, along with the options to merge them with.
+type fetchedRegistry struct {
+	config   grantedConfig.Registry
+	profiles *ini.File
+	opts     awsmerge.RegistryOpts
+}
+
+// mergeResult is the result of merging every profile registry into the AWS config file.
+type mergeResult struct {
+	// before is the AWS config file before the merge.
+	before        *ini.File
+	merged        *ini.File
+	registryNames []string
+	// quarantined are the sections from each registry which violate the registry's policy.
+	quarantined map[string][]awsmerge.PolicyViolation
+}
+
+// dueRegistryNames returns the names of the registries which haven't been synced within their sync interval,
+// or of every registry if force is true. It returns nil if no registries are configured.
+func dueRegistryNames(force bool) ([]string, error) {
+	gConf, err := grantedConfig.Load()
+	if err != nil {
+		return nil, err
+	}
+	if len(gConf.ProfileRegistry.Registries) == 0 {
+		return nil, nil
+	}
+	rc, _ := LoadRegistrySyncConfig()
+	due := DueRegistries(gConf.ProfileRegistry.Registries, rc, time.Now(), force)
+	if due == nil {
+		due = []string{}
+	}
+	return due, nil
+}
+
 // Wrapper around sync func. Check if profile registry is configured, pull the latest changes and call sync func.
 // promptUserIfProfileDuplication if true will automatically prefix the duplicate profiles and won't prompt users
 // this is useful when new registry with higher priority is added and there is duplication with lower priority registry.
 func SyncProfileRegistries(ctx context.Context, interactive bool) error {
-	registries, err := GetProfileRegistries(interactive)
+	return SyncNamedProfileRegistries(ctx, interactive, nil)
+}
+
+// SyncNamedProfileRegistries syncs the profile registries with the given names. All registries are synced if names is empty.
+func SyncNamedProfileRegistries(ctx context.Context, interactive bool, names []string) error {
+	fetched, err := fetchProfileRegistries(ctx, interactive, names)
 	if err != nil {
 		return err
 	}
+	return saveProfileRegistries(fetched, true)
+}
+
+// fetchProfileRegistries pulls the latest changes for each profile registry. If names is not empty,
+// only the registries with those names are pulled. If interactive is true and a registry's profiles would
+// duplicate existing profiles, the user is prompted to prefix them. The AWS config file isn't locked,
+// as pulling a registry makes network calls and may prompt for required keys.
+func fetchProfileRegistries(ctx context.Context, interactive bool, names []string) ([]fetchedRegistry, error) {
+	registries, err := GetProfileRegistries(interactive)
+	if err != nil {
+		return nil, err
+	}
 
 	if len(registries) == 0 {
 		clio.Warn("granted registry not configured. Try adding a git repository with 'granted registry add <https://github.com/your-org/your-registry.git>'")
 	}
 
-	configFile, awsConfigPath, err := loadAWSConfigFile()
+	configFile, err := readAWSConfigFile()
 	if err != nil {
-		return err
-	}
-
-	if configFile == nil {
-		// prevent a panic reported by a user due to configFile being empty.
-		// It is likely this is caused by Granted being run for the first time on
-		// a device that does not have AWS profiles set up.
-		return nil
+		return nil, err
 	}
 
+	var fetched []fetchedRegistry
 	for _, r := range registries {
+		if len(names) > 0 && !slices.Contains(names, r.Config.Name) {
+			continue
+		}
+
 		src, err := r.Registry.AWSProfiles(ctx, interactive)
 		if err != nil {
-			return fmt.Errorf("error retrieving AWS profiles for registry %s: %w", r.Config.Name, err)
+			return nil, fmt.Errorf("error retrieving AWS profiles for registry %s: %w", r.Config.Name, err)
 		}
 
-		merged, err := awsmerge.WithRegistry(src, configFile, awsmerge.RegistryOpts{
+		opts := awsmerge.RegistryOpts{
 			Name:                    r.Config.Name,
 			PrefixAllProfiles:       r.Config.PrefixAllProfiles,
 			PrefixDuplicateProfiles: r.Config.PrefixDuplicateProfiles,
-		})
-		var dpe awsmerge.DuplicateProfileError
-		if interactive && errors.As(err, &dpe) {
-			clio.Warnf(err.Error())
-
-			const (
-				DUPLICATE = "Add registry name as prefix to all duplicate profiles for this registry"
-				ABORT     = "Abort, I will manually fix this"
-			)
-
-			options := []string{DUPLICATE, ABORT}
-
-			in := survey.Select{Message: "Please select which option would you like to choose to resolve: ", Options: options}
-			var selected string
-			withStdio := survey.WithStdio(os.Stdin, os.Stderr, os.Stderr)
-			err = testable.AskOne(&in, &selected, withStdio)
-			if err != nil {
-				return err
-			}
-
-			if selected == ABORT {
-				return fmt.Errorf("aborting sync for registry %s", r.Config.Name)
-			}
-
-			// try and merge again
-			merged, err = awsmerge.WithRegistry(src, configFile, awsmerge.RegistryOpts{
-				Name:                    r.Config.Name,
-				PrefixAllProfiles:       r.Config.PrefixAllProfiles,
-				PrefixDuplicateProfiles: true,
-			})
-			if err != nil {
-				return fmt.Errorf("error after trying to merge profiles again for registry %s: %w", r.Config.Name, err)
-			}
 		}
+		// merge into the unlocked copy so that duplicates with the registries before this one are found too
+		configFile, err = resolveDuplicateProfiles(src, configFile, &opts, interactive)
 		if err != nil {
-			return fmt.Errorf("error after trying to merge profiles for registry %s: %w", r.Config.Name, err)
+			return nil, err
 		}
 
-		configFile = merged
+		fetched = append(fetched, fetchedRegistry{config: r.Config, profiles: src, opts: opts})
+	}
+
+	return fetched, nil
+}
+
+// resolveDuplicateProfiles merges the registry's profiles into configFile. If they duplicate existing profiles
+// and interactive is true, the user is prompted to prefix the duplicates, and opts is updated to do so.
+func resolveDuplicateProfiles(src *ini.File, configFile *ini.File, opts *awsmerge.RegistryOpts, interactive bool) (*ini.File, error) {
+	merged, err := awsmerge.WithRegistry(src, configFile, *opts)
+	var dpe awsmerge.DuplicateProfileError
+	if interactive && errors.As(err, &dpe) {
+		clio.Warnf(err.Error())
+
+		const (
+			DUPLICATE = "Add registry name as prefix to all duplicate profiles for this registry"
+			ABORT     = "Abort, I will manually fix this"
+		)
+
+		options := []string{DUPLICATE, ABORT}
+
+		in := survey.Select{Message: "Please select which option would you like to choose to resolve: ", Options: options}
+		var selected string
+		withStdio := survey.WithStdio(os.Stdin, os.Stderr, os.Stderr)
+		err = testable.AskOne(&in, &selected, withStdio)
+		if err != nil {
+			return nil, err
+		}
+
+		if selected == ABORT {
+			return nil, fmt.Errorf("aborting sync for registry %s", opts.Name)
+		}
+
+		// try and merge again
+		opts.PrefixDuplicateProfiles = true
+		merged, err = awsmerge.WithRegistry(src, configFile, *opts)
+		if err != nil {
+			return nil, fmt.Errorf("error after trying to merge profiles again for registry %s: %w", opts.Name, err)
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error after trying to merge profiles for registry %s: %w", opts.Name, err)
+	}
+	return merged, nil
+}
+
+// saveProfileRegistries locks the AWS config file, merges the fetched registries into it and saves it.
+// The lock is only held while merging, and the AWS config file is only updated if every registry merges.
+// The quarantined profiles and the last sync time of each registry are then recorded.
+// Policy violations are logged if warn is true.
+func saveProfileRegistries(fetched []fetchedRegistry, warn bool) error {
+	awsConfig, err := loadAWSConfigFile()
+	if err != nil {
+		return err
+	}
+	defer func() { _ = awsConfig.Close() }()
+
+	result, err := mergeProfileRegistries(awsConfig.File, fetched, warn)
+	if err != nil {
+		return err
 	}
 
-	// Update the AWS config file only if all syncs have succeeded
-	err = configFile.SaveTo(awsConfigPath)
+	awsConfig.File = result.merged
+	err = awsConfig.Save()
 	if err != nil {
-		return fmt.Errorf("error saving AWS config to %s: %w", awsConfigPath, err)
+		return fmt.Errorf("error saving AWS config to %s: %w", awsConfig.Filename, err)
 	}
 
+	for _, name := range result.registryNames {
+		err = writeQuarantine(name, result.quarantined[name])
+		if err != nil {
+			return fmt.Errorf("error writing quarantined profiles for registry %s: %w", name, err)
+		}
+	}
+	err = recordSync(result.registryNames)
+	if err != nil {
+		return fmt.Errorf("saving registry sync config: %w", err)
+	}
 	return nil
 }
+
+// mergeProfileRegistries merges the fetched registries into configFile using the options chosen when they were fetched,
+// and applies each registry's policy. configFile isn't modified. Policy violations are logged if warn is true.
+func mergeProfileRegistries(configFile *ini.File, fetched []fetchedRegistry, warn bool) (*mergeResult, error) {
+	result := mergeResult{
+		before:      configFile,
+		quarantined: map[string][]awsmerge.PolicyViolation{},
+	}
+
+	for _, r := range fetched {
+		result.registryNames = append(result.registryNames, r.config.Name)
+
+		merged, err := awsmerge.WithRegistry(r.profiles, configFile, r.opts)
+		if err != nil {
+			return nil, fmt.Errorf("error after trying to merge profiles for registry %s: %w", r.config.Name, err)
+		}
+
+		violations := awsmerge.ApplyPolicy(merged, r.config.Name, r.config.Policy)
+		if warn {
+			warnPolicyViolations(r.config.Name, violations)
+		}
+		result.quarantined[r.config.Name] = violations
+
+		configFile = merged
+	}
+
+	result.merged = configFile
+	return &result, nil
+}
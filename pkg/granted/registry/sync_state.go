@@ -0,0 +1,136 @@
+package registry
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path"
+	"time"
+
+	"github.com/common-fate/clio"
+	grantedConfig "github.com/common-fate/granted/pkg/config"
+)
+
+const (
+	FILENAME = "registry-sync"
+)
+
+// DefaultSyncInterval is how often a profile registry is synced if it doesn't specify a sync interval.
+const DefaultSyncInterval = 24 * time.Hour
+
+type RegistrySyncConfig struct {
+	dir string
+	// LastSync is when each registry was last synced, keyed by registry name.
+	LastSync map[string]time.Time `json:"lastSync"`
+	// BackgroundSyncStartedAt is when the most recent background sync was started.
+	BackgroundSyncStartedAt time.Time `json:"backgroundSyncStartedAt"`
+}
+
+// return the absolute path of commonfate/registry-sync file.
+func (rc RegistrySyncConfig) Path() string {
+	return path.Join(rc.dir, FILENAME)
+}
+
+// create or save config in required file path.
+func (rc RegistrySyncConfig) Save() error {
+	if rc.dir == "" {
+		return errors.New("version config dir was not specified")
+	}
+
+	err := os.MkdirAll(rc.dir, os.ModePerm)
+	if err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(rc)
+	if err != nil {
+		return err
+	}
+
+	err = os.WriteFile(rc.Path(), data, 0666)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// if the required dir is present then os.MkdirAll will return nil
+// therefore we will check if 'registry-sync' file is present or not
+// if present then unmarshall the file and return the registry sync config
+// else log and return
+func LoadRegistrySyncConfig() (rc RegistrySyncConfig, ok bool) {
+	rc.LastSync = map[string]time.Time{}
+
+	cd, err := os.UserConfigDir()
+	if err != nil {
+		clio.Debug("error loading user config dir: %s", err.Error())
+		return
+	}
+
+	rc.dir = path.Join(cd, "commonfate")
+	err = os.MkdirAll(rc.dir, os.ModePerm)
+	if err != nil {
+		clio.Debug("error creating commonfate config dir: %s", err.Error())
+		return
+	}
+
+	rcfile := path.Join(rc.dir, FILENAME)
+	if _, err := os.Stat(rcfile); errors.Is(err, os.ErrNotExist) {
+		clio.Debug("registry sync config file does not exist: %s", rcfile)
+		return
+	}
+
+	data, err := os.ReadFile(rcfile)
+	if err != nil {
+		clio.Debug("error reading registry sync config: %s", err.Error())
+		return
+	}
+	err = json.Unmarshal(data, &rc)
+	if err != nil {
+		clio.Debug("error unmarshalling registry sync config: %s", err.Error())
+		return
+	}
+	if rc.LastSync == nil {
+		rc.LastSync = map[string]time.Time{}
+	}
+	ok = true
+	return
+}
+
+// recordSync sets the last sync time of the registries to now.
+// The sync config is reloaded first, as a background sync may have updated it while we were syncing.
+func recordSync(names []string) error {
+	rc, _ := LoadRegistrySyncConfig()
+	now := time.Now()
+	for _, name := range names {
+		rc.LastSync[name] = now
+	}
+	return rc.Save()
+}
+
+// DueRegistries returns the names of the registries which haven't been synced within their sync interval.
+// If force is true, every registry is returned.
+func DueRegistries(registries []grantedConfig.Registry, rc RegistrySyncConfig, now time.Time, force bool) []string {
+	var due []string
+	for _, r := range registries {
+		lastSync := rc.LastSync[r.Name]
+		if force || lastSync.IsZero() || now.Sub(lastSync) >= syncInterval(r) {
+			due = append(due, r.Name)
+		}
+	}
+	return due
+}
+
+func syncInterval(r grantedConfig.Registry) time.Duration {
+	if r.SyncInterval == "" {
+		return DefaultSyncInterval
+	}
+
+	d, err := time.ParseDuration(r.SyncInterval)
+	if err != nil {
+		clio.Warnf("Invalid syncInterval '%s' for Profile Registry %s, using the default of %s: %s", r.SyncInterval, r.Name, DefaultSyncInterval, err.Error())
+		return DefaultSyncInterval
+	}
+	return d
+}
@@ -0,0 +1,31 @@
+package registry
+
+import (
+	"testing"
+	"time"
+
+	grantedConfig "github.com/common-fate/granted/pkg/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDueRegistries(t *testing.T) {
+	now := time.Date(2024, 1, 8, 9, 0, 0, 0, time.UTC)
+
+	registries := []grantedConfig.Registry{
+		{Name: "never-synced"},
+		{Name: "daily"},
+		{Name: "a-week-ago"},
+		{Name: "frequent", SyncInterval: "15m"},
+		{Name: "frequent-recent", SyncInterval: "15m"},
+	}
+
+	rc := RegistrySyncConfig{LastSync: map[string]time.Time{
+		"daily":           now.Add(-time.Hour),
+		"a-week-ago":      now.Add(-7 * 24 * time.Hour),
+		"frequent":        now.Add(-20 * time.Minute),
+		"frequent-recent": now.Add(-5 * time.Minute),
+	}}
+
+	assert.Equal(t, []string{"never-synced", "a-week-ago", "frequent"}, DueRegistries(registries, rc, now, false))
+	assert.Equal(t, []string{"never-synced", "daily", "a-week-ago", "frequent", "frequent-recent"}, DueRegistries(registries, rc, now, true))
+}
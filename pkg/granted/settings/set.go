@@ -37,6 +37,8 @@ var SetConfigCommand = cli.Command{
 		fieldMap["Keyring.FileDir"] = keyringFields{&cfg.Keyring.FileDir}
 		fieldMap["Keyring.LibSecretCollectionName"] = keyringFields{&cfg.Keyring.LibSecretCollectionName}
 		fieldMap["Keyring.PassDir"] = keyringFields{&cfg.Keyring.PassDir}
+		fieldMap["Keyring.EncryptedFileKeyFile"] = keyringFields{&cfg.Keyring.EncryptedFileKeyFile}
+		fieldMap["Keyring.EncryptedFileSSHKey"] = keyringFields{&cfg.Keyring.EncryptedFileSSHKey}
 
 		fields := make([]string, 0, len(fieldMap))
 		for k := range fieldMap {
@@ -5,7 +5,8 @@ import (
 	"fmt"
 	"io"
 	"path/filepath"
-	"sync"
+	"slices"
+	"time"
 
 	"net/http"
 	"os"
@@ -35,7 +36,7 @@ import (
 var SSOCommand = cli.Command{
 	Name:        "sso",
 	Usage:       "Manage your local AWS configuration file from information available in AWS SSO",
-	Subcommands: []*cli.Command{&GenerateCommand, &PopulateCommand, &LoginCommand},
+	Subcommands: []*cli.Command{&GenerateCommand, &PopulateCommand, &ListCommand, &LoginCommand, &RefresherCommand},
 }
 
 const (
@@ -49,13 +50,14 @@ var GenerateCommand = cli.Command{
 	Name:      "generate",
 	Usage:     "Prints an AWS configuration file to stdout with profiles from accounts and roles available in AWS SSO",
 	UsageText: "granted [global options] sso generate [command options] [sso-start-url]",
-	Flags: []cli.Flag{
+	Flags: append([]cli.Flag{
 		&cli.StringFlag{Name: "config", Usage: "Specify the SSO config section in the Granted config file ([SSO.name])", Value: "default"},
 		&cli.StringFlag{Name: "prefix", Usage: "Specify a prefix for all generated profile names"},
 		&cli.StringFlag{Name: "sso-region", Usage: "Specify the SSO region"},
 		&cli.StringSliceFlag{Name: "source", Usage: "The sources to load AWS profiles from (valid values are: 'aws-sso')", Value: cli.NewStringSlice("aws-sso")},
 		&cli.BoolFlag{Name: "no-credential-process", Usage: "Generate profiles without the Granted credential-process integration"},
-		&cli.StringFlag{Name: "profile-template", Usage: "Specify profile name template", Value: awsconfigfile.DefaultProfileNameTemplate}},
+		&cli.StringFlag{Name: "profile-template", Usage: "Specify profile name template", Value: awsconfigfile.DefaultProfileNameTemplate},
+	}, slices.Concat(ssoFilterFlags, ssoInventoryFlags)...),
 	Action: func(c *cli.Context) error {
 		ctx := c.Context
 		fullCommand := fmt.Sprintf("%s %s", c.App.Name, c.Command.FullName()) // e.g. 'granted sso populate'
@@ -103,7 +105,7 @@ var GenerateCommand = cli.Command{
 		for _, s := range c.StringSlice("source") {
 			switch s {
 			case "aws-sso":
-				g.AddSource(AWSSSOSource{SSORegion: ssoRegion, StartURL: startURL})
+				g.AddSource(ssoInventoryOptsFromFlags(c, AWSSSOSource{SSORegion: ssoRegion, StartURL: startURL, Filter: mergeSSOFilters(cfgSSO.Filter, ssoFilterFromFlags(c))}))
 			case "commonfate", "common-fate", "cf":
 				return fmt.Errorf("the common fate profile source is no longer supported: https://www.commonfate.io/blog/winding-down")
 			default:
@@ -129,16 +131,17 @@ var PopulateCommand = cli.Command{
 	Name:      "populate",
 	Usage:     "Populate your local AWS configuration file with profiles from accounts and roles available in AWS SSO",
 	UsageText: "granted [global options] sso populate [command options] [sso-start-url]",
-	Flags: []cli.Flag{
+	Flags: append([]cli.Flag{
 		&cli.StringFlag{Name: "config", Usage: "Specify the SSO config section ([SSO.name])", Value: "default"},
 		&cli.StringFlag{Name: "prefix", Usage: "Specify a prefix for all generated profile names"},
 		&cli.StringFlag{Name: "sso-region", Usage: "Specify the SSO region"},
 		&cli.StringSliceFlag{Name: "sso-scope", Usage: "Specify the SSO scopes"},
 		&cli.StringSliceFlag{Name: "source", Usage: "The sources to load AWS profiles from", Value: cli.NewStringSlice("aws-sso")},
-		&cli.BoolFlag{Name: "prune", Usage: "Remove any generated profiles with the 'common_fate_generated_from' key which no longer exist"},
+		&cli.BoolFlag{Name: "prune", Usage: "Remove any generated profiles with the 'common_fate_generated_from' key which no longer exist or no longer match the account and role filters"},
 		&cli.StringFlag{Name: "profile-template", Usage: "Specify profile name template", Value: awsconfigfile.DefaultProfileNameTemplate},
 		&cli.BoolFlag{Name: "no-credential-process", Usage: "Generate profiles without the Granted credential-process integration"},
-	},
+		&cli.BoolFlag{Name: "all", Usage: "Populate profiles from every [SSO.name] entry in the Granted config file, each with its own prefix and profile template"},
+	}, slices.Concat(ssoFilterFlags, ssoInventoryFlags)...),
 	Action: func(c *cli.Context) error {
 		ctx := c.Context
 		fullCommand := fmt.Sprintf("%s %s", c.App.Name, c.Command.FullName()) // e.g. 'granted sso populate'
@@ -149,6 +152,16 @@ var PopulateCommand = cli.Command{
 			return nil
 		}
 
+		if c.Bool("all") {
+			err = checkPopulateAllFlags(c.IsSet, c.Args().Present())
+			if err != nil {
+				return err
+			}
+
+			base := ssoInventoryOptsFromFlags(c, AWSSSOSource{SSOScopes: c.StringSlice("sso-scope"), Filter: ssoFilterFromFlags(c)})
+			return populateAll(ctx, cfg, base, c.Bool("prune"))
+		}
+
 		cfgSSO := cfg.SSO[c.String("config")]
 
 		startURL := coalesceString(c.Args().First(), cfgSSO.StartURL)
@@ -176,66 +189,64 @@ var PopulateCommand = cli.Command{
 		prefix := coalesceString(c.String("prefix"), cfgSSO.Prefix)
 		noCredentialProcess := c.Bool("no-credential-process") || cfgSSO.NoCredentialProcess
 
-		configFilename := cfaws.GetAWSConfigPath()
-
-		// Create ~/.aws if it does not exists
-		dir := filepath.Dir(configFilename)
-		if _, err := os.Stat(dir); os.IsNotExist(err) {
-			clio.Infof("created AWS config file: %s", dir)
-			err = os.MkdirAll(dir, USER_READ_WRITE_PERM)
-			if err != nil {
-				return err
-			}
-		}
-
-		config, err := ini.LoadSources(ini.LoadOptions{
-			AllowNonUniqueSections:  false,
-			SkipUnrecognizableLines: false,
-			AllowNestedValues:       true,
-		}, configFilename)
-		if err != nil {
-			if !os.IsNotExist(err) {
-				return err
-			}
-			config = ini.Empty()
-		}
-
 		var pruneStartURLs []string
 
 		if c.Bool("prune") {
 			pruneStartURLs = []string{startURL}
 		}
 
-		g := awsconfigfile.Generator{
-			Config:              config,
-			ProfileNameTemplate: profileNameTemplate,
-			NoCredentialProcess: noCredentialProcess,
-			Prefix:              prefix,
-			PruneStartURLs:      pruneStartURLs,
-		}
-
+		// the profiles are listed before the AWS config file is locked, as listing them may prompt for a login
+		var profiles staticSource
 		for _, s := range c.StringSlice("source") {
 			switch s {
 			case "aws-sso":
-				g.AddSource(AWSSSOSource{SSORegion: ssoRegion, StartURL: startURL, SSOScopes: c.StringSlice("sso-scope")})
+				src := ssoInventoryOptsFromFlags(c, AWSSSOSource{SSORegion: ssoRegion, StartURL: startURL, SSOScopes: c.StringSlice("sso-scope"), Filter: mergeSSOFilters(cfgSSO.Filter, ssoFilterFromFlags(c))})
+				got, err := src.GetProfiles(ctx)
+				if err != nil {
+					return err
+				}
+				profiles = append(profiles, got...)
 			case "commonfate", "common-fate", "cf":
 				return fmt.Errorf("the common fate profile source is no longer supported: https://www.commonfate.io/blog/winding-down")
 			default:
 				return fmt.Errorf("unknown profile source %s: allowed sources are aws-sso", s)
 			}
 		}
-		err = g.Generate(ctx)
-		if err != nil {
-			return err
-		}
 
-		err = config.SaveTo(configFilename)
+		return updateAWSConfigForPopulate(func(config *ini.File) error {
+			g := awsconfigfile.Generator{
+				Config:              config,
+				ProfileNameTemplate: profileNameTemplate,
+				NoCredentialProcess: noCredentialProcess,
+				Prefix:              prefix,
+				PruneStartURLs:      pruneStartURLs,
+			}
+			g.AddSource(profiles)
+			return g.Generate(ctx)
+		})
+	},
+}
+
+// updateAWSConfigForPopulate locks and loads the AWS config file, calls update to merge profiles into it, and then saves it.
+// The ~/.aws folder is created if it doesn't exist. The lock is only held while update runs, so update shouldn't make network calls or prompt.
+func updateAWSConfigForPopulate(update func(config *ini.File) error) error {
+	configFilename := cfaws.GetAWSConfigPath()
+
+	// Create ~/.aws if it does not exists
+	dir := filepath.Dir(configFilename)
+	if _, err := os.Stat(dir); os.IsNotExist(err) {
+		clio.Infof("created AWS config file: %s", dir)
+		err = os.MkdirAll(dir, USER_READ_WRITE_PERM)
 		if err != nil {
 			return err
 		}
+	}
 
-		return nil
-	},
+	return cfaws.UpdateFile(configFilename, ini.LoadOptions{
+		AllowNonUniqueSections:  false,
+		SkipUnrecognizableLines: false,
+		AllowNestedValues:       true,
+	}, update)
 }
 
 var LoginCommand = cli.Command{
@@ -245,11 +256,17 @@ var LoginCommand = cli.Command{
 		&cli.StringFlag{Name: "sso-region", Usage: "Specify the SSO region"},
 		&cli.StringFlag{Name: "sso-start-url", Usage: "Specify the SSO start url"},
 		&cli.StringSliceFlag{Name: "sso-scope", Usage: "Specify the SSO scopes"},
+		&cli.StringFlag{Name: "login-flow", Usage: "The flow used to log in, either 'device-code' or 'pkce'. Defaults to the 'SSOLoginFlow' Granted config setting"},
 	},
 	Action: func(c *cli.Context) error {
 		ctx := c.Context
 		ssoStartUrl := c.String("sso-start-url")
 
+		flow, err := idclogin.ParseFlow(c.String("login-flow"))
+		if err != nil {
+			return err
+		}
+
 		if ssoStartUrl == "" {
 			in1 := survey.Input{Message: "SSO Start URL"}
 			err := testable.AskOne(&in1, &ssoStartUrl)
@@ -297,7 +314,7 @@ var LoginCommand = cli.Command{
 
 		secureSSOTokenStorage := securestorage.NewSecureSSOTokenStorage()
 
-		newSSOToken, err := idclogin.Login(ctx, *cfg, ssoStartUrl, ssoScopes)
+		newSSOToken, err := idclogin.LoginWithFlow(ctx, *cfg, ssoStartUrl, ssoScopes, flow)
 		if err != nil {
 			return err
 		}
@@ -314,33 +331,35 @@ type AWSSSOSource struct {
 	SSORegion string
 	StartURL  string
 	SSOScopes []string
+	// Filter restricts the accounts and roles that profiles are returned for.
+	Filter grantedconfig.SSOProfileFilter
+	// Offline returns profiles from the cached inventory without calling IAM Identity Center.
+	Offline bool
+	// MaxCacheAge is how long the roles for an account are cached in the inventory before they are listed again.
+	// If zero, the roles for every account are listed.
+	MaxCacheAge time.Duration
+	// AccessToken is used to call IAM Identity Center if it is set, rather than looking up a cached token or logging in.
+	AccessToken string
 }
 
 func (s AWSSSOSource) GetProfiles(ctx context.Context) ([]awsconfigfile.SSOProfile, error) {
-	region, err := cfaws.ExpandRegion(s.SSORegion)
+	inv, err := s.Inventory(ctx)
 	if err != nil {
 		return nil, err
 	}
+	return inv.profiles(s.Filter), nil
+}
 
-	cfg, err := config.LoadDefaultConfig(ctx, config.WithRetryer(func() aws.Retryer {
-		return retry.NewStandard(func(so *retry.StandardOptions) {
-			// We've disabled the built-in AWS client rate limiting below because we're using uber's rate limit package to rate limit the AWS SSO API calls
-			// The issue is caused because all Go routines use the same token bucket and it runs out of tokens. Link to the solution: https://github.com/aws/aws-sdk-go-v2/issues/1665
-			so.RateLimiter = ratelimit.NewTokenRateLimit(100000)
-			so.MaxAttempts = 15
-		})
-	}))
-	if err != nil {
-		return nil, err
+// accessToken returns an access token for the start URL, logging in if there isn't a valid cached token.
+func (s AWSSSOSource) accessToken(ctx context.Context, cfg aws.Config) (string, error) {
+	if s.AccessToken != "" {
+		return s.AccessToken, nil
 	}
-	cfg.Region = region
+
 	secureSSOTokenStorage := securestorage.NewSecureSSOTokenStorage()
 	ssoTokenFromSecureCache := secureSSOTokenStorage.GetValidSSOToken(ctx, s.StartURL)
 	ssoTokenFromPlainText := cfaws.GetValidSSOTokenFromPlaintextCache(s.StartURL)
 
-	// depending on whether creds come from secure storage or ~/.aws/sso/cache, we need to use different access tokens
-	var accessToken string
-
 	// we also want to store this in the secure cache to prevent subsequent logins
 	if ssoTokenFromPlainText != nil {
 		secureSSOTokenStorage.StoreSSOToken(s.StartURL, *ssoTokenFromPlainText)
@@ -348,32 +367,116 @@ func (s AWSSSOSource) GetProfiles(ctx context.Context) ([]awsconfigfile.SSOProfi
 
 	if ssoTokenFromSecureCache == nil && ssoTokenFromPlainText == nil {
 		// otherwise, login with SSO
+		var err error
 		ssoTokenFromSecureCache, err = idclogin.Login(ctx, cfg, s.StartURL, s.SSOScopes)
 		if err != nil {
-			return nil, err
+			return "", err
 		}
 		secureSSOTokenStorage.StoreSSOToken(s.StartURL, *ssoTokenFromSecureCache)
 	}
 
+	// depending on whether creds come from secure storage or ~/.aws/sso/cache, we need to use different access tokens
 	if ssoTokenFromSecureCache != nil {
-		accessToken = ssoTokenFromSecureCache.AccessToken
-	} else {
-		accessToken = ssoTokenFromPlainText.AccessToken
+		return ssoTokenFromSecureCache.AccessToken, nil
 	}
+	return ssoTokenFromPlainText.AccessToken, nil
+}
 
-	clio.Info("listing available profiles from AWS IAM Identity Center...")
+// ssoClientConfig returns the AWS config used to call the IAM Identity Center portal API.
+func ssoClientConfig(ctx context.Context, region string) (aws.Config, error) {
+	cfg, err := config.LoadDefaultConfig(ctx, config.WithRetryer(func() aws.Retryer {
+		return retry.NewStandard(func(so *retry.StandardOptions) {
+			// We've disabled the built-in AWS client rate limiting below because we're using uber's rate limit package to rate limit the AWS SSO API calls
+			// The issue is caused because all Go routines use the same token bucket and it runs out of tokens. Link to the solution: https://github.com/aws/aws-sdk-go-v2/issues/1665
+			so.RateLimiter = ratelimit.NewTokenRateLimit(100000)
+			so.MaxAttempts = 15
+		})
+	}))
+	if err != nil {
+		return aws.Config{}, err
+	}
+	cfg.Region = region
+	return cfg, nil
+}
 
-	ssoClient := sso.NewFromConfig(cfg)
-	g, gctx := errgroup.WithContext(ctx)
-	var mu sync.Mutex
+// listRoles lists the roles available in each account, reusing the roles from the cached
+// inventory for accounts which were listed within the source's MaxCacheAge.
+// Accounts which are excluded by the filter aren't listed.
+func (s AWSSSOSource) listRoles(ctx context.Context, ssoClient *sso.Client, accessToken string, accounts []ssoInventoryAccount, cached *ssoInventory) error {
+	now := time.Now()
+
+	var toList []int
+	for i := range accounts {
+		account := &accounts[i]
+		if prev := cached.account(account.AccountID); prev != nil {
+			account.Roles = prev.Roles
+			account.RolesUpdatedAt = prev.RolesUpdatedAt
+		}
+		if !accountAllowed(s.Filter, account.AccountID, account.AccountName) {
+			clio.Debugf("skipping account %s (%s) as it doesn't match the filters", account.AccountName, account.AccountID)
+			continue
+		}
+		if !account.RolesUpdatedAt.IsZero() && now.Sub(account.RolesUpdatedAt) < s.MaxCacheAge {
+			continue
+		}
+		toList = append(toList, i)
+	}
 
-	// if the token is nil fetch it from config instead
-	var ssoProfiles []awsconfigfile.SSOProfile
-	listAccountsNextToken := ""
-	bar := progressbar.Default(1)
-	isFirstLoop := true
+	clio.Debugf("listing roles for %d of %d accounts, the others are cached or filtered", len(toList), len(accounts))
+	if len(toList) == 0 {
+		return nil
+	}
+
+	bar := progressbar.Default(int64(len(toList)))
+	g, gctx := errgroup.WithContext(ctx)
 	// Setting the rate limit to 20 since IAM Identity Center APIs have a throttle maximum of 20 transactions per second (TPS) (https://docs.aws.amazon.com/singlesignon/latest/userguide/limits.html)
 	rl := uberratelimit.New(20)
+	for _, i := range toList {
+		account := &accounts[i]
+		g.Go(func() error {
+			var roles []string
+			listAccountRolesNextToken := ""
+			for {
+				listAccountRolesInput := sso.ListAccountRolesInput{
+					AccessToken: &accessToken,
+					AccountId:   &account.AccountID,
+				}
+				if listAccountRolesNextToken != "" {
+					listAccountRolesInput.NextToken = &listAccountRolesNextToken
+				}
+				rl.Take()
+				listAccountRolesOutput, err := ssoClient.ListAccountRoles(gctx, &listAccountRolesInput)
+				if err != nil {
+					return err
+				}
+				for _, role := range listAccountRolesOutput.RoleList {
+					roles = append(roles, aws.ToString(role.RoleName))
+				}
+
+				if listAccountRolesOutput.NextToken == nil {
+					break
+				}
+
+				listAccountRolesNextToken = *listAccountRolesOutput.NextToken
+			}
+
+			// each goroutine writes to a different account, so no locking is needed
+			account.Roles = roles
+			account.RolesUpdatedAt = now
+			return bar.Add(1)
+		})
+	}
+	err := g.Wait()
+	if err != nil {
+		return err
+	}
+	return bar.Finish()
+}
+
+// listAccounts lists every account available to the access token.
+func listAccounts(ctx context.Context, ssoClient *sso.Client, accessToken string) ([]ssoInventoryAccount, error) {
+	var accounts []ssoInventoryAccount
+	listAccountsNextToken := ""
 	for {
 		listAccountsInput := sso.ListAccountsInput{
 			AccessToken: &accessToken,
@@ -381,59 +484,14 @@ func (s AWSSSOSource) GetProfiles(ctx context.Context) ([]awsconfigfile.SSOProfi
 		if listAccountsNextToken != "" {
 			listAccountsInput.NextToken = &listAccountsNextToken
 		}
-		rl.Take()
 		listAccountsOutput, err := ssoClient.ListAccounts(ctx, &listAccountsInput)
 		if err != nil {
 			return nil, err
 		}
-		//`isFirstLoop` is used to assign the initial max of the progress bar
-		if isFirstLoop {
-			bar.ChangeMax(len(listAccountsOutput.AccountList) + 1)
-			isFirstLoop = false
-		} else {
-			bar.ChangeMax(bar.GetMax() + len(listAccountsOutput.AccountList))
-		}
-		for _, accountLoop := range listAccountsOutput.AccountList {
-			account := accountLoop
-			g.Go(func() error {
-				listAccountRolesNextToken := ""
-				for {
-					listAccountRolesInput := sso.ListAccountRolesInput{
-						AccessToken: &accessToken,
-						AccountId:   account.AccountId,
-					}
-					if listAccountRolesNextToken != "" {
-						listAccountRolesInput.NextToken = &listAccountRolesNextToken
-					}
-					rl.Take()
-					listAccountRolesOutput, err := ssoClient.ListAccountRoles(gctx, &listAccountRolesInput)
-					if err != nil {
-						return err
-					}
-					mu.Lock()
-					for _, role := range listAccountRolesOutput.RoleList {
-						ssoProfiles = append(ssoProfiles, awsconfigfile.SSOProfile{
-							SSOStartURL:   s.StartURL,
-							SSORegion:     region,
-							AccountID:     *role.AccountId,
-							AccountName:   *account.AccountName,
-							RoleName:      *role.RoleName,
-							GeneratedFrom: "aws-sso",
-						})
-					}
-					mu.Unlock()
-
-					if listAccountRolesOutput.NextToken == nil {
-						break
-					}
-
-					listAccountRolesNextToken = *listAccountRolesOutput.NextToken
-				}
-				err = bar.Add(1)
-				if err != nil {
-					return err
-				}
-				return nil
+		for _, account := range listAccountsOutput.AccountList {
+			accounts = append(accounts, ssoInventoryAccount{
+				AccountID:   aws.ToString(account.AccountId),
+				AccountName: aws.ToString(account.AccountName),
 			})
 		}
 
@@ -443,16 +501,7 @@ func (s AWSSSOSource) GetProfiles(ctx context.Context) ([]awsconfigfile.SSOProfi
 
 		listAccountsNextToken = *listAccountsOutput.NextToken
 	}
-	err = g.Wait()
-	if err != nil {
-		return nil, err
-	}
-	bar.ChangeMax(bar.GetMax() - 1)
-	err = bar.Finish()
-	if err != nil {
-		return nil, err
-	}
-	return ssoProfiles, nil
+	return accounts, nil
 }
 
 func coalesceString(s1, s2 string) string {
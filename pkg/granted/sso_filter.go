@@ -0,0 +1,87 @@
+package granted
+
+import (
+	"path"
+	"slices"
+
+	grantedconfig "github.com/common-fate/granted/pkg/config"
+	"github.com/urfave/cli/v2"
+)
+
+// ssoFilterFlags filter the accounts and roles that profiles are generated for.
+// Accounts can't be filtered by organizational unit or tag, as IAM Identity Center doesn't return them
+// and listing them from AWS Organizations requires credentials for the management account.
+var ssoFilterFlags = []cli.Flag{
+	&cli.StringSliceFlag{Name: "include-account", Usage: "Only generate profiles for these account IDs. Filtering by organizational unit or tag isn't supported, so list the account IDs or use --include-account-name"},
+	&cli.StringSliceFlag{Name: "exclude-account", Usage: "Don't generate profiles for these account IDs"},
+	&cli.StringSliceFlag{Name: "include-account-name", Usage: "Only generate profiles for accounts with names matching these glob patterns, such as 'prod-*'"},
+	&cli.StringSliceFlag{Name: "exclude-account-name", Usage: "Don't generate profiles for accounts with names matching these glob patterns"},
+	&cli.StringSliceFlag{Name: "include-role", Usage: "Only generate profiles for roles with names matching these glob patterns, such as '*Admin*'"},
+	&cli.StringSliceFlag{Name: "exclude-role", Usage: "Don't generate profiles for roles with names matching these glob patterns"},
+}
+
+// ssoFilterFromFlags returns the filters passed as flags.
+func ssoFilterFromFlags(c *cli.Context) grantedconfig.SSOProfileFilter {
+	return grantedconfig.SSOProfileFilter{
+		IncludeAccountIDs:   c.StringSlice("include-account"),
+		ExcludeAccountIDs:   c.StringSlice("exclude-account"),
+		IncludeAccountNames: c.StringSlice("include-account-name"),
+		ExcludeAccountNames: c.StringSlice("exclude-account-name"),
+		IncludeRoles:        c.StringSlice("include-role"),
+		ExcludeRoles:        c.StringSlice("exclude-role"),
+	}
+}
+
+// mergeSSOFilters combines the filters from an [SSO.name] entry in the Granted config file with the filters passed as flags.
+// Include filters passed as flags replace the include filters from the config, so that a narrower or different set of
+// accounts or roles can be selected for one run. Exclude filters from both are applied.
+func mergeSSOFilters(cfg grantedconfig.SSOProfileFilter, flags grantedconfig.SSOProfileFilter) grantedconfig.SSOProfileFilter {
+	f := grantedconfig.SSOProfileFilter{
+		IncludeAccountIDs:   slices.Clone(cfg.IncludeAccountIDs),
+		ExcludeAccountIDs:   append(slices.Clone(cfg.ExcludeAccountIDs), flags.ExcludeAccountIDs...),
+		IncludeAccountNames: slices.Clone(cfg.IncludeAccountNames),
+		ExcludeAccountNames: append(slices.Clone(cfg.ExcludeAccountNames), flags.ExcludeAccountNames...),
+		IncludeRoles:        slices.Clone(cfg.IncludeRoles),
+		ExcludeRoles:        append(slices.Clone(cfg.ExcludeRoles), flags.ExcludeRoles...),
+	}
+	// account IDs and names are alternatives for including an account, so they are replaced together
+	if len(flags.IncludeAccountIDs) > 0 || len(flags.IncludeAccountNames) > 0 {
+		f.IncludeAccountIDs = slices.Clone(flags.IncludeAccountIDs)
+		f.IncludeAccountNames = slices.Clone(flags.IncludeAccountNames)
+	}
+	if len(flags.IncludeRoles) > 0 {
+		f.IncludeRoles = slices.Clone(flags.IncludeRoles)
+	}
+	return f
+}
+
+// accountAllowed returns whether profiles may be generated for the account.
+func accountAllowed(f grantedconfig.SSOProfileFilter, accountID string, accountName string) bool {
+	if slices.Contains(f.ExcludeAccountIDs, accountID) || matchesGlob(f.ExcludeAccountNames, accountName) {
+		return false
+	}
+	if len(f.IncludeAccountIDs) > 0 || len(f.IncludeAccountNames) > 0 {
+		return slices.Contains(f.IncludeAccountIDs, accountID) || matchesGlob(f.IncludeAccountNames, accountName)
+	}
+	return true
+}
+
+// roleAllowed returns whether profiles may be generated for the role.
+func roleAllowed(f grantedconfig.SSOProfileFilter, roleName string) bool {
+	if matchesGlob(f.ExcludeRoles, roleName) {
+		return false
+	}
+	if len(f.IncludeRoles) > 0 {
+		return matchesGlob(f.IncludeRoles, roleName)
+	}
+	return true
+}
+
+func matchesGlob(patterns []string, s string) bool {
+	for _, p := range patterns {
+		if ok, _ := path.Match(p, s); ok {
+			return true
+		}
+	}
+	return false
+}
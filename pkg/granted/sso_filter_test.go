@@ -0,0 +1,56 @@
+package granted
+
+import (
+	"testing"
+
+	grantedconfig "github.com/common-fate/granted/pkg/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSSOProfileFilter(t *testing.T) {
+	f := grantedconfig.SSOProfileFilter{
+		IncludeAccountNames: []string{"prod-*"},
+		IncludeAccountIDs:   []string{"111111111111"},
+		ExcludeAccountIDs:   []string{"222222222222"},
+		IncludeRoles:        []string{"*Admin*", "ReadOnly"},
+		ExcludeRoles:        []string{"BreakGlassAdmin"},
+	}
+
+	assert.True(t, accountAllowed(f, "333333333333", "prod-payments"))
+	assert.True(t, accountAllowed(f, "111111111111", "sandbox"))
+	assert.False(t, accountAllowed(f, "222222222222", "prod-legacy"))
+	assert.False(t, accountAllowed(f, "444444444444", "dev-payments"))
+	assert.True(t, accountAllowed(grantedconfig.SSOProfileFilter{}, "444444444444", "dev-payments"))
+
+	assert.True(t, roleAllowed(f, "AWSAdministratorAccess"))
+	assert.True(t, roleAllowed(f, "ReadOnly"))
+	assert.False(t, roleAllowed(f, "BreakGlassAdmin"))
+	assert.False(t, roleAllowed(f, "Developer"))
+}
+
+func TestMergeSSOFilters(t *testing.T) {
+	cfg := grantedconfig.SSOProfileFilter{
+		IncludeAccountNames: []string{"prod-*"},
+		ExcludeAccountIDs:   []string{"222222222222"},
+		IncludeRoles:        []string{"ReadOnly"},
+	}
+
+	// without include flags, the includes from the config are used
+	f := mergeSSOFilters(cfg, grantedconfig.SSOProfileFilter{ExcludeRoles: []string{"BreakGlassAdmin"}})
+	assert.Equal(t, []string{"prod-*"}, f.IncludeAccountNames)
+	assert.Equal(t, []string{"ReadOnly"}, f.IncludeRoles)
+	assert.Equal(t, []string{"BreakGlassAdmin"}, f.ExcludeRoles)
+
+	// include flags replace the includes from the config, and excludes are combined
+	f = mergeSSOFilters(cfg, grantedconfig.SSOProfileFilter{
+		IncludeAccountIDs: []string{"111111111111"},
+		ExcludeAccountIDs: []string{"333333333333"},
+		IncludeRoles:      []string{"*Admin*"},
+	})
+	assert.Equal(t, []string{"111111111111"}, f.IncludeAccountIDs)
+	assert.Empty(t, f.IncludeAccountNames)
+	assert.Equal(t, []string{"222222222222", "333333333333"}, f.ExcludeAccountIDs)
+	assert.Equal(t, []string{"*Admin*"}, f.IncludeRoles)
+	assert.False(t, accountAllowed(f, "444444444444", "prod-payments"))
+	assert.True(t, accountAllowed(f, "111111111111", "sandbox"))
+}
@@ -0,0 +1,229 @@
+package granted
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/sso"
+	"github.com/common-fate/awsconfigfile"
+	"github.com/common-fate/clio"
+	"github.com/common-fate/granted/pkg/cfaws"
+	grantedconfig "github.com/common-fate/granted/pkg/config"
+	"github.com/urfave/cli/v2"
+)
+
+// DefaultSSOInventoryMaxAge is how long the roles for an account are cached before they are listed again.
+const DefaultSSOInventoryMaxAge = time.Hour
+
+// ssoInventoryFlags control how the cached IAM Identity Center inventory is used.
+var ssoInventoryFlags = []cli.Flag{
+	&cli.BoolFlag{Name: "offline", Usage: "Generate profiles from the cached account and role inventory, without calling IAM Identity Center"},
+	&cli.BoolFlag{Name: "refresh", Usage: "List the roles for every account, ignoring the cached account and role inventory"},
+	&cli.DurationFlag{Name: "max-cache-age", Usage: "How long the roles for an account are cached before they are listed again", Value: DefaultSSOInventoryMaxAge},
+}
+
+// ssoInventoryOptsFromFlags sets the inventory options for the source from the ssoInventoryFlags.
+func ssoInventoryOptsFromFlags(c *cli.Context, src AWSSSOSource) AWSSSOSource {
+	src.Offline = c.Bool("offline")
+	src.MaxCacheAge = c.Duration("max-cache-age")
+	if c.Bool("refresh") {
+		src.MaxCacheAge = 0
+	}
+	return src
+}
+
+// ssoInventory is the accounts and roles available from an IAM Identity Center start URL.
+// It is cached so that profiles can be generated incrementally, or offline.
+type ssoInventory struct {
+	StartURL  string                `json:"startUrl"`
+	SSORegion string                `json:"ssoRegion"`
+	UpdatedAt time.Time             `json:"updatedAt"`
+	Accounts  []ssoInventoryAccount `json:"accounts"`
+}
+
+type ssoInventoryAccount struct {
+	AccountID   string   `json:"accountId"`
+	AccountName string   `json:"accountName"`
+	Roles       []string `json:"roles"`
+	// RolesUpdatedAt is when the roles were last listed. It is zero if the roles have never been listed,
+	// which happens if the account was excluded by a filter.
+	RolesUpdatedAt time.Time `json:"rolesUpdatedAt"`
+}
+
+// account returns the cached account with the ID, or nil if it isn't in the inventory.
+func (inv *ssoInventory) account(accountID string) *ssoInventoryAccount {
+	if inv == nil {
+		return nil
+	}
+	for i := range inv.Accounts {
+		if inv.Accounts[i].AccountID == accountID {
+			return &inv.Accounts[i]
+		}
+	}
+	return nil
+}
+
+// profiles returns a profile for each account and role in the inventory which matches the filter.
+func (inv *ssoInventory) profiles(filter grantedconfig.SSOProfileFilter) []awsconfigfile.SSOProfile {
+	var profiles []awsconfigfile.SSOProfile
+	for _, account := range inv.Accounts {
+		if !accountAllowed(filter, account.AccountID, account.AccountName) {
+			continue
+		}
+		if account.RolesUpdatedAt.IsZero() {
+			clio.Warnf("the roles for account %s (%s) haven't been listed yet, run without --offline to list them", account.AccountName, account.AccountID)
+			continue
+		}
+		for _, role := range account.Roles {
+			if !roleAllowed(filter, role) {
+				continue
+			}
+			profiles = append(profiles, awsconfigfile.SSOProfile{
+				SSOStartURL:   inv.StartURL,
+				SSORegion:     inv.SSORegion,
+				AccountID:     account.AccountID,
+				AccountName:   account.AccountName,
+				RoleName:      role,
+				GeneratedFrom: "aws-sso",
+			})
+		}
+	}
+	return profiles
+}
+
+// Inventory returns the accounts and roles available from the start URL. Unless the source is offline,
+// the accounts are listed from IAM Identity Center, and the roles are listed for accounts which aren't
+// in the cached inventory or were cached longer than MaxCacheAge ago. The updated inventory is cached.
+func (s AWSSSOSource) Inventory(ctx context.Context) (*ssoInventory, error) {
+	region, err := cfaws.ExpandRegion(s.SSORegion)
+	if err != nil {
+		return nil, err
+	}
+
+	cached, err := loadSSOInventory(s.StartURL)
+	if err != nil {
+		clio.Debugf("ignoring the cached SSO inventory for %s: %s", s.StartURL, err.Error())
+	}
+
+	if s.Offline {
+		if cached == nil {
+			return nil, fmt.Errorf("there is no cached account and role inventory for %s: run without --offline to list them from IAM Identity Center", s.StartURL)
+		}
+		clio.Infof("using the account and role inventory for %s cached at %s", s.StartURL, cached.UpdatedAt.Local().Format(time.RFC1123))
+		return cached, nil
+	}
+
+	cfg, err := ssoClientConfig(ctx, region)
+	if err != nil {
+		return nil, err
+	}
+
+	accessToken, err := s.accessToken(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	clio.Info("listing available profiles from AWS IAM Identity Center...")
+
+	ssoClient := sso.NewFromConfig(cfg)
+	accounts, err := listAccounts(ctx, ssoClient, accessToken)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.listRoles(ctx, ssoClient, accessToken, accounts, cached)
+	if err != nil {
+		return nil, err
+	}
+
+	inv := &ssoInventory{
+		StartURL:  s.StartURL,
+		SSORegion: region,
+		UpdatedAt: time.Now(),
+		Accounts:  accounts,
+	}
+
+	err = saveSSOInventory(inv)
+	if err != nil {
+		// the inventory is only a cache, so profiles can still be generated if it can't be written
+		clio.Warnf("unable to cache the account and role inventory: %s", err.Error())
+	}
+
+	return inv, nil
+}
+
+// ssoInventoryPath returns the path the inventory for the start URL is cached at.
+func ssoInventoryPath(startURL string) (string, error) {
+	cacheFolder, err := grantedconfig.GrantedCacheFolder()
+	if err != nil {
+		return "", err
+	}
+	hash := sha256.Sum256([]byte(startURL))
+	return filepath.Join(cacheFolder, "sso-inventory", hex.EncodeToString(hash[:])+".json"), nil
+}
+
+// loadSSOInventory loads the cached inventory for the start URL, returning nil if it isn't cached.
+func loadSSOInventory(startURL string) (*ssoInventory, error) {
+	p, err := ssoInventoryPath(startURL)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := os.ReadFile(p)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var inv ssoInventory
+	err = json.Unmarshal(data, &inv)
+	if err != nil {
+		return nil, err
+	}
+	if inv.StartURL != startURL {
+		return nil, fmt.Errorf("the cached inventory is for %s", inv.StartURL)
+	}
+	return &inv, nil
+}
+
+func saveSSOInventory(inv *ssoInventory) error {
+	p, err := ssoInventoryPath(inv.StartURL)
+	if err != nil {
+		return err
+	}
+
+	err = os.MkdirAll(filepath.Dir(p), USER_READ_WRITE_PERM)
+	if err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(inv, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	// write to a temporary file and rename it, so that concurrent populates don't read a partial inventory
+	tmp, err := os.CreateTemp(filepath.Dir(p), filepath.Base(p)+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer func() { _ = os.Remove(tmp.Name()) }()
+
+	_, err = tmp.Write(data)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), p)
+}
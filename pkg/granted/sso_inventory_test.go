@@ -0,0 +1,56 @@
+package granted
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/common-fate/awsconfigfile"
+	grantedconfig "github.com/common-fate/granted/pkg/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSSOInventoryProfiles(t *testing.T) {
+	inv := &ssoInventory{
+		StartURL:  "https://example.awsapps.com/start",
+		SSORegion: "us-east-1",
+		Accounts: []ssoInventoryAccount{
+			{AccountID: "111111111111", AccountName: "prod", Roles: []string{"Admin", "ReadOnly"}, RolesUpdatedAt: time.Now()},
+			// never listed, as it was excluded by a filter
+			{AccountID: "222222222222", AccountName: "dev"},
+		},
+	}
+
+	got := inv.profiles(grantedconfig.SSOProfileFilter{IncludeRoles: []string{"Admin"}})
+	assert.Equal(t, []awsconfigfile.SSOProfile{
+		{SSOStartURL: inv.StartURL, SSORegion: "us-east-1", AccountID: "111111111111", AccountName: "prod", RoleName: "Admin", GeneratedFrom: "aws-sso"},
+	}, got)
+}
+
+func TestSSOInventoryOffline(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	src := AWSSSOSource{StartURL: "https://example.awsapps.com/start", SSORegion: "us-east-1", Offline: true}
+
+	_, err := src.GetProfiles(context.Background())
+	assert.Error(t, err)
+
+	err = saveSSOInventory(&ssoInventory{
+		StartURL:  src.StartURL,
+		SSORegion: "us-east-1",
+		UpdatedAt: time.Now(),
+		Accounts: []ssoInventoryAccount{
+			{AccountID: "111111111111", AccountName: "prod", Roles: []string{"Admin"}, RolesUpdatedAt: time.Now()},
+		},
+	})
+	require.NoError(t, err)
+
+	got, err := src.GetProfiles(context.Background())
+	require.NoError(t, err)
+	assert.Len(t, got, 1)
+
+	// the inventory for a different start URL isn't used
+	src.StartURL = "https://other.awsapps.com/start"
+	_, err = src.GetProfiles(context.Background())
+	assert.Error(t, err)
+}
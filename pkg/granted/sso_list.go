@@ -0,0 +1,330 @@
+package granted
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"runtime"
+	"slices"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/AlecAivazis/survey/v2"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/common-fate/awsconfigfile"
+	"github.com/common-fate/clio"
+	"github.com/common-fate/clio/clierr"
+	"github.com/common-fate/granted/pkg/assume"
+	"github.com/common-fate/granted/pkg/cfaws"
+	grantedconfig "github.com/common-fate/granted/pkg/config"
+	"github.com/urfave/cli/v2"
+	"gopkg.in/ini.v1"
+)
+
+var ListCommand = cli.Command{
+	Name:      "list",
+	Usage:     "List the accounts and roles available to you in AWS SSO",
+	UsageText: "granted [global options] sso list [command options] [sso-start-url] [-- command args...]",
+	Flags: slices.Concat([]cli.Flag{
+		&cli.StringFlag{Name: "config", Usage: "Specify the SSO config section ([SSO.name])", Value: "default"},
+		&cli.StringFlag{Name: "sso-region", Usage: "Specify the SSO region"},
+		&cli.StringSliceFlag{Name: "sso-scope", Usage: "Specify the SSO scopes"},
+		&cli.StringFlag{Name: "output", Aliases: []string{"o"}, Usage: "The output format: 'table', 'json' or 'csv'", Value: "table"},
+		&cli.BoolFlag{Name: "interactive", Aliases: []string{"i"}, Usage: "Pick an account and role, and run the command after '--' with its credentials, defaulting to your shell. Use 'assume --sso-list' to assume it in your current shell instead"},
+	}, ssoFilterFlags, ssoInventoryFlags),
+	Action: func(c *cli.Context) error {
+		ctx := c.Context
+		fullCommand := fmt.Sprintf("%s %s", c.App.Name, c.Command.FullName()) // e.g. 'granted sso list'
+
+		output := c.String("output")
+		if output != "table" && output != "json" && output != "csv" {
+			return fmt.Errorf("unsupported output format '%s': must be one of 'table', 'json' or 'csv'", output)
+		}
+
+		cfg, err := grantedconfig.Load()
+		if err != nil {
+			clio.Errorf("Error reading default config (~/.granted/config)")
+			return nil
+		}
+
+		args, command := splitCommandArgs(c.Args().Slice(), os.Args)
+		if len(command) > 0 && !c.Bool("interactive") {
+			return errors.New("a command can only be provided after '--' when using --interactive")
+		}
+
+		cfgSSO := cfg.SSO[c.String("config")]
+		var firstArg string
+		if len(args) > 0 {
+			firstArg = args[0]
+		}
+		startURL := coalesceString(firstArg, cfgSSO.StartURL)
+		if startURL == "" {
+			return clierr.New(fmt.Sprintf("Usage: %s [sso-start-url]", fullCommand), clierr.Infof("For example, %s https://example.awsapps.com/start", fullCommand))
+		}
+
+		ssoRegion := coalesceString(c.String("sso-region"), cfgSSO.SSORegion)
+		if ssoRegion == "" {
+			clio.Errorf("Please specify the --sso-region flag: '%s --sso-region us-east-1 %s'", fullCommand, startURL)
+			return nil
+		}
+
+		src := ssoInventoryOptsFromFlags(c, AWSSSOSource{SSORegion: ssoRegion, StartURL: startURL, SSOScopes: c.StringSlice("sso-scope"), Filter: mergeSSOFilters(cfgSSO.Filter, ssoFilterFromFlags(c))})
+		profiles, err := src.GetProfiles(ctx)
+		if err != nil {
+			return err
+		}
+
+		awsConfig, err := ini.LoadSources(ini.LoadOptions{AllowNestedValues: true}, cfaws.GetAWSConfigPath())
+		if err != nil {
+			if !os.IsNotExist(err) {
+				return err
+			}
+			awsConfig = ini.Empty()
+		}
+
+		rows := ssoListRows(profiles, localSSOProfiles(awsConfig))
+
+		if c.Bool("interactive") {
+			return pickAndAssumeSSORole(c, rows, command)
+		}
+
+		return printSSOListRows(os.Stdout, rows, output)
+	},
+}
+
+// ssoListRow is an account and role in the output of 'granted sso list'.
+type ssoListRow struct {
+	StartURL    string `json:"startUrl"`
+	SSORegion   string `json:"ssoRegion"`
+	AccountID   string `json:"accountId"`
+	AccountName string `json:"accountName"`
+	RoleName    string `json:"roleName"`
+	// LocalProfile is the name of the profile in the AWS config file for the account and role, if there is one.
+	LocalProfile string `json:"localProfile,omitempty"`
+}
+
+// ssoProfileKey identifies an account and role available from a start URL.
+type ssoProfileKey struct {
+	startURL  string
+	accountID string
+	roleName  string
+}
+
+func newSSOProfileKey(startURL, accountID, roleName string) ssoProfileKey {
+	// the start URL is sometimes copied from the portal with a trailing '#' or '/'
+	return ssoProfileKey{startURL: strings.TrimRight(startURL, "/#"), accountID: accountID, roleName: roleName}
+}
+
+func ssoListRows(profiles []awsconfigfile.SSOProfile, local map[ssoProfileKey]string) []ssoListRow {
+	rows := make([]ssoListRow, 0, len(profiles))
+	for _, p := range profiles {
+		rows = append(rows, ssoListRow{
+			StartURL:     p.SSOStartURL,
+			SSORegion:    p.SSORegion,
+			AccountID:    p.AccountID,
+			AccountName:  p.AccountName,
+			RoleName:     p.RoleName,
+			LocalProfile: local[newSSOProfileKey(p.SSOStartURL, p.AccountID, p.RoleName)],
+		})
+	}
+
+	sort.SliceStable(rows, func(i, j int) bool {
+		if rows[i].AccountName != rows[j].AccountName {
+			return rows[i].AccountName < rows[j].AccountName
+		}
+		return rows[i].RoleName < rows[j].RoleName
+	})
+	return rows
+}
+
+// localSSOProfiles returns the names of the profiles in the AWS config file for each SSO account and role.
+// Both native SSO profiles and profiles using the Granted credential process are included.
+// If several profiles exist for an account and role, the first one is used.
+func localSSOProfiles(f *ini.File) map[ssoProfileKey]string {
+	get := func(sec *ini.Section, keys ...string) string {
+		for _, k := range keys {
+			if sec.HasKey(k) {
+				return sec.Key(k).String()
+			}
+		}
+		return ""
+	}
+
+	local := map[ssoProfileKey]string{}
+	for _, sec := range f.Sections() {
+		name := sec.Name()
+		if name != "default" {
+			var ok bool
+			name, ok = strings.CutPrefix(name, "profile ")
+			if !ok {
+				continue
+			}
+		}
+
+		accountID := get(sec, "sso_account_id", "granted_sso_account_id")
+		roleName := get(sec, "sso_role_name", "granted_sso_role_name")
+		if accountID == "" || roleName == "" {
+			continue
+		}
+
+		startURL := get(sec, "sso_start_url", "granted_sso_start_url")
+		if session := get(sec, "sso_session"); startURL == "" && session != "" {
+			if s, err := f.GetSection("sso-session " + session); err == nil {
+				startURL = get(s, "sso_start_url")
+			}
+		}
+
+		key := newSSOProfileKey(startURL, accountID, roleName)
+		if _, ok := local[key]; !ok {
+			local[key] = name
+		}
+	}
+	return local
+}
+
+func printSSOListRows(w io.Writer, rows []ssoListRow, output string) error {
+	switch output {
+	case "json":
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(rows)
+
+	case "csv":
+		cw := csv.NewWriter(w)
+		_ = cw.Write([]string{"account_id", "account_name", "role_name", "local_profile", "sso_start_url", "sso_region"})
+		for _, r := range rows {
+			_ = cw.Write([]string{r.AccountID, r.AccountName, r.RoleName, r.LocalProfile, r.StartURL, r.SSORegion})
+		}
+		cw.Flush()
+		return cw.Error()
+
+	default:
+		tw := tabwriter.NewWriter(w, 10, 1, 3, ' ', 0)
+		_, _ = fmt.Fprintln(tw, strings.Join([]string{"ACCOUNT ID", "ACCOUNT NAME", "ROLE", "LOCAL PROFILE"}, "\t"))
+		for _, r := range rows {
+			localProfile := r.LocalProfile
+			if localProfile == "" {
+				localProfile = "-"
+			}
+			_, _ = fmt.Fprintln(tw, strings.Join([]string{r.AccountID, r.AccountName, r.RoleName, localProfile}, "\t"))
+		}
+		return tw.Flush()
+	}
+}
+
+// splitCommandArgs splits the positional arguments of a command into those before a '--' argument in osArgs,
+// and the command and its arguments after it. The '--' itself isn't included in args, as it is removed by the flag parser.
+func splitCommandArgs(args []string, osArgs []string) (positional []string, command []string) {
+	for i, arg := range osArgs {
+		if arg == "--" {
+			command = osArgs[i+1:]
+			break
+		}
+	}
+	if len(command) > len(args) {
+		return args, nil
+	}
+	return args[:len(args)-len(command)], command
+}
+
+// pickAndAssumeSSORole prompts for an account and role, and runs a command with credentials for it.
+// The local profile is used if one exists, so that its configuration such as the region is applied.
+//
+// 'granted' can't export credentials to the calling shell like the 'assume' shell script does,
+// so the credentials are provided to a command instead, which is a new shell by default.
+// 'assume --sso-list' shows the same picker and exports the credentials to the calling shell.
+func pickAndAssumeSSORole(c *cli.Context, rows []ssoListRow, cmd []string) error {
+	ctx := c.Context
+	if len(rows) == 0 {
+		return errors.New("no accounts and roles are available")
+	}
+
+	options := make([]string, len(rows))
+	for i, r := range rows {
+		options[i] = fmt.Sprintf("%s (%s) / %s", r.AccountName, r.AccountID, r.RoleName)
+		if r.LocalProfile != "" {
+			options[i] += fmt.Sprintf(" [%s]", r.LocalProfile)
+		}
+	}
+
+	var selected int
+	in := survey.Select{Message: "Select an account and role to assume:", Options: options}
+	err := survey.AskOne(&in, &selected, survey.WithStdio(os.Stdin, os.Stderr, os.Stderr))
+	if err != nil {
+		return err
+	}
+	row := rows[selected]
+
+	var profile *cfaws.Profile
+	if row.LocalProfile != "" {
+		profiles, err := cfaws.LoadProfiles()
+		if err != nil {
+			return err
+		}
+		profile, err = profiles.LoadInitialisedProfile(ctx, row.LocalProfile)
+		if err != nil {
+			return err
+		}
+	} else {
+		s := &cfaws.AwsSsoAssumer{}
+		profile = &cfaws.Profile{
+			Name:        row.RoleName,
+			ProfileType: s.Type(),
+			AWSConfig: config.SharedConfig{
+				SSOAccountID: row.AccountID,
+				SSORoleName:  row.RoleName,
+				SSORegion:    row.SSORegion,
+				SSOStartURL:  row.StartURL,
+			},
+			Initialised: true,
+		}
+	}
+
+	duration := time.Hour
+	if profile.AWSConfig.RoleDurationSeconds != nil {
+		duration = *profile.AWSConfig.RoleDurationSeconds
+	}
+
+	creds, err := profile.AssumeTerminal(ctx, cfaws.ConfigOpts{Duration: duration})
+	if err != nil {
+		return err
+	}
+
+	region, err := profile.Region(ctx)
+	if err != nil {
+		return err
+	}
+
+	if len(cmd) == 0 {
+		cmd = []string{defaultShell()}
+	}
+
+	clio.Successf("Assumed %s (%s) / %s, running '%s' with the credentials", row.AccountName, row.AccountID, row.RoleName, strings.Join(cmd, " "))
+	if row.LocalProfile != "" {
+		clio.Infof("To assume this role in your current shell, run: assume %s, or pick it with: assume --sso-list", row.LocalProfile)
+	} else {
+		clio.Infof("To assume this role in your current shell, run: assume --sso --sso-start-url %s --sso-region %s --account-id %s --role-name %s, or pick it with: assume --sso-list", row.StartURL, row.SSORegion, row.AccountID, row.RoleName)
+	}
+
+	code, err := assume.RunSupervised(assume.ExecEnv(assume.EnvKeys(creds, region)...), cmd[0], cmd[1:]...)
+	if err != nil {
+		return err
+	}
+	if code != 0 {
+		return cli.Exit("", code)
+	}
+	return nil
+}
+
+// defaultShell returns the user's shell, which is run with the credentials for the selected role by 'granted sso list --interactive'.
+func defaultShell() string {
+	if runtime.GOOS == "windows" {
+		return coalesceString(os.Getenv("COMSPEC"), "cmd.exe")
+	}
+	return coalesceString(os.Getenv("SHELL"), "/bin/sh")
+}
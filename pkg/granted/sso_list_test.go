@@ -0,0 +1,74 @@
+package granted
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/common-fate/awsconfigfile"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/ini.v1"
+)
+
+func TestSSOListRows(t *testing.T) {
+	f, err := ini.Load([]byte(`
+[profile prod-admin]
+sso_start_url = https://example.awsapps.com/start#/
+sso_region = us-east-1
+sso_account_id = 111111111111
+sso_role_name = Admin
+
+[profile dev-readonly]
+sso_session = example
+sso_account_id = 222222222222
+sso_role_name = ReadOnly
+
+[profile prod-admin-cp]
+granted_sso_start_url = https://example.awsapps.com/start
+granted_sso_account_id = 111111111111
+granted_sso_role_name = Admin
+credential_process = granted credential-process --profile prod-admin-cp
+
+[sso-session example]
+sso_start_url = https://example.awsapps.com/start
+sso_region = us-east-1
+`))
+	require.NoError(t, err)
+
+	profiles := []awsconfigfile.SSOProfile{
+		{SSOStartURL: "https://example.awsapps.com/start", AccountID: "222222222222", AccountName: "dev", RoleName: "ReadOnly"},
+		{SSOStartURL: "https://example.awsapps.com/start", AccountID: "111111111111", AccountName: "prod", RoleName: "Admin"},
+		{SSOStartURL: "https://example.awsapps.com/start", AccountID: "111111111111", AccountName: "prod", RoleName: "ReadOnly"},
+	}
+
+	rows := ssoListRows(profiles, localSSOProfiles(f))
+	var local []string
+	for _, r := range rows {
+		local = append(local, r.LocalProfile)
+	}
+	assert.Equal(t, []string{"dev-readonly", "prod-admin", ""}, local)
+
+	var buf bytes.Buffer
+	err = printSSOListRows(&buf, rows, "csv")
+	require.NoError(t, err)
+	assert.Equal(t, `account_id,account_name,role_name,local_profile,sso_start_url,sso_region
+222222222222,dev,ReadOnly,dev-readonly,https://example.awsapps.com/start,
+111111111111,prod,Admin,prod-admin,https://example.awsapps.com/start,
+111111111111,prod,ReadOnly,,https://example.awsapps.com/start,
+`, buf.String())
+}
+
+func TestSplitCommandArgs(t *testing.T) {
+	osArgs := []string{"granted", "sso", "list", "-i", "https://example.awsapps.com/start", "--", "aws", "s3", "cp", "my file.txt", "s3://bucket"}
+	args, command := splitCommandArgs([]string{"https://example.awsapps.com/start", "aws", "s3", "cp", "my file.txt", "s3://bucket"}, osArgs)
+	assert.Equal(t, []string{"https://example.awsapps.com/start"}, args)
+	assert.Equal(t, []string{"aws", "s3", "cp", "my file.txt", "s3://bucket"}, command)
+
+	args, command = splitCommandArgs([]string{"aws", "s3", "ls"}, []string{"granted", "sso", "list", "-i", "--", "aws", "s3", "ls"})
+	assert.Empty(t, args)
+	assert.Equal(t, []string{"aws", "s3", "ls"}, command)
+
+	args, command = splitCommandArgs([]string{"https://example.awsapps.com/start"}, []string{"granted", "sso", "list", "https://example.awsapps.com/start"})
+	assert.Equal(t, []string{"https://example.awsapps.com/start"}, args)
+	assert.Empty(t, command)
+}
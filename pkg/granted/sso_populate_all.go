@@ -0,0 +1,165 @@
+package granted
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/common-fate/awsconfigfile"
+	"github.com/common-fate/clio"
+	"github.com/common-fate/granted/pkg/cfaws"
+	grantedconfig "github.com/common-fate/granted/pkg/config"
+	"golang.org/x/sync/errgroup"
+	"gopkg.in/ini.v1"
+)
+
+// populateAllIncompatibleFlags can't be used with 'granted sso populate --all', as each
+// [SSO.name] entry in the Granted config file provides its own values.
+var populateAllIncompatibleFlags = []string{"config", "prefix", "sso-region", "profile-template"}
+
+// staticSource is a profile source which returns profiles which have already been listed.
+type staticSource []awsconfigfile.SSOProfile
+
+func (s staticSource) GetProfiles(ctx context.Context) ([]awsconfigfile.SSOProfile, error) {
+	return s, nil
+}
+
+// populateAll lists the profiles for every [SSO.name] entry in the Granted config file, and merges them into the AWS config file.
+// An access token is obtained for each start URL one at a time, so that at most one login is in progress,
+// and then the accounts for every start URL are listed concurrently. Each entry uses its own prefix and profile template.
+// Each entry is listed using the options from base, and the filter from base is merged with the filter from each entry using mergeSSOFilters.
+// If prune is true, generated profiles which no longer exist are removed for all of the start URLs at once.
+// The AWS config file is only locked once every entry has been listed, and all of the entries are written at once,
+// so the config file is left untouched if any of them fail.
+func populateAll(ctx context.Context, cfg *grantedconfig.Config, base AWSSSOSource, prune bool) error {
+	if len(cfg.SSO) == 0 {
+		return errors.New("no [SSO.name] entries are configured in the Granted config file")
+	}
+
+	names := make([]string, 0, len(cfg.SSO))
+	for name, entry := range cfg.SSO {
+		if entry.StartURL == "" || entry.SSORegion == "" {
+			return fmt.Errorf("the [SSO.%s] entry in the Granted config file must specify both StartURL and SSORegion", name)
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	sources := make([]AWSSSOSource, len(names))
+	for i, name := range names {
+		entry := cfg.SSO[name]
+		src := base
+		src.SSORegion = entry.SSORegion
+		src.StartURL = entry.StartURL
+		src.Filter = mergeSSOFilters(entry.Filter, base.Filter)
+		if !src.Offline {
+			region, err := cfaws.ExpandRegion(src.SSORegion)
+			if err != nil {
+				return err
+			}
+			clientCfg, err := ssoClientConfig(ctx, region)
+			if err != nil {
+				return err
+			}
+			src.AccessToken, err = src.accessToken(ctx, clientCfg)
+			if err != nil {
+				return fmt.Errorf("logging in for [SSO.%s] (%s): %w", name, entry.StartURL, err)
+			}
+		}
+		sources[i] = src
+	}
+
+	profiles := make([][]awsconfigfile.SSOProfile, len(names))
+	g, gctx := errgroup.WithContext(ctx)
+	for i, name := range names {
+		i, name := i, name
+		entry := cfg.SSO[name]
+		g.Go(func() error {
+			got, err := sources[i].GetProfiles(gctx)
+			if err != nil {
+				return fmt.Errorf("listing profiles for [SSO.%s] (%s): %w", name, entry.StartURL, err)
+			}
+			profiles[i] = got
+			return nil
+		})
+	}
+	err := g.Wait()
+	if err != nil {
+		return err
+	}
+
+	err = updateAWSConfigForPopulate(func(config *ini.File) error {
+		if prune {
+			var startURLs []string
+			for _, name := range names {
+				startURLs = append(startURLs, cfg.SSO[name].StartURL)
+			}
+			pruneGeneratedProfiles(config, startURLs)
+		}
+
+		for i, name := range names {
+			entry := cfg.SSO[name]
+			gen := awsconfigfile.Generator{
+				Config:              config,
+				ProfileNameTemplate: coalesceString(entry.ProfileTemplate, awsconfigfile.DefaultProfileNameTemplate),
+				NoCredentialProcess: entry.NoCredentialProcess,
+				Prefix:              entry.Prefix,
+			}
+			gen.AddSource(staticSource(profiles[i]))
+
+			err := gen.Generate(ctx)
+			if err != nil {
+				return fmt.Errorf("generating profiles for [SSO.%s]: %w", name, err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for i, name := range names {
+		clio.Infof("Populated %d profiles from [SSO.%s] (%s)", len(profiles[i]), name, cfg.SSO[name].StartURL)
+	}
+	return nil
+}
+
+// pruneGeneratedProfiles removes the profiles generated by 'granted sso populate' for the start URLs.
+// It matches the pruning done by awsconfigfile.Merge, so that several start URLs can be pruned in one pass.
+func pruneGeneratedProfiles(config *ini.File, startURLs []string) {
+	prune := map[string]bool{}
+	for _, u := range startURLs {
+		prune[u] = true
+	}
+
+	for _, sec := range config.Sections() {
+		if !sec.HasKey("common_fate_generated_from") {
+			continue
+		}
+
+		var startURL string
+		if sec.HasKey("granted_sso_start_url") {
+			startURL = sec.Key("granted_sso_start_url").String()
+		} else if sec.HasKey("sso_start_url") {
+			startURL = sec.Key("sso_start_url").String()
+		}
+
+		if prune[startURL] {
+			config.DeleteSection(sec.Name())
+		}
+	}
+}
+
+// checkPopulateAllFlags returns an error if flags which conflict with the [SSO.name] entries are set.
+func checkPopulateAllFlags(isSet func(name string) bool, hasStartURL bool) error {
+	if hasStartURL {
+		return errors.New("a start URL can't be provided with --all, as the start URLs are read from the [SSO.name] entries in the Granted config file")
+	}
+	for _, f := range populateAllIncompatibleFlags {
+		if isSet(f) {
+			return fmt.Errorf("the --%s flag can't be used with --all, as each [SSO.name] entry in the Granted config file provides its own value", f)
+		}
+	}
+	return nil
+}
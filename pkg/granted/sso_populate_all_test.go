@@ -0,0 +1,33 @@
+package granted
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/ini.v1"
+)
+
+func TestPruneGeneratedProfiles(t *testing.T) {
+	config, err := ini.Load([]byte(`
+[profile manual]
+granted_sso_start_url = https://one.awsapps.com/start
+
+[profile one/admin]
+granted_sso_start_url      = https://one.awsapps.com/start
+common_fate_generated_from = aws-sso
+
+[profile two/admin]
+sso_start_url              = https://two.awsapps.com/start
+common_fate_generated_from = aws-sso
+
+[profile three/admin]
+granted_sso_start_url      = https://three.awsapps.com/start
+common_fate_generated_from = aws-sso
+`))
+	require.NoError(t, err)
+
+	pruneGeneratedProfiles(config, []string{"https://one.awsapps.com/start", "https://two.awsapps.com/start"})
+
+	assert.Equal(t, []string{ini.DefaultSection, "profile manual", "profile three/admin"}, config.SectionStrings())
+}
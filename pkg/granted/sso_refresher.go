@@ -0,0 +1,41 @@
+package granted
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/common-fate/granted/pkg/securestorage"
+	"github.com/common-fate/granted/pkg/tokenrefresher"
+	"github.com/urfave/cli/v2"
+)
+
+var RefresherCommand = cli.Command{
+	Name:  "refresher",
+	Usage: "Run a background process which refreshes IAM Identity Center tokens before they expire",
+	Flags: []cli.Flag{
+		&cli.DurationFlag{Name: "window", Usage: "Refresh tokens which are due to expire within this window", Value: 15 * time.Minute},
+		&cli.DurationFlag{Name: "registration-window", Usage: "Renew OIDC client registrations which expire within this window", Value: 7 * 24 * time.Hour},
+		&cli.DurationFlag{Name: "interval", Usage: "How often to check the stored tokens", Value: time.Minute},
+		&cli.BoolFlag{Name: "once", Usage: "Check the stored tokens once and exit"},
+	},
+	Action: func(c *cli.Context) error {
+		r := tokenrefresher.Refresher{
+			Storage:            securestorage.NewSecureSSOTokenStorage(),
+			Window:             c.Duration("window"),
+			RegistrationWindow: c.Duration("registration-window"),
+			Interval:           c.Duration("interval"),
+		}
+
+		if c.Bool("once") {
+			_, err := r.RunOnce(c.Context)
+			return err
+		}
+
+		ctx, cancel := signal.NotifyContext(c.Context, os.Interrupt, syscall.SIGTERM)
+		defer cancel()
+
+		return r.Run(ctx)
+	},
+}
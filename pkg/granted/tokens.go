@@ -14,6 +14,7 @@ import (
 	"github.com/common-fate/granted/pkg/cfaws"
 	"github.com/common-fate/granted/pkg/securestorage"
 	"github.com/common-fate/granted/pkg/testable"
+	"github.com/common-fate/granted/pkg/tokenrefresher"
 	"github.com/urfave/cli/v2"
 )
 
@@ -107,13 +108,25 @@ var TokenExpiryCommand = cli.Command{
 		jsonflag := c.Bool("json")
 
 		type sso_expiry struct {
-			StartURLs string `json:"start_urls"`
-			ExpiresAt string `json:"expires_at"`
-			IsExpired bool   `json:"is_expired"`
+			StartURLs             string `json:"start_urls"`
+			ExpiresAt             string `json:"expires_at"`
+			IsExpired             bool   `json:"is_expired"`
+			Refreshable           bool   `json:"refreshable"`
+			RegistrationExpiresAt string `json:"registration_expires_at,omitempty"`
+			RefresherCheckedAt    string `json:"refresher_checked_at,omitempty"`
+			RefresherRefreshedAt  string `json:"refresher_refreshed_at,omitempty"`
+			RefresherError        string `json:"refresher_error,omitempty"`
 		}
 
 		var jsonDataArray []sso_expiry
 
+		// include the status reported by 'granted sso refresher', if it has run
+		refresherState, err := tokenrefresher.LoadState()
+		if err != nil {
+			clio.Debugw("error loading token refresher state", "error", err)
+			refresherState = &tokenrefresher.State{}
+		}
+
 		for _, key := range keys {
 			token := secureSSOTokenStorage.GetValidSSOToken(ctx, key)
 
@@ -129,6 +142,19 @@ var TokenExpiryCommand = cli.Command{
 					ExpiresAt: expiry,
 					IsExpired: expiry == "EXPIRED",
 				}
+				if token != nil {
+					sso_expiry_data.Refreshable = token.CanRefresh()
+					if !token.RegistrationExpiresAt.IsZero() {
+						sso_expiry_data.RegistrationExpiresAt = token.RegistrationExpiresAt.Local().Format(time.RFC3339)
+					}
+				}
+				if status, ok := refresherState.Tokens[key]; ok {
+					sso_expiry_data.RefresherCheckedAt = status.LastCheckedAt.Local().Format(time.RFC3339)
+					if status.LastRefreshedAt != nil {
+						sso_expiry_data.RefresherRefreshedAt = status.LastRefreshedAt.Local().Format(time.RFC3339)
+					}
+					sso_expiry_data.RefresherError = status.LastError
+				}
 				jsonDataArray = append(jsonDataArray, sso_expiry_data)
 			} else {
 				clio.Logf("%-*s (%s) expires at: %s", max, key, strings.Join(startUrlMap[key], ", "), expiry)
@@ -0,0 +1,109 @@
+// package hook runs user-configured external commands at points in the
+// assume lifecycle, such as when access to a role is denied.
+//
+// Hooks receive a JSON document describing the assume on stdin, which allows
+// them to integrate with access request tooling without parsing CLI arguments.
+package hook
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"time"
+
+	"github.com/common-fate/clio"
+	"github.com/common-fate/granted/pkg/config"
+)
+
+// Event is the point in the assume lifecycle that a hook runs at.
+type Event string
+
+const (
+	EventPreAssume  Event = "pre-assume"
+	EventPostAssume Event = "post-assume"
+	EventNoAccess   Event = "on-no-access"
+)
+
+// DefaultTimeout is used for hooks which do not specify a timeout.
+const DefaultTimeout = 5 * time.Minute
+
+// Input is written as JSON to the stdin of each hook.
+type Input struct {
+	Event     Event    `json:"event"`
+	Profile   string   `json:"profile"`
+	AccountID string   `json:"accountId,omitempty"`
+	RoleName  string   `json:"roleName,omitempty"`
+	RoleARN   string   `json:"roleArn,omitempty"`
+	Region    string   `json:"region,omitempty"`
+	Reason    string   `json:"reason,omitempty"`
+	Attach    []string `json:"attach,omitempty"`
+	Wait      bool     `json:"wait"`
+	// Error is set for the on-no-access event.
+	Error string `json:"error,omitempty"`
+	// Expiration is set for the post-assume event if the credentials expire.
+	Expiration *time.Time `json:"expiration,omitempty"`
+}
+
+// HooksForEvent returns the hooks configured for a particular event.
+func HooksForEvent(cfg *config.HooksConfig, event Event) []config.Hook {
+	if cfg == nil {
+		return nil
+	}
+	switch event {
+	case EventPreAssume:
+		return cfg.PreAssume
+	case EventPostAssume:
+		return cfg.PostAssume
+	case EventNoAccess:
+		return cfg.OnNoAccess
+	}
+	return nil
+}
+
+// Run runs each of the hooks in order, stopping at the first one which fails.
+func Run(ctx context.Context, hooks []config.Hook, input Input) error {
+	for _, h := range hooks {
+		err := runHook(ctx, h, input)
+		if err != nil {
+			return fmt.Errorf("%s hook %s failed: %w", input.Event, h.Command, err)
+		}
+	}
+	return nil
+}
+
+func runHook(ctx context.Context, h config.Hook, input Input) error {
+	if h.Command == "" {
+		return fmt.Errorf("hook command is empty")
+	}
+
+	timeout := DefaultTimeout
+	if h.Timeout != "" {
+		d, err := time.ParseDuration(h.Timeout)
+		if err != nil {
+			return fmt.Errorf("parsing hook timeout: %w", err)
+		}
+		timeout = d
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	b, err := json.Marshal(input)
+	if err != nil {
+		return err
+	}
+
+	clio.Debugw("running hook", "event", input.Event, "command", h.Command, "args", h.Args)
+
+	cmd := exec.CommandContext(ctx, h.Command, h.Args...)
+	cmd.Stdin = bytes.NewReader(b)
+	// hooks write to stderr, as stdout is read by the assume shell script.
+	cmd.Stdout = os.Stderr
+	cmd.Stderr = os.Stderr
+	cmd.Env = append(os.Environ(), "GRANTED_HOOK_EVENT="+string(input.Event))
+
+	return cmd.Run()
+}
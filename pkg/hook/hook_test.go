@@ -0,0 +1,32 @@
+package hook
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/common-fate/granted/pkg/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRun(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("hook tests use sh")
+	}
+	out := filepath.Join(t.TempDir(), "stdin.json")
+
+	err := Run(context.Background(), []config.Hook{
+		{Command: "sh", Args: []string{"-c", "cat > " + out}},
+	}, Input{Event: EventNoAccess, Profile: "dev", Reason: "deploy", Attach: []string{"TP-123"}})
+	require.NoError(t, err)
+
+	got, err := os.ReadFile(out)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"event":"on-no-access","profile":"dev","reason":"deploy","attach":["TP-123"],"wait":false}`, string(got))
+
+	err = Run(context.Background(), []config.Hook{{Command: "sh", Args: []string{"-c", "exit 1"}}}, Input{Event: EventPreAssume})
+	assert.Error(t, err)
+}
@@ -0,0 +1,109 @@
+package idclogin
+
+import (
+	"context"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ssooidc"
+	"github.com/common-fate/clio"
+	"github.com/common-fate/granted/pkg/securestorage"
+)
+
+// RegistrationRenewalWindow is how long before a client registration expires
+// that it is replaced with a new registration.
+const RegistrationRenewalWindow = 7 * 24 * time.Hour
+
+type registerClientAPI interface {
+	RegisterClient(ctx context.Context, params *ssooidc.RegisterClientInput, optFns ...func(*ssooidc.Options)) (*ssooidc.RegisterClientOutput, error)
+}
+
+type registrationStore interface {
+	GetRegistration(key string) (*securestorage.ClientRegistration, error)
+	StoreRegistration(key string, registration securestorage.ClientRegistration) error
+}
+
+// registrationKey identifies a client registration. Registrations are scoped to
+// a start URL and region, and the scopes that were requested.
+func registrationKey(startURL string, region string, scopes []string) string {
+	sorted := append([]string{}, scopes...)
+	sort.Strings(sorted)
+	return strings.Join([]string{region, strings.TrimSuffix(startURL, "/"), strings.Join(sorted, ",")}, "|")
+}
+
+// defaultScopes returns the scopes to register a client with.
+//
+// If scopes aren't provided, default to the legacy non-refreshable configuration
+// by specifying the "sso-portal:*" scope
+// there is a little more info here on this, although the specific "sso-portal:*" scope was taken from the AWS CLI source code.
+// https://docs.aws.amazon.com/cli/latest/userguide/sso-configure-profile-legacy.html
+func defaultScopes(scopes []string) []string {
+	if len(scopes) == 0 {
+		return []string{"sso-portal:*"}
+	}
+	return scopes
+}
+
+// ClientRegistration returns an OIDC client registration for the start URL,
+// reusing a stored registration if it is not close to expiring. Otherwise a new
+// client is registered and stored.
+func ClientRegistration(ctx context.Context, cfg aws.Config, startURL string, scopes []string) (*securestorage.ClientRegistration, error) {
+	store := securestorage.NewSecureSSOClientRegistrationStorage()
+	return getClientRegistration(ctx, ssooidc.NewFromConfig(cfg), &store, startURL, cfg.Region, defaultScopes(scopes), RegistrationRenewalWindow, time.Now())
+}
+
+// RenewClientRegistration registers a new client for the login flow if the stored registration
+// for the start URL expires within the window, so that the next login uses a fresh registration.
+func RenewClientRegistration(ctx context.Context, cfg aws.Config, flow Flow, startURL string, scopes []string, window time.Duration) (*securestorage.ClientRegistration, error) {
+	store := securestorage.NewSecureSSOClientRegistrationStorage()
+	scopes = defaultScopes(scopes)
+	if flow == FlowPKCE {
+		registrar := pkceRegistrar{client: &oidcClient{endpoint: oidcEndpoint(cfg.Region), http: http.DefaultClient}, issuerURL: startURL}
+		return getClientRegistration(ctx, registrar, pkceRegistrationStore{&store}, startURL, cfg.Region, scopes, window, time.Now())
+	}
+	return getClientRegistration(ctx, ssooidc.NewFromConfig(cfg), &store, startURL, cfg.Region, scopes, window, time.Now())
+}
+
+// getClientRegistration returns the stored registration for the start URL, unless it expires within the window.
+func getClientRegistration(ctx context.Context, api registerClientAPI, store registrationStore, startURL string, region string, scopes []string, window time.Duration, now time.Time) (*securestorage.ClientRegistration, error) {
+	key := registrationKey(startURL, region, scopes)
+
+	existing, err := store.GetRegistration(key)
+	if err != nil {
+		clio.Debugw("error loading OIDC client registration", "key", key, "error", err)
+	} else if existing != nil && existing.ExpiresAt.Add(-window).After(now) {
+		clio.Debugw("reusing OIDC client registration", "key", key, "expiresAt", existing.ExpiresAt)
+		return existing, nil
+	} else if existing != nil {
+		clio.Debugw("OIDC client registration is due to expire, registering a new client", "key", key, "expiresAt", existing.ExpiresAt)
+	}
+
+	client, err := api.RegisterClient(ctx, &ssooidc.RegisterClientInput{
+		ClientName: aws.String("Granted CLI"),
+		ClientType: aws.String("public"),
+		Scopes:     scopes,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	registration := securestorage.ClientRegistration{
+		ClientID:     aws.ToString(client.ClientId),
+		ClientSecret: aws.ToString(client.ClientSecret),
+		ExpiresAt:    time.Unix(client.ClientSecretExpiresAt, 0),
+		StartURL:     startURL,
+		Region:       region,
+		Scopes:       scopes,
+	}
+
+	err = store.StoreRegistration(key, registration)
+	if err != nil {
+		// the registration can still be used for this login
+		clio.Debugw("error storing OIDC client registration", "key", key, "error", err)
+	}
+
+	return &registration, nil
+}
@@ -0,0 +1,79 @@
+package idclogin
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ssooidc"
+	"github.com/common-fate/granted/pkg/securestorage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type mockRegisterClient struct {
+	calls     int
+	expiresAt time.Time
+}
+
+func (m *mockRegisterClient) RegisterClient(ctx context.Context, params *ssooidc.RegisterClientInput, optFns ...func(*ssooidc.Options)) (*ssooidc.RegisterClientOutput, error) {
+	m.calls++
+	return &ssooidc.RegisterClientOutput{
+		ClientId:              aws.String("client"),
+		ClientSecret:          aws.String("secret"),
+		ClientSecretExpiresAt: m.expiresAt.Unix(),
+	}, nil
+}
+
+type mockRegistrationStore map[string]securestorage.ClientRegistration
+
+func (m mockRegistrationStore) GetRegistration(key string) (*securestorage.ClientRegistration, error) {
+	r, ok := m[key]
+	if !ok {
+		return nil, nil
+	}
+	return &r, nil
+}
+
+func (m mockRegistrationStore) StoreRegistration(key string, registration securestorage.ClientRegistration) error {
+	m[key] = registration
+	return nil
+}
+
+func TestGetClientRegistration(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	ctx := context.Background()
+	api := &mockRegisterClient{expiresAt: now.Add(90 * 24 * time.Hour)}
+	store := mockRegistrationStore{}
+	scopes := []string{"sso:account:access"}
+
+	// the first login registers a client
+	r, err := getClientRegistration(ctx, api, store, "https://example.awsapps.com/start", "us-east-1", scopes, RegistrationRenewalWindow, now)
+	require.NoError(t, err)
+	assert.Equal(t, "client", r.ClientID)
+	assert.Equal(t, 1, api.calls)
+
+	// subsequent logins reuse the registration
+	_, err = getClientRegistration(ctx, api, store, "https://example.awsapps.com/start/", "us-east-1", scopes, RegistrationRenewalWindow, now.Add(time.Hour))
+	require.NoError(t, err)
+	assert.Equal(t, 1, api.calls)
+
+	// a different region requires a separate registration
+	_, err = getClientRegistration(ctx, api, store, "https://example.awsapps.com/start", "us-west-2", scopes, RegistrationRenewalWindow, now)
+	require.NoError(t, err)
+	assert.Equal(t, 2, api.calls)
+
+	// the registration is renewed when it is close to expiring
+	_, err = getClientRegistration(ctx, api, store, "https://example.awsapps.com/start", "us-east-1", scopes, RegistrationRenewalWindow, now.Add(85*24*time.Hour))
+	require.NoError(t, err)
+	assert.Equal(t, 3, api.calls)
+
+	// a wider window renews the registration earlier
+	_, err = getClientRegistration(ctx, api, store, "https://example.awsapps.com/start", "us-east-1", scopes, RegistrationRenewalWindow, now.Add(61*24*time.Hour))
+	require.NoError(t, err)
+	assert.Equal(t, 3, api.calls)
+	_, err = getClientRegistration(ctx, api, store, "https://example.awsapps.com/start", "us-east-1", scopes, 30*24*time.Hour, now.Add(61*24*time.Hour))
+	require.NoError(t, err)
+	assert.Equal(t, 4, api.calls)
+}
@@ -0,0 +1,44 @@
+package idclogin
+
+import (
+	"os"
+	"strconv"
+
+	"github.com/common-fate/clio"
+	grantedConfig "github.com/common-fate/granted/pkg/config"
+	"github.com/mdp/qrterminal/v3"
+)
+
+// EnvHeadless is the environment variable which controls whether Granted opens a browser to log in.
+// Setting it to 'true' prints the login URL instead, and setting it to 'false' disables headless detection.
+const EnvHeadless = "GRANTED_HEADLESS"
+
+// Headless returns true if Granted should print the login URL rather than opening a browser.
+func Headless() bool {
+	return isHeadless(os.Getenv)
+}
+
+func isHeadless(getenv func(string) string) bool {
+	if val := getenv(EnvHeadless); val != "" {
+		headless, err := strconv.ParseBool(val)
+		if err == nil {
+			return headless
+		}
+		clio.Debugw("ignoring invalid value for "+EnvHeadless, "value", val, "error", err)
+	}
+
+	// browsers opened over an SSH session won't be visible to the user.
+	// Other sessions aren't treated as headless, even without DISPLAY, as a browser can still be opened
+	// in WSL and some desktop sessions. The login URL is printed if the browser can't be opened.
+	return getenv("SSH_CONNECTION") != ""
+}
+
+// printLoginURL prints the login URL for the user to open on another device,
+// along with a QR code if 'SSOLoginQRCode' is enabled in the Granted config.
+func printLoginURL(config *grantedConfig.Config, url string) {
+	clio.Info("Open the following link in a browser on any device to log in to AWS: " + url)
+	if config.SSOLoginQRCode {
+		// stdout is read by the assume shell script and credential process callers, so the QR code is written to stderr
+		qrterminal.GenerateHalfBlock(url, qrterminal.L, os.Stderr)
+	}
+}
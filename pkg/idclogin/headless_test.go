@@ -0,0 +1,28 @@
+package idclogin
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIsHeadless(t *testing.T) {
+	tests := []struct {
+		name string
+		env  map[string]string
+		want bool
+	}{
+		{name: "linux desktop", env: map[string]string{"DISPLAY": ":0"}, want: false},
+		{name: "wsl without a display", env: map[string]string{"WSL_DISTRO_NAME": "Ubuntu"}, want: false},
+		{name: "no display", env: map[string]string{}, want: false},
+		{name: "ssh session", env: map[string]string{"SSH_CONNECTION": "10.0.0.1 50000 10.0.0.2 22"}, want: true},
+		{name: "forced headless", env: map[string]string{"GRANTED_HEADLESS": "true", "DISPLAY": ":0"}, want: true},
+		{name: "headless detection disabled", env: map[string]string{"GRANTED_HEADLESS": "false", "SSH_CONNECTION": "10.0.0.1 50000 10.0.0.2 22"}, want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			getenv := func(key string) string { return tt.env[key] }
+			assert.Equal(t, tt.want, isHeadless(getenv))
+		})
+	}
+}
@@ -0,0 +1,338 @@
+package idclogin
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ssooidc"
+	"github.com/common-fate/clio"
+	grantedConfig "github.com/common-fate/granted/pkg/config"
+	"github.com/common-fate/granted/pkg/securestorage"
+)
+
+const (
+	pkceCallbackPath = "/oauth/callback"
+	// pkceRegisteredRedirectURI is the redirect URI that clients are registered with.
+	// The port is omitted, as loopback redirects may use any port (RFC 8252 section 7.3).
+	pkceRegisteredRedirectURI = "http://127.0.0.1" + pkceCallbackPath
+	// pkceLoginTimeout is how long to wait for the browser to be redirected back to Granted.
+	pkceLoginTimeout = 10 * time.Minute
+)
+
+// oidcEndpoint returns the IAM Identity Center OIDC endpoint for a region.
+func oidcEndpoint(region string) string {
+	return fmt.Sprintf("https://oidc.%s.amazonaws.com", region)
+}
+
+// oidcClient calls the IAM Identity Center OIDC API.
+//
+// The version of the AWS SDK used by Granted doesn't support the authorization code
+// grant, so the RegisterClient and CreateToken calls are made directly. Both APIs are
+// unauthenticated and don't require request signing.
+type oidcClient struct {
+	endpoint string
+	http     *http.Client
+}
+
+type registerClientRequest struct {
+	ClientName   string   `json:"clientName"`
+	ClientType   string   `json:"clientType"`
+	Scopes       []string `json:"scopes,omitempty"`
+	GrantTypes   []string `json:"grantTypes"`
+	RedirectURIs []string `json:"redirectUris"`
+	IssuerURL    string   `json:"issuerUrl"`
+}
+
+type registerClientResponse struct {
+	ClientID              string `json:"clientId"`
+	ClientSecret          string `json:"clientSecret"`
+	ClientSecretExpiresAt int64  `json:"clientSecretExpiresAt"`
+}
+
+type createTokenRequest struct {
+	ClientID     string `json:"clientId"`
+	ClientSecret string `json:"clientSecret"`
+	GrantType    string `json:"grantType"`
+	Code         string `json:"code"`
+	RedirectURI  string `json:"redirectUri"`
+	CodeVerifier string `json:"codeVerifier"`
+}
+
+type createTokenResponse struct {
+	AccessToken  string `json:"accessToken"`
+	ExpiresIn    int32  `json:"expiresIn"`
+	RefreshToken string `json:"refreshToken"`
+}
+
+type oidcError struct {
+	StatusCode  int
+	Code        string `json:"error"`
+	Description string `json:"error_description"`
+}
+
+func (e *oidcError) Error() string {
+	if e.Description != "" {
+		return fmt.Sprintf("IAM Identity Center OIDC error (%d): %s: %s", e.StatusCode, e.Code, e.Description)
+	}
+	return fmt.Sprintf("IAM Identity Center OIDC error (%d): %s", e.StatusCode, e.Code)
+}
+
+func (c *oidcClient) post(ctx context.Context, path string, in any, out any) error {
+	body, err := json.Marshal(in)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.endpoint+path, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	res, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		oerr := oidcError{StatusCode: res.StatusCode}
+		_ = json.NewDecoder(res.Body).Decode(&oerr)
+		return &oerr
+	}
+
+	return json.NewDecoder(res.Body).Decode(out)
+}
+
+// pkceRegistrar registers clients which are able to use the authorization code grant.
+// It implements registerClientAPI so that registrations are reused in the same way as
+// for the device code flow.
+type pkceRegistrar struct {
+	client    *oidcClient
+	issuerURL string
+}
+
+func (r pkceRegistrar) RegisterClient(ctx context.Context, params *ssooidc.RegisterClientInput, optFns ...func(*ssooidc.Options)) (*ssooidc.RegisterClientOutput, error) {
+	var res registerClientResponse
+	err := r.client.post(ctx, "/client/register", registerClientRequest{
+		ClientName:   aws.ToString(params.ClientName),
+		ClientType:   aws.ToString(params.ClientType),
+		Scopes:       params.Scopes,
+		GrantTypes:   []string{"authorization_code", "refresh_token"},
+		RedirectURIs: []string{pkceRegisteredRedirectURI},
+		IssuerURL:    r.issuerURL,
+	}, &res)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ssooidc.RegisterClientOutput{
+		ClientId:              aws.String(res.ClientID),
+		ClientSecret:          aws.String(res.ClientSecret),
+		ClientSecretExpiresAt: res.ClientSecretExpiresAt,
+	}, nil
+}
+
+// pkceRegistrationStore stores registrations for the authorization code grant separately
+// from device code registrations, as they are registered with different grant types.
+type pkceRegistrationStore struct {
+	registrationStore
+}
+
+func (s pkceRegistrationStore) GetRegistration(key string) (*securestorage.ClientRegistration, error) {
+	return s.registrationStore.GetRegistration("pkce|" + key)
+}
+
+func (s pkceRegistrationStore) StoreRegistration(key string, registration securestorage.ClientRegistration) error {
+	return s.registrationStore.StoreRegistration("pkce|"+key, registration)
+}
+
+// pkceLogin completes an authorization code flow with PKCE.
+type pkceLogin struct {
+	client *oidcClient
+	store  registrationStore
+	// open opens the authorization URL in the user's browser.
+	open func(url string) error
+	now  func() time.Time
+}
+
+func loginWithPKCE(ctx context.Context, cfg aws.Config, config *grantedConfig.Config, startUrl string, scopes []string) (*securestorage.SSOToken, error) {
+	store := securestorage.NewSecureSSOClientRegistrationStorage()
+	l := pkceLogin{
+		client: &oidcClient{endpoint: oidcEndpoint(cfg.Region), http: http.DefaultClient},
+		store:  &store,
+		open: func(url string) error {
+			return openBrowser(config, url)
+		},
+		now: time.Now,
+	}
+	return l.login(ctx, startUrl, cfg.Region, scopes)
+}
+
+type authorizationResult struct {
+	code string
+	err  error
+}
+
+func (l *pkceLogin) login(ctx context.Context, startURL string, region string, scopes []string) (*securestorage.SSOToken, error) {
+	registrar := pkceRegistrar{client: l.client, issuerURL: startURL}
+	client, err := getClientRegistration(ctx, registrar, pkceRegistrationStore{l.store}, startURL, region, scopes, RegistrationRenewalWindow, l.now())
+	if err != nil {
+		return nil, err
+	}
+
+	verifier, err := randomString(64)
+	if err != nil {
+		return nil, err
+	}
+	state, err := randomString(32)
+	if err != nil {
+		return nil, err
+	}
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, fmt.Errorf("error listening for the login redirect: %w", err)
+	}
+	redirectURI := fmt.Sprintf("http://%s%s", listener.Addr().String(), pkceCallbackPath)
+
+	results := make(chan authorizationResult, 1)
+	server := &http.Server{
+		Handler:           callbackHandler(state, results),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	go func() {
+		err := server.Serve(listener)
+		if err != nil && !errors.Is(err, http.ErrServerClosed) {
+			clio.Debugw("login redirect server stopped", "error", err)
+		}
+	}()
+	defer server.Close()
+
+	authURL := authorizationURL(l.client.endpoint, client.ClientID, redirectURI, state, codeChallenge(verifier), scopes)
+	clio.Info("If the browser does not open automatically, please open this link: " + authURL)
+
+	err = l.open(authURL)
+	if err != nil {
+		return nil, err
+	}
+
+	clio.Info("Awaiting AWS authentication in the browser")
+
+	ctx, cancel := context.WithTimeout(ctx, pkceLoginTimeout)
+	defer cancel()
+
+	var result authorizationResult
+	select {
+	case <-ctx.Done():
+		return nil, fmt.Errorf("timed out waiting for the browser to redirect to %s", redirectURI)
+	case result = <-results:
+	}
+	if result.err != nil {
+		return nil, result.err
+	}
+
+	var token createTokenResponse
+	err = l.client.post(ctx, "/token", createTokenRequest{
+		ClientID:     client.ClientID,
+		ClientSecret: client.ClientSecret,
+		GrantType:    "authorization_code",
+		Code:         result.code,
+		RedirectURI:  redirectURI,
+		CodeVerifier: verifier,
+	}, &token)
+	if err != nil {
+		return nil, err
+	}
+
+	ssoToken := securestorage.SSOToken{
+		AccessToken:           token.AccessToken,
+		Expiry:                l.now().Add(time.Duration(token.ExpiresIn) * time.Second),
+		ClientID:              client.ClientID,
+		ClientSecret:          client.ClientSecret,
+		RegistrationExpiresAt: client.ExpiresAt,
+		Region:                region,
+		StartURL:              startURL,
+		Scopes:                scopes,
+		Flow:                  string(FlowPKCE),
+	}
+	if token.RefreshToken != "" {
+		ssoToken.RefreshToken = &token.RefreshToken
+	}
+
+	return &ssoToken, nil
+}
+
+// authorizationURL builds the URL which the user is sent to in order to authorize Granted.
+func authorizationURL(endpoint string, clientID string, redirectURI string, state string, challenge string, scopes []string) string {
+	q := url.Values{}
+	q.Set("response_type", "code")
+	q.Set("client_id", clientID)
+	q.Set("redirect_uri", redirectURI)
+	q.Set("state", state)
+	q.Set("code_challenge_method", "S256")
+	q.Set("code_challenge", challenge)
+	q.Set("scopes", strings.Join(scopes, " "))
+	return endpoint + "/authorize?" + q.Encode()
+}
+
+// callbackHandler handles the browser being redirected back to Granted after authorizing.
+// The first result is sent to the results channel, which must be buffered.
+func callbackHandler(state string, results chan<- authorizationResult) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc(pkceCallbackPath, func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+
+		var result authorizationResult
+		switch {
+		case q.Get("state") != state:
+			result.err = errors.New("the login redirect contained an invalid state parameter")
+		case q.Get("error") != "":
+			result.err = fmt.Errorf("authorization failed: %s %s", q.Get("error"), q.Get("error_description"))
+		case q.Get("code") == "":
+			result.err = errors.New("the login redirect did not contain an authorization code")
+		default:
+			result.code = q.Get("code")
+		}
+
+		if result.err != nil {
+			http.Error(w, "Granted was unable to log in: "+result.err.Error(), http.StatusBadRequest)
+		} else {
+			fmt.Fprintln(w, "Granted has logged in successfully. You can close this tab.")
+		}
+
+		select {
+		case results <- result:
+		default:
+		}
+	})
+	return mux
+}
+
+// randomString returns a URL-safe random string encoding n random bytes.
+func randomString(n int) (string, error) {
+	b := make([]byte, n)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// codeChallenge returns the S256 code challenge for a PKCE code verifier.
+func codeChallenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
@@ -0,0 +1,132 @@
+package idclogin
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeOIDCServer is a stand-in for the IAM Identity Center OIDC API which
+// supports the authorization code grant with PKCE.
+type fakeOIDCServer struct {
+	t  *testing.T
+	mu sync.Mutex
+	// registrations counts the number of calls to RegisterClient.
+	registrations int
+	// challenge is the code challenge received in the most recent authorization request.
+	challenge   string
+	redirectURI string
+	// deny causes the authorization request to be denied.
+	deny bool
+}
+
+func (f *fakeOIDCServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	switch r.URL.Path {
+	case "/client/register":
+		var req registerClientRequest
+		require.NoError(f.t, json.NewDecoder(r.Body).Decode(&req))
+		assert.Equal(f.t, []string{"authorization_code", "refresh_token"}, req.GrantTypes)
+		assert.Equal(f.t, []string{"http://127.0.0.1/oauth/callback"}, req.RedirectURIs)
+		assert.Equal(f.t, "https://example.awsapps.com/start", req.IssuerURL)
+		f.registrations++
+
+		_ = json.NewEncoder(w).Encode(registerClientResponse{
+			ClientID:              "client",
+			ClientSecret:          "secret",
+			ClientSecretExpiresAt: time.Now().Add(90 * 24 * time.Hour).Unix(),
+		})
+
+	case "/authorize":
+		q := r.URL.Query()
+		assert.Equal(f.t, "client", q.Get("client_id"))
+		assert.Equal(f.t, "S256", q.Get("code_challenge_method"))
+		f.challenge = q.Get("code_challenge")
+		f.redirectURI = q.Get("redirect_uri")
+
+		redirect := url.Values{"state": {q.Get("state")}}
+		if f.deny {
+			redirect.Set("error", "access_denied")
+		} else {
+			redirect.Set("code", "authcode")
+		}
+		http.Redirect(w, r, f.redirectURI+"?"+redirect.Encode(), http.StatusFound)
+
+	case "/token":
+		var req createTokenRequest
+		require.NoError(f.t, json.NewDecoder(r.Body).Decode(&req))
+		if req.Code != "authcode" || codeChallenge(req.CodeVerifier) != f.challenge || req.RedirectURI != f.redirectURI {
+			w.WriteHeader(http.StatusBadRequest)
+			_ = json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant"})
+			return
+		}
+		_ = json.NewEncoder(w).Encode(createTokenResponse{
+			AccessToken:  "access-token",
+			ExpiresIn:    3600,
+			RefreshToken: "refresh-token",
+		})
+
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func TestPKCELogin(t *testing.T) {
+	fake := &fakeOIDCServer{t: t}
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	l := pkceLogin{
+		client: &oidcClient{endpoint: server.URL, http: server.Client()},
+		store:  mockRegistrationStore{},
+		// follow the authorization redirect back to the loopback listener, as a browser would
+		open: func(url string) error {
+			res, err := http.Get(url)
+			if err != nil {
+				return err
+			}
+			return res.Body.Close()
+		},
+		now: func() time.Time { return now },
+	}
+
+	token, err := l.login(context.Background(), "https://example.awsapps.com/start", "us-east-1", []string{"sso:account:access"})
+	require.NoError(t, err)
+	assert.Equal(t, "access-token", token.AccessToken)
+	assert.Equal(t, now.Add(time.Hour), token.Expiry)
+	require.NotNil(t, token.RefreshToken)
+	assert.Equal(t, "refresh-token", *token.RefreshToken)
+	assert.Equal(t, "client", token.ClientID)
+	assert.Equal(t, "https://example.awsapps.com/start", token.StartURL)
+
+	// the client registration is reused for the next login
+	_, err = l.login(context.Background(), "https://example.awsapps.com/start", "us-east-1", []string{"sso:account:access"})
+	require.NoError(t, err)
+	assert.Equal(t, 1, fake.registrations)
+
+	fake.deny = true
+	_, err = l.login(context.Background(), "https://example.awsapps.com/start", "us-east-1", []string{"sso:account:access"})
+	assert.ErrorContains(t, err, "access_denied")
+}
+
+func TestParseFlow(t *testing.T) {
+	for _, s := range []string{"", "device-code", "pkce"} {
+		got, err := ParseFlow(s)
+		assert.NoError(t, err)
+		assert.Equal(t, Flow(s), got)
+	}
+
+	_, err := ParseFlow("implicit")
+	assert.Error(t, err)
+}
@@ -18,31 +18,77 @@ import (
 	"github.com/pkg/browser"
 )
 
-// Login contains all the steps to complete a device code flow to retrieve an SSO token
+// Flow is the OAuth grant used to log in to IAM Identity Center.
+type Flow string
+
+const (
+	// FlowDeviceCode uses the device authorization grant, which requires the
+	// user to confirm a code in the browser.
+	FlowDeviceCode Flow = "device-code"
+	// FlowPKCE uses the authorization code grant with PKCE, redirecting the
+	// browser to a loopback address which Granted listens on.
+	FlowPKCE Flow = "pkce"
+)
+
+// ParseFlow parses a login flow. An empty string is returned as an empty Flow.
+func ParseFlow(s string) (Flow, error) {
+	switch Flow(s) {
+	case "", FlowDeviceCode, FlowPKCE:
+		return Flow(s), nil
+	}
+	return "", fmt.Errorf("invalid SSO login flow '%s', valid flows are '%s' and '%s'", s, FlowDeviceCode, FlowPKCE)
+}
+
+// Login logs in to IAM Identity Center using the login flow from the Granted config file, defaulting to the device code flow.
 func Login(ctx context.Context, cfg aws.Config, startUrl string, scopes []string) (*securestorage.SSOToken, error) {
-	ssooidcClient := ssooidc.NewFromConfig(cfg)
+	return LoginWithFlow(ctx, cfg, startUrl, scopes, "")
+}
 
-	// If scopes aren't provided, default to the legacy non-refreshable configuration
-	// by specifying the "sso-portal:*" scope
-	// there is a little more info here on this, although the specific "sso-portal:*" scope was taken from the AWS CLI source code.
-	// https://docs.aws.amazon.com/cli/latest/userguide/sso-configure-profile-legacy.html
-	if len(scopes) == 0 {
-		scopes = []string{"sso-portal:*"}
+// LoginWithFlow logs in to IAM Identity Center using the provided flow.
+// If flow is empty, the 'SSOLoginFlow' from the Granted config file is used.
+func LoginWithFlow(ctx context.Context, cfg aws.Config, startUrl string, scopes []string, flow Flow) (*securestorage.SSOToken, error) {
+	config, err := grantedConfig.Load()
+	if err != nil {
+		return nil, err
 	}
 
-	client, err := ssooidcClient.RegisterClient(ctx, &ssooidc.RegisterClientInput{
-		ClientName: aws.String("Granted CLI"),
-		ClientType: aws.String("public"),
-		Scopes:     scopes,
-	})
+	if flow == "" {
+		flow = Flow(config.SSOLoginFlow)
+	}
+	flow, err = ParseFlow(string(flow))
+	if err != nil {
+		return nil, err
+	}
+
+	scopes = defaultScopes(scopes)
+
+	if flow == FlowPKCE && Headless() {
+		// the loopback redirect can't reach Granted from a browser on another device
+		clio.Infof("Using the %s login flow as Granted is running in headless mode", FlowDeviceCode)
+		flow = FlowDeviceCode
+	}
+
+	if flow == FlowPKCE {
+		clio.Debugw("logging in using the authorization code flow", "startURL", startUrl)
+		return loginWithPKCE(ctx, cfg, config, startUrl, scopes)
+	}
+
+	return loginWithDeviceCode(ctx, cfg, config, startUrl, scopes)
+}
+
+// loginWithDeviceCode contains all the steps to complete a device code flow to retrieve an SSO token
+func loginWithDeviceCode(ctx context.Context, cfg aws.Config, config *grantedConfig.Config, startUrl string, scopes []string) (*securestorage.SSOToken, error) {
+	ssooidcClient := ssooidc.NewFromConfig(cfg)
+
+	client, err := ClientRegistration(ctx, cfg, startUrl, scopes)
 	if err != nil {
 		return nil, err
 	}
 
 	// authorize your device using the client registration response
 	deviceAuth, err := ssooidcClient.StartDeviceAuthorization(ctx, &ssooidc.StartDeviceAuthorizationInput{
-		ClientId:     client.ClientId,
-		ClientSecret: client.ClientSecret,
+		ClientId:     &client.ClientID,
+		ClientSecret: &client.ClientSecret,
 		StartUrl:     aws.String(startUrl),
 	})
 	if err != nil {
@@ -61,27 +107,60 @@ func Login(ctx context.Context, cfg aws.Config, startUrl string, scopes []string
 
 	// trigger OIDC login. open browser to login. close tab once login is done. press enter to continue
 	url := aws.ToString(deviceAuth.VerificationUriComplete)
-	clio.Info("If the browser does not open automatically, please open this link: " + url)
+	if Headless() {
+		printLoginURL(config, url)
+	} else {
+		clio.Info("If the browser does not open automatically, please open this link: " + url)
 
-	// check if sso browser path is set
-	config, err := grantedConfig.Load()
+		err = openBrowser(config, url)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	clio.Info("Awaiting AWS authentication in the browser")
+	clio.Info("You will be prompted to authenticate with AWS in the browser, then you will be prompted to 'Allow'")
+	clio.Infof("Code: %s", *deviceAuth.UserCode)
+
+	pc := getPollingConfig(deviceAuth)
+
+	token, err := pollToken(ctx, ssooidcClient, client.ClientSecret, client.ClientID, *deviceAuth.DeviceCode, pc)
 	if err != nil {
 		return nil, err
 	}
 
+	result := securestorage.SSOToken{
+		AccessToken:           *token.AccessToken,
+		Expiry:                time.Now().Add(time.Duration(token.ExpiresIn) * time.Second),
+		ClientID:              client.ClientID,
+		ClientSecret:          client.ClientSecret,
+		RegistrationExpiresAt: client.ExpiresAt,
+		RefreshToken:          token.RefreshToken,
+		Region:                cfg.Region,
+		StartURL:              startUrl,
+		Scopes:                scopes,
+		Flow:                  string(FlowDeviceCode),
+	}
+
+	return &result, nil
+}
+
+// openBrowser opens the URL using the SSO browser configured in the Granted config file,
+// falling back to the system default browser.
+func openBrowser(config *grantedConfig.Config, url string) error {
 	if config.SSOBrowserLaunchTemplate != nil {
 		l, err := launcher.CustomFromLaunchTemplate(config.SSOBrowserLaunchTemplate, []string{})
 		if err == launcher.ErrLaunchTemplateNotConfigured {
-			return nil, errors.New("error configuring custom browser, ensure that [SSOBrowserLaunchTemplate] is specified in your Granted config file")
+			return errors.New("error configuring custom browser, ensure that [SSOBrowserLaunchTemplate] is specified in your Granted config file")
 		}
 		if err != nil {
-			return nil, err
+			return err
 		}
 
 		// now build the actual command to run - e.g. 'firefox --new-tab <URL>'
 		args, err := l.LaunchCommand(url, "")
 		if err != nil {
-			return nil, fmt.Errorf("error building browser launch command: %w", err)
+			return fmt.Errorf("error building browser launch command: %w", err)
 		}
 
 		var startErr error
@@ -89,7 +168,7 @@ func Login(ctx context.Context, cfg aws.Config, startUrl string, scopes []string
 			clio.Debugf("running command using forkprocess: %s", args)
 			cmd, err := forkprocess.New(args...)
 			if err != nil {
-				return nil, err
+				return err
 			}
 			startErr = cmd.Start()
 		} else {
@@ -99,7 +178,7 @@ func Login(ctx context.Context, cfg aws.Config, startUrl string, scopes []string
 		}
 
 		if startErr != nil {
-			return nil, clierr.New(fmt.Sprintf("Granted was unable to open a browser session automatically due to the following error: %s", startErr.Error()),
+			return clierr.New(fmt.Sprintf("Granted was unable to open a browser session automatically due to the following error: %s", startErr.Error()),
 				// allow them to try open the url manually
 				clierr.Info("You can open the browser session manually using the following url:"),
 				clierr.Info(url),
@@ -108,10 +187,10 @@ func Login(ctx context.Context, cfg aws.Config, startUrl string, scopes []string
 
 	} else if config.CustomSSOBrowserPath != "" {
 		cmd := exec.Command(config.CustomSSOBrowserPath, url)
-		err = cmd.Start()
+		err := cmd.Start()
 		if err != nil {
-			// fail silently
-			clio.Debug(err.Error())
+			clio.Debugw("error opening browser", "error", err)
+			printLoginURL(config, url)
 		} else {
 			// detach from this new process because it continues to run
 			err = cmd.Process.Release()
@@ -121,33 +200,13 @@ func Login(ctx context.Context, cfg aws.Config, startUrl string, scopes []string
 			}
 		}
 	} else {
-		err = browser.OpenURL(url)
+		err := browser.OpenURL(url)
 		if err != nil {
-			// fail silently
-			clio.Debug(err.Error())
+			// there may not be a browser to open, such as on a server which isn't accessed over SSH
+			clio.Debugw("error opening browser", "error", err)
+			printLoginURL(config, url)
 		}
 	}
 
-	clio.Info("Awaiting AWS authentication in the browser")
-	clio.Info("You will be prompted to authenticate with AWS in the browser, then you will be prompted to 'Allow'")
-	clio.Infof("Code: %s", *deviceAuth.UserCode)
-
-	pc := getPollingConfig(deviceAuth)
-
-	token, err := pollToken(ctx, ssooidcClient, *client.ClientSecret, *client.ClientId, *deviceAuth.DeviceCode, pc)
-	if err != nil {
-		return nil, err
-	}
-
-	result := securestorage.SSOToken{
-		AccessToken:           *token.AccessToken,
-		Expiry:                time.Now().Add(time.Duration(token.ExpiresIn) * time.Second),
-		ClientID:              *client.ClientId,
-		ClientSecret:          *client.ClientSecret,
-		RegistrationExpiresAt: time.Unix(client.ClientSecretExpiresAt, 0),
-		RefreshToken:          token.RefreshToken,
-		Region:                cfg.Region,
-	}
-
-	return &result, nil
+	return nil
 }
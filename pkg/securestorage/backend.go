@@ -0,0 +1,103 @@
+package securestorage
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/99designs/keyring"
+	"github.com/common-fate/granted/pkg/config"
+)
+
+// Backend stores the items for a SecureStorage. Get returns keyring.ErrKeyNotFound
+// if the item doesn't exist.
+//
+// keyring.Keyring implements Backend, so any keyring can be used as a backend.
+type Backend interface {
+	Get(key string) (keyring.Item, error)
+	Set(item keyring.Item) error
+	Remove(key string) error
+	Keys() ([]string, error)
+}
+
+// OpenBackendFunc opens the backend for the storage with the suffix.
+type OpenBackendFunc func(cfg *config.Config, storageSuffix string) (Backend, error)
+
+const (
+	KeyringBackend      = "keyring"
+	VaultTransitBackend = "vault-transit"
+	OnePasswordBackend  = "1password"
+	AgeBackend          = "age"
+)
+
+var (
+	backendsMu   sync.Mutex
+	backendTypes = map[string]OpenBackendFunc{
+		KeyringBackend:       openKeyringBackend,
+		VaultTransitBackend:  openVaultTransitBackend,
+		OnePasswordBackend:   openOnePasswordBackend,
+		AgeBackend:           openAgeBackend,
+		EncryptedFileBackend: openEncryptedFileBackend,
+	}
+	// backends are the opened backends, keyed by storage suffix. They are kept open for the
+	// life of the process, so that the config is loaded and the keyring is unlocked only once.
+	backends = map[string]Backend{}
+)
+
+// RegisterBackend adds a backend which can be selected in the SecureStorage config.
+func RegisterBackend(name string, open OpenBackendFunc) {
+	backendsMu.Lock()
+	defer backendsMu.Unlock()
+	backendTypes[name] = open
+}
+
+// openBackend returns the backend for the storage with the suffix, opening it if needed.
+func openBackend(storageSuffix string) (Backend, error) {
+	backendsMu.Lock()
+	defer backendsMu.Unlock()
+
+	if b, ok := backends[storageSuffix]; ok {
+		return b, nil
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		return nil, err
+	}
+
+	name := backendName(cfg, storageSuffix)
+	open, ok := backendTypes[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown secure storage backend %q for storage %s, valid backends are: [%s]", name, storageSuffix, strings.Join(backendNames(), ", "))
+	}
+
+	b, err := open(cfg, storageSuffix)
+	if err != nil {
+		return nil, err
+	}
+	backends[storageSuffix] = b
+	return b, nil
+}
+
+func backendName(cfg *config.Config, storageSuffix string) string {
+	if cfg.SecureStorage == nil {
+		return KeyringBackend
+	}
+	if name, ok := cfg.SecureStorage.Storages[storageSuffix]; ok {
+		return name
+	}
+	if cfg.SecureStorage.Backend != "" {
+		return cfg.SecureStorage.Backend
+	}
+	return KeyringBackend
+}
+
+func backendNames() []string {
+	var names []string
+	for name := range backendTypes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
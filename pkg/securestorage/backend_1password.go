@@ -0,0 +1,285 @@
+package securestorage
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os/exec"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/99designs/keyring"
+	"github.com/common-fate/granted/pkg/config"
+)
+
+// onePasswordBackend stores items as secure notes in a 1Password vault, using the 1Password CLI ('op').
+// Items are titled and tagged with the storage name, so that several storages can share a vault.
+// Items are found by listing the storage's tag and are then addressed by ID, as 'op' can't address
+// an item by title if several items share the title.
+type onePasswordBackend struct {
+	Vault   string
+	Account string
+	// Tag is added to every item in the storage, and is used to list them.
+	Tag string
+
+	mu sync.Mutex
+	// ids are the IDs of the items for each key, most recently updated first. The backend is kept open
+	// for the whole process, so the items are only listed again if a key or an ID isn't found,
+	// as it may have been changed by another process.
+	ids map[string][]string
+}
+
+// onePasswordItem is the subset of the 'op' JSON item format which is used by the backend.
+type onePasswordItem struct {
+	ID        string             `json:"id,omitempty"`
+	Title     string             `json:"title"`
+	UpdatedAt time.Time          `json:"updated_at,omitempty"`
+	Category  string             `json:"category,omitempty"`
+	Tags      []string           `json:"tags,omitempty"`
+	Fields    []onePasswordField `json:"fields,omitempty"`
+}
+
+type onePasswordField struct {
+	ID      string `json:"id"`
+	Type    string `json:"type,omitempty"`
+	Purpose string `json:"purpose,omitempty"`
+	Label   string `json:"label,omitempty"`
+	Value   string `json:"value"`
+}
+
+func openOnePasswordBackend(cfg *config.Config, storageSuffix string) (Backend, error) {
+	oc := cfg.SecureStorage.OnePassword
+	if oc == nil || oc.Vault == "" {
+		return nil, errors.New("the 1password secure storage backend requires SecureStorage.OnePassword.Vault to be set in the Granted config")
+	}
+	if _, err := exec.LookPath("op"); err != nil {
+		return nil, fmt.Errorf("the 1password secure storage backend requires the 1Password CLI ('op') to be installed: %w", err)
+	}
+	return &onePasswordBackend{Vault: oc.Vault, Account: oc.Account, Tag: "granted-" + storageSuffix}, nil
+}
+
+func (b *onePasswordBackend) title(key string) string {
+	return b.Tag + "/" + key
+}
+
+// list returns the items in the storage, most recently updated first.
+func (b *onePasswordBackend) list() ([]onePasswordItem, error) {
+	out, err := b.op(nil, "item", "list", "--tags", b.Tag, "--format", "json")
+	if err != nil {
+		return nil, err
+	}
+	var items []onePasswordItem
+	err = json.Unmarshal(out, &items)
+	if err != nil {
+		return nil, err
+	}
+	sort.SliceStable(items, func(i, j int) bool { return items[i].UpdatedAt.After(items[j].UpdatedAt) })
+	return items, nil
+}
+
+// refresh lists the items in the storage and replaces the cached IDs.
+func (b *onePasswordBackend) refresh() error {
+	items, err := b.list()
+	if err != nil {
+		return err
+	}
+	b.ids = map[string][]string{}
+	for _, item := range items {
+		if key, ok := strings.CutPrefix(item.Title, b.Tag+"/"); ok {
+			b.ids[key] = append(b.ids[key], item.ID)
+		}
+	}
+	return nil
+}
+
+// itemIDs returns the IDs of the items for the key, most recently updated first.
+// There is usually one item, but there may be several if an item was created by hand or by an interrupted Set.
+// If refresh is true or the key isn't cached, the items are listed again.
+func (b *onePasswordBackend) itemIDs(key string, refresh bool) ([]string, error) {
+	if ids, ok := b.ids[key]; ok && !refresh {
+		return ids, nil
+	}
+	err := b.refresh()
+	if err != nil {
+		return nil, err
+	}
+	return b.ids[key], nil
+}
+
+func (b *onePasswordBackend) Get(key string) (keyring.Item, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	ids, err := b.itemIDs(key, false)
+	if err != nil {
+		return keyring.Item{}, err
+	}
+	if len(ids) == 0 {
+		return keyring.Item{}, keyring.ErrKeyNotFound
+	}
+
+	out, err := b.op(nil, "item", "get", ids[0], "--format", "json")
+	if errors.Is(err, keyring.ErrKeyNotFound) {
+		// the item may have been replaced by another process
+		ids, err = b.itemIDs(key, true)
+		if err != nil {
+			return keyring.Item{}, err
+		}
+		if len(ids) == 0 {
+			return keyring.Item{}, keyring.ErrKeyNotFound
+		}
+		out, err = b.op(nil, "item", "get", ids[0], "--format", "json")
+	}
+	if err != nil {
+		return keyring.Item{}, err
+	}
+	var item onePasswordItem
+	err = json.Unmarshal(out, &item)
+	if err != nil {
+		return keyring.Item{}, err
+	}
+	for _, f := range item.Fields {
+		if f.ID == "notesPlain" {
+			data, err := base64.StdEncoding.DecodeString(f.Value)
+			if err != nil {
+				return keyring.Item{}, err
+			}
+			return keyring.Item{Key: key, Data: data}, nil
+		}
+	}
+	return keyring.Item{}, fmt.Errorf("1Password item %s doesn't have a notes field", b.title(key))
+}
+
+// Set updates the existing item for the key in place, so that the previous value is kept if the update fails.
+// The item is only created if it doesn't exist, and any duplicate items for the key are removed.
+func (b *onePasswordBackend) Set(item keyring.Item) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	template, err := json.Marshal(onePasswordItem{
+		Title:    b.title(item.Key),
+		Category: "SECURE_NOTE",
+		Tags:     []string{b.Tag},
+		Fields: []onePasswordField{{
+			ID:      "notesPlain",
+			Type:    "STRING",
+			Purpose: "NOTES",
+			Label:   "notesPlain",
+			Value:   base64.StdEncoding.EncodeToString(item.Data),
+		}},
+	})
+	if err != nil {
+		return err
+	}
+
+	// the items are listed again before creating an item, so that an item created by another process isn't duplicated
+	ids, err := b.itemIDs(item.Key, false)
+	if err == nil && len(ids) == 0 {
+		ids, err = b.itemIDs(item.Key, true)
+	}
+	if err != nil {
+		return err
+	}
+
+	// the item is passed on stdin rather than as arguments, so that it isn't visible in the process list
+	if len(ids) > 0 {
+		_, err = b.op(template, "item", "edit", ids[0])
+		if errors.Is(err, keyring.ErrKeyNotFound) {
+			// the item may have been removed by another process
+			ids, err = b.itemIDs(item.Key, true)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	if len(ids) == 0 {
+		out, err := b.op(template, "item", "create", "-", "--format", "json")
+		if err != nil {
+			return err
+		}
+		var created onePasswordItem
+		err = json.Unmarshal(out, &created)
+		if err != nil {
+			return err
+		}
+		b.ids[item.Key] = []string{created.ID}
+		return nil
+	}
+
+	for _, id := range ids[1:] {
+		_, err = b.op(nil, "item", "delete", id)
+		if err != nil && !errors.Is(err, keyring.ErrKeyNotFound) {
+			return fmt.Errorf("removing duplicate 1Password item %s: %w", b.title(item.Key), err)
+		}
+	}
+	b.ids[item.Key] = ids[:1]
+	return nil
+}
+
+// Remove deletes every item for the key.
+func (b *onePasswordBackend) Remove(key string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	ids, err := b.itemIDs(key, true)
+	if err != nil {
+		return err
+	}
+	if len(ids) == 0 {
+		return keyring.ErrKeyNotFound
+	}
+	for _, id := range ids {
+		_, err = b.op(nil, "item", "delete", id)
+		if err != nil && !errors.Is(err, keyring.ErrKeyNotFound) {
+			return err
+		}
+	}
+	delete(b.ids, key)
+	return nil
+}
+
+func (b *onePasswordBackend) Keys() ([]string, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	err := b.refresh()
+	if err != nil {
+		return nil, err
+	}
+	var keys []string
+	for key := range b.ids {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys, nil
+}
+
+// op runs the 1Password CLI against the configured vault and returns its output.
+// It returns keyring.ErrKeyNotFound if the item doesn't exist.
+func (b *onePasswordBackend) op(stdin []byte, args ...string) ([]byte, error) {
+	args = append(args, "--vault", b.Vault)
+	if b.Account != "" {
+		args = append(args, "--account", b.Account)
+	}
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command("op", args...)
+	if stdin != nil {
+		cmd.Stdin = bytes.NewReader(stdin)
+	}
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	err := cmd.Run()
+	if err != nil {
+		if strings.Contains(stderr.String(), "isn't an item") {
+			return nil, keyring.ErrKeyNotFound
+		}
+		return nil, fmt.Errorf("running 'op %s': %w: %s", strings.Join(args[:2], " "), err, strings.TrimSpace(stderr.String()))
+	}
+	return stdout.Bytes(), nil
+}
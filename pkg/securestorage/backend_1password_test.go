@@ -0,0 +1,191 @@
+package securestorage
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/99designs/keyring"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeOPStateEnv is the file that the fake 'op' CLI stores its items in.
+const fakeOPStateEnv = "GRANTED_TEST_FAKE_OP_STATE"
+
+// fakeOPLogEnv is the file that the fake 'op' CLI appends the subcommand of each call to.
+const fakeOPLogEnv = "GRANTED_TEST_FAKE_OP_LOG"
+
+func TestOnePasswordBackend(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the fake 'op' CLI is a shell script")
+	}
+
+	// put a fake 'op' CLI on the PATH which runs TestFakeOP in this test binary
+	bin := t.TempDir()
+	script := fmt.Sprintf("#!/bin/sh\nexec %q -test.run=^TestFakeOP$ -- \"$@\"\n", os.Args[0])
+	require.NoError(t, os.WriteFile(filepath.Join(bin, "op"), []byte(script), 0700))
+	t.Setenv("PATH", bin+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+	// two items share the title of the 'prod' key, as if one was created by an interrupted Set
+	state := filepath.Join(t.TempDir(), "items.json")
+	t.Setenv(fakeOPStateEnv, state)
+	log := filepath.Join(t.TempDir(), "calls.log")
+	t.Setenv(fakeOPLogEnv, log)
+	now := time.Now()
+	writeFakeOPItems(t, state, []onePasswordItem{
+		fakeOPItem("old", "granted-test/prod", "b2xk", now.Add(-time.Hour)),
+		fakeOPItem("new", "granted-test/prod", "bmV3", now),
+		fakeOPItem("other", "granted-other/prod", "b3RoZXI=", now),
+	})
+
+	b := &onePasswordBackend{Vault: "Private", Tag: "granted-test"}
+
+	item, err := b.Get("prod")
+	require.NoError(t, err)
+	assert.Equal(t, "new", string(item.Data), "the most recently updated item should be read")
+
+	// the item IDs are cached, so reading the key again doesn't list the items
+	require.NoError(t, os.Remove(log))
+	_, err = b.Get("prod")
+	require.NoError(t, err)
+	calls, err := os.ReadFile(log)
+	require.NoError(t, err)
+	assert.Equal(t, "get\n", string(calls))
+
+	keys, err := b.Keys()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"prod"}, keys)
+
+	// the newest item is edited in place, and the duplicate is removed
+	require.NoError(t, b.Set(keyring.Item{Key: "prod", Data: []byte("updated")}))
+	items := readFakeOPItems(t, state)
+	assert.Equal(t, []string{"new", "other"}, fakeOPItemIDs(items))
+	item, err = b.Get("prod")
+	require.NoError(t, err)
+	assert.Equal(t, "updated", string(item.Data))
+
+	require.NoError(t, b.Set(keyring.Item{Key: "dev", Data: []byte("created")}))
+	item, err = b.Get("dev")
+	require.NoError(t, err)
+	assert.Equal(t, "created", string(item.Data))
+
+	// the item is replaced by another process, so the cached ID is stale
+	writeFakeOPItems(t, state, append(readFakeOPItems(t, state)[:2], fakeOPItem("replaced", "granted-test/dev", "cmVwbGFjZWQ=", now)))
+	item, err = b.Get("dev")
+	require.NoError(t, err)
+	assert.Equal(t, "replaced", string(item.Data))
+
+	require.NoError(t, b.Remove("prod"))
+	_, err = b.Get("prod")
+	assert.ErrorIs(t, err, keyring.ErrKeyNotFound)
+	assert.ErrorIs(t, b.Remove("prod"), keyring.ErrKeyNotFound)
+}
+
+// TestFakeOP is run by the fake 'op' CLI in TestOnePasswordBackend. It implements the
+// 'op item' subcommands used by the backend against a JSON file of items.
+func TestFakeOP(t *testing.T) {
+	state := os.Getenv(fakeOPStateEnv)
+	if state == "" {
+		t.Skip("only run as the fake 'op' CLI")
+	}
+	args := os.Args[slices.Index(os.Args, "--")+1:]
+	items := readFakeOPItems(t, state)
+
+	log, err := os.OpenFile(os.Getenv(fakeOPLogEnv), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	require.NoError(t, err)
+	_, err = fmt.Fprintln(log, args[1])
+	require.NoError(t, err)
+	require.NoError(t, log.Close())
+
+	fail := func(msg string) {
+		fmt.Fprintln(os.Stderr, msg)
+		os.Exit(1)
+	}
+	find := func(id string) int {
+		i := slices.IndexFunc(items, func(item onePasswordItem) bool { return item.ID == id })
+		if i == -1 {
+			fail(fmt.Sprintf("[ERROR] %q isn't an item in the \"Private\" vault", id))
+		}
+		return i
+	}
+	readTemplate := func() onePasswordItem {
+		var item onePasswordItem
+		data, err := io.ReadAll(os.Stdin)
+		require.NoError(t, err)
+		require.NoError(t, json.Unmarshal(data, &item))
+		return item
+	}
+
+	switch args[1] {
+	case "list":
+		tag := args[slices.Index(args, "--tags")+1]
+		var listed []onePasswordItem
+		for _, item := range items {
+			if slices.Contains(item.Tags, tag) {
+				item.Fields = nil
+				listed = append(listed, item)
+			}
+		}
+		require.NoError(t, json.NewEncoder(os.Stdout).Encode(listed))
+	case "get":
+		require.NoError(t, json.NewEncoder(os.Stdout).Encode(items[find(args[2])]))
+	case "create":
+		item := readTemplate()
+		item.ID = fmt.Sprintf("created-%d", len(items))
+		item.UpdatedAt = time.Now()
+		items = append(items, item)
+		require.NoError(t, json.NewEncoder(os.Stdout).Encode(item))
+	case "edit":
+		i := find(args[2])
+		item := readTemplate()
+		item.ID = items[i].ID
+		item.UpdatedAt = time.Now()
+		items[i] = item
+	case "delete":
+		items = slices.Delete(items, find(args[2]), find(args[2])+1)
+	default:
+		fail("unknown command " + args[1])
+	}
+
+	writeFakeOPItems(t, state, items)
+	os.Exit(0)
+}
+
+func fakeOPItem(id string, title string, notes string, updatedAt time.Time) onePasswordItem {
+	return onePasswordItem{
+		ID:        id,
+		Title:     title,
+		UpdatedAt: updatedAt,
+		Tags:      []string{filepath.Dir(title)},
+		Fields:    []onePasswordField{{ID: "notesPlain", Value: notes}},
+	}
+}
+
+func fakeOPItemIDs(items []onePasswordItem) []string {
+	var ids []string
+	for _, item := range items {
+		ids = append(ids, item.ID)
+	}
+	return ids
+}
+
+func readFakeOPItems(t *testing.T, state string) []onePasswordItem {
+	var items []onePasswordItem
+	data, err := os.ReadFile(state)
+	require.NoError(t, err)
+	require.NoError(t, json.Unmarshal(data, &items))
+	return items
+}
+
+func writeFakeOPItems(t *testing.T, state string, items []onePasswordItem) {
+	data, err := json.Marshal(items)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(state, data, 0600))
+}
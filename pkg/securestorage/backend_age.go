@@ -0,0 +1,80 @@
+package securestorage
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/common-fate/granted/pkg/config"
+)
+
+// ageCLI encrypts items using the age CLI (https://age-encryption.org).
+type ageCLI struct {
+	Recipients   []string
+	IdentityFile string
+}
+
+func openAgeBackend(cfg *config.Config, storageSuffix string) (Backend, error) {
+	ac := cfg.SecureStorage.Age
+	if ac == nil || len(ac.Recipients) == 0 || ac.IdentityFile == "" {
+		return nil, errors.New("the age secure storage backend requires SecureStorage.Age.Recipients and SecureStorage.Age.IdentityFile to be set in the Granted config")
+	}
+	if _, err := exec.LookPath("age"); err != nil {
+		return nil, fmt.Errorf("the age secure storage backend requires the age CLI to be installed: %w", err)
+	}
+
+	identityFile, err := expandHome(ac.IdentityFile)
+	if err != nil {
+		return nil, err
+	}
+
+	dir, err := secureStorageDir(ac.Dir, AgeBackend, storageSuffix)
+	if err != nil {
+		return nil, err
+	}
+
+	return &encryptedFileBackend{Dir: dir, Ext: ".age", Cipher: &ageCLI{Recipients: ac.Recipients, IdentityFile: identityFile}}, nil
+}
+
+func (a *ageCLI) Encrypt(key string, plaintext []byte) ([]byte, error) {
+	args := []string{"--encrypt"}
+	for _, r := range a.Recipients {
+		args = append(args, "--recipient", r)
+	}
+	return a.run(args, plaintext)
+}
+
+func (a *ageCLI) Decrypt(key string, ciphertext []byte) ([]byte, error) {
+	return a.run([]string{"--decrypt", "--identity", a.IdentityFile}, ciphertext)
+}
+
+func (a *ageCLI) run(args []string, stdin []byte) ([]byte, error) {
+	var stdout bytes.Buffer
+	cmd := exec.Command("age", args...)
+	cmd.Stdin = bytes.NewReader(stdin)
+	cmd.Stdout = &stdout
+	// age prompts for the passphrase of an encrypted identity on the terminal, and writes errors to stderr
+	cmd.Stderr = os.Stderr
+	err := cmd.Run()
+	if err != nil {
+		return nil, fmt.Errorf("running age %s: %w", args[0], err)
+	}
+	return stdout.Bytes(), nil
+}
+
+// expandHome resolves a leading '~/' in the path to the user's home folder.
+func expandHome(path string) (string, error) {
+	rest, ok := strings.CutPrefix(path, "~/")
+	if !ok {
+		return path, nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, rest), nil
+}
@@ -0,0 +1,461 @@
+package securestorage
+
+import (
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/common-fate/clio"
+	"github.com/common-fate/granted/pkg/config"
+	"github.com/common-fate/granted/pkg/filelock"
+	"golang.org/x/crypto/chacha20poly1305"
+	"golang.org/x/crypto/hkdf"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+)
+
+// EncryptedFileBackend stores items in files encrypted with a key from an environment variable, a file or
+// an SSH agent, so that it can be used on servers and in CI without a keyring or a password prompt.
+// It is selected by setting Keyring.Backend to 'encrypted-file'.
+const EncryptedFileBackend = "encrypted-file"
+
+// EncryptedFileKeyEnv is the environment variable which the encrypted-file key is read from.
+// If it is set, it takes priority over the key configured in the Granted config.
+const EncryptedFileKeyEnv = "GRANTED_ENCRYPTED_FILE_KEY"
+
+// minEncryptedFileKeyLength is the minimum length of the key material. The key isn't stretched,
+// so it must be random rather than a memorable password.
+const minEncryptedFileKeyLength = 32
+
+// sshAgentChallenge is signed by the SSH agent to derive the key. The signatures made by Ed25519 and
+// RSA keys are deterministic, so the same key is derived each time.
+const sshAgentChallenge = "granted encrypted-file key v1"
+
+// encryptedFileKeyInfo is the HKDF info used to derive the encryption key from the key material.
+const encryptedFileKeyInfo = "granted encrypted-file xchacha20poly1305 v1"
+
+// encryptedFileLockTimeout is how long to wait for another Granted process to release the lock on the
+// encrypted-file storages, such as while they are being rekeyed.
+var encryptedFileLockTimeout = 30 * time.Second
+
+// EncryptedFileKeySource is where the key for the encrypted-file backend comes from. One of the fields should be set.
+type EncryptedFileKeySource struct {
+	// Env is the name of an environment variable containing the key.
+	Env string
+	// File contains the key.
+	File string
+	// SSHKey is the fingerprint or public key of a key in the SSH agent.
+	SSHKey string
+}
+
+// ConfiguredEncryptedFileKeySource returns the key source from GRANTED_ENCRYPTED_FILE_KEY or the Granted config.
+func ConfiguredEncryptedFileKeySource(cfg *config.Config) (EncryptedFileKeySource, error) {
+	if os.Getenv(EncryptedFileKeyEnv) != "" {
+		return EncryptedFileKeySource{Env: EncryptedFileKeyEnv}, nil
+	}
+	if cfg.Keyring != nil && cfg.Keyring.EncryptedFileKeyFile != nil {
+		return EncryptedFileKeySource{File: *cfg.Keyring.EncryptedFileKeyFile}, nil
+	}
+	if cfg.Keyring != nil && cfg.Keyring.EncryptedFileSSHKey != nil {
+		return EncryptedFileKeySource{SSHKey: *cfg.Keyring.EncryptedFileSSHKey}, nil
+	}
+	return EncryptedFileKeySource{}, fmt.Errorf("the encrypted-file secure storage backend requires a key: set %s, or set Keyring.EncryptedFileKeyFile or Keyring.EncryptedFileSSHKey in the Granted config", EncryptedFileKeyEnv)
+}
+
+// Key reads the key material from the source.
+func (s EncryptedFileKeySource) Key() ([]byte, error) {
+	var key []byte
+	switch {
+	case s.Env != "":
+		key = []byte(os.Getenv(s.Env))
+		if len(key) == 0 {
+			return nil, fmt.Errorf("the encrypted-file key environment variable %s is empty", s.Env)
+		}
+	case s.File != "":
+		filename, err := expandHome(s.File)
+		if err != nil {
+			return nil, err
+		}
+		b, err := os.ReadFile(filename)
+		if err != nil {
+			return nil, fmt.Errorf("reading encrypted-file key: %w", err)
+		}
+		key = []byte(strings.TrimSpace(string(b)))
+	case s.SSHKey != "":
+		return sshAgentKey(s.SSHKey)
+	default:
+		return nil, errors.New("no encrypted-file key source was provided")
+	}
+
+	if len(key) < minEncryptedFileKeyLength {
+		return nil, fmt.Errorf("the encrypted-file key must be at least %d characters long, generate one with 'openssl rand -base64 32'", minEncryptedFileKeyLength)
+	}
+	return key, nil
+}
+
+// sshAgentKey returns a signature of sshAgentChallenge made by the key in the SSH agent.
+func sshAgentKey(fingerprintOrPublicKey string) ([]byte, error) {
+	sock := os.Getenv("SSH_AUTH_SOCK")
+	if sock == "" {
+		return nil, errors.New("SSH_AUTH_SOCK isn't set, an SSH agent is required to read the encrypted-file key")
+	}
+	conn, err := net.Dial("unix", sock)
+	if err != nil {
+		return nil, fmt.Errorf("connecting to the SSH agent: %w", err)
+	}
+	defer conn.Close()
+
+	client := agent.NewClient(conn)
+	keys, err := client.List()
+	if err != nil {
+		return nil, fmt.Errorf("listing SSH agent keys: %w", err)
+	}
+
+	want := strings.TrimSpace(fingerprintOrPublicKey)
+	if pub, _, _, _, err := ssh.ParseAuthorizedKey([]byte(want)); err == nil {
+		want = ssh.FingerprintSHA256(pub)
+	}
+
+	for _, k := range keys {
+		if ssh.FingerprintSHA256(k) != want {
+			continue
+		}
+
+		var flags agent.SignatureFlags
+		switch k.Type() {
+		case ssh.KeyAlgoED25519:
+		case ssh.KeyAlgoRSA:
+			flags = agent.SignatureFlagRsaSha256
+		default:
+			// other signature algorithms, such as ECDSA, are randomised and can't be used to derive a key
+			return nil, fmt.Errorf("SSH key %s has type %s, only Ed25519 and RSA keys can be used for the encrypted-file key", want, k.Type())
+		}
+
+		sig, err := client.SignWithFlags(k, []byte(sshAgentChallenge), flags)
+		if err != nil {
+			return nil, fmt.Errorf("signing with SSH key %s: %w", want, err)
+		}
+		return sig.Blob, nil
+	}
+	return nil, fmt.Errorf("SSH key %s wasn't found in the SSH agent", want)
+}
+
+// aeadCipher encrypts items using XChaCha20-Poly1305. The item key is used as additional data,
+// so that an encrypted item can't be copied to another key.
+type aeadCipher struct {
+	AEAD cipher.AEAD
+}
+
+func newAEADCipher(keyMaterial []byte) (*aeadCipher, error) {
+	key := make([]byte, chacha20poly1305.KeySize)
+	_, err := io.ReadFull(hkdf.New(sha256.New, keyMaterial, nil, []byte(encryptedFileKeyInfo)), key)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := chacha20poly1305.NewX(key)
+	if err != nil {
+		return nil, err
+	}
+	return &aeadCipher{AEAD: aead}, nil
+}
+
+func (c *aeadCipher) Encrypt(key string, plaintext []byte) ([]byte, error) {
+	nonce := make([]byte, c.AEAD.NonceSize(), c.AEAD.NonceSize()+len(plaintext)+c.AEAD.Overhead())
+	_, err := rand.Read(nonce)
+	if err != nil {
+		return nil, err
+	}
+	return c.AEAD.Seal(nonce, nonce, plaintext, []byte(key)), nil
+}
+
+func (c *aeadCipher) Decrypt(key string, ciphertext []byte) ([]byte, error) {
+	if len(ciphertext) < c.AEAD.NonceSize() {
+		return nil, fmt.Errorf("encrypted item %s is too short", key)
+	}
+	nonce, ciphertext := ciphertext[:c.AEAD.NonceSize()], ciphertext[c.AEAD.NonceSize():]
+	plaintext, err := c.AEAD.Open(nil, nonce, ciphertext, []byte(key))
+	if err != nil {
+		return nil, fmt.Errorf("decrypting item %s, the encrypted-file key may be incorrect: %w", key, err)
+	}
+	return plaintext, nil
+}
+
+// encryptedFileRoot returns the folder containing a folder for each storage. It is Keyring.FileDir if set.
+func encryptedFileRoot(cfg *config.Config) (string, error) {
+	var dir string
+	if cfg.Keyring != nil && cfg.Keyring.FileDir != nil {
+		dir = *cfg.Keyring.FileDir
+	}
+	root, err := secureStorageDir(dir, EncryptedFileBackend, "")
+	if err != nil {
+		return "", err
+	}
+	return expandHome(root)
+}
+
+func newEncryptedFileBackend(dir string, keyMaterial []byte) (*encryptedFileBackend, error) {
+	c, err := newAEADCipher(keyMaterial)
+	if err != nil {
+		return nil, err
+	}
+	return &encryptedFileBackend{Dir: dir, Ext: ".enc", Cipher: c}, nil
+}
+
+func openEncryptedFileBackend(cfg *config.Config, storageSuffix string) (Backend, error) {
+	source, err := ConfiguredEncryptedFileKeySource(cfg)
+	if err != nil {
+		return nil, err
+	}
+	key, err := source.Key()
+	if err != nil {
+		return nil, err
+	}
+	root, err := encryptedFileRoot(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	lock, err := filelock.Lock(encryptedFileLockName(root), encryptedFileLockTimeout)
+	if err != nil {
+		return nil, err
+	}
+	err = recoverRekey(root)
+	_ = lock.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	b, err := newEncryptedFileBackend(filepath.Join(root, storageSuffix), key)
+	if err != nil {
+		return nil, err
+	}
+	b.Lock = encryptedFileLockName(root)
+	return b, nil
+}
+
+// encryptedFileLockName is the name of the lock shared by every storage in the root folder.
+// It is held while items are written or removed, and for the whole of a rekey.
+func encryptedFileLockName(root string) string {
+	hash := sha256.Sum256([]byte(root))
+	return "encrypted-file-" + hex.EncodeToString(hash[:8])
+}
+
+// recoverRekey puts back the storages left behind by a rekey which didn't finish, such as if
+// the process was killed. Until a rekey finishes the configured key is the old key, so the ".old"
+// folders replace the rekeyed ones, and the staged ".rekey" folders are removed.
+// It must be called while holding the lock from encryptedFileLockName.
+func recoverRekey(root string) error {
+	entries, err := os.ReadDir(root)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	for _, e := range entries {
+		if !e.IsDir() {
+			continue
+		}
+		if dir, ok := strings.CutSuffix(filepath.Join(root, e.Name()), ".old"); ok {
+			clio.Warnf("Restoring encrypted-file storage %s, which was left behind by a rekey which didn't finish", filepath.Base(dir))
+			err = restoreRekeyedDir(dir)
+			if err != nil {
+				return fmt.Errorf("restoring storage %s: %w", filepath.Base(dir), err)
+			}
+		}
+	}
+	for _, e := range entries {
+		if e.IsDir() && strings.HasSuffix(e.Name(), ".rekey") {
+			err = os.RemoveAll(filepath.Join(root, e.Name()))
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// RekeyEncryptedFile re-encrypts every item stored by the encrypted-file backend with the new key,
+// and returns the number of items which were re-encrypted. The current key is read from the configured source.
+//
+// Every storage is first written to a new folder encrypted with the new key. The new folders then replace
+// the existing ones, and if any of them can't be replaced the storages which were already replaced are put back,
+// so that a failure part way through doesn't leave storages encrypted with different keys.
+// Other Granted processes can't write or remove items while the storages are being rekeyed.
+func RekeyEncryptedFile(cfg *config.Config, newKey EncryptedFileKeySource) (int, error) {
+	newKeyMaterial, err := newKey.Key()
+	if err != nil {
+		return 0, err
+	}
+	root, err := encryptedFileRoot(cfg)
+	if err != nil {
+		return 0, err
+	}
+
+	lock, err := filelock.Lock(encryptedFileLockName(root), encryptedFileLockTimeout)
+	if err != nil {
+		return 0, err
+	}
+	defer func() { _ = lock.Unlock() }()
+
+	err = recoverRekey(root)
+	if err != nil {
+		return 0, err
+	}
+
+	entries, err := os.ReadDir(root)
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	var dirs []string
+	for _, e := range entries {
+		if e.IsDir() && !strings.Contains(e.Name(), ".") {
+			dirs = append(dirs, filepath.Join(root, e.Name()))
+		}
+	}
+	if len(dirs) == 0 {
+		return 0, nil
+	}
+
+	source, err := ConfiguredEncryptedFileKeySource(cfg)
+	if err != nil {
+		return 0, err
+	}
+	oldKeyMaterial, err := source.Key()
+	if err != nil {
+		return 0, err
+	}
+
+	// the staged folders are removed if anything fails before they have all replaced the existing ones
+	defer func() {
+		for _, dir := range dirs {
+			_ = os.RemoveAll(dir + ".rekey")
+		}
+	}()
+
+	var count int
+	for _, dir := range dirs {
+		n, err := stageRekey(dir, oldKeyMaterial, newKeyMaterial)
+		if err != nil {
+			return 0, fmt.Errorf("rekeying storage %s: %w", filepath.Base(dir), err)
+		}
+		count += n
+	}
+
+	err = swapRekeyedDirs(dirs)
+	if err != nil {
+		return 0, err
+	}
+
+	// backends which are already open use the old key
+	backendsMu.Lock()
+	clear(backends)
+	backendsMu.Unlock()
+
+	for _, dir := range dirs {
+		err = os.RemoveAll(dir + ".old")
+		if err != nil {
+			return count, err
+		}
+	}
+	return count, nil
+}
+
+// stageRekey writes the items in dir, re-encrypted with the new key, to the folder dir + ".rekey".
+// It returns the number of items which were written.
+func stageRekey(dir string, oldKeyMaterial, newKeyMaterial []byte) (int, error) {
+	oldBackend, err := newEncryptedFileBackend(dir, oldKeyMaterial)
+	if err != nil {
+		return 0, err
+	}
+	newDir := dir + ".rekey"
+	err = os.RemoveAll(newDir)
+	if err != nil {
+		return 0, err
+	}
+	newBackend, err := newEncryptedFileBackend(newDir, newKeyMaterial)
+	if err != nil {
+		return 0, err
+	}
+
+	keys, err := oldBackend.Keys()
+	if err != nil {
+		return 0, err
+	}
+	for _, key := range keys {
+		item, err := oldBackend.Get(key)
+		if err != nil {
+			return 0, err
+		}
+		err = newBackend.Set(item)
+		if err != nil {
+			return 0, err
+		}
+	}
+	err = os.MkdirAll(newDir, 0700)
+	if err != nil {
+		return 0, err
+	}
+	return len(keys), nil
+}
+
+// swapRekeyedDirs replaces each folder with its staged ".rekey" folder, moving the existing folder to ".old".
+// If a folder can't be replaced, the folders which were already replaced are put back.
+func swapRekeyedDirs(dirs []string) error {
+	var swapped []string
+	for _, dir := range dirs {
+		err := swapRekeyedDir(dir)
+		if err != nil {
+			for _, s := range swapped {
+				if rerr := restoreRekeyedDir(s); rerr != nil {
+					err = errors.Join(err, fmt.Errorf("restoring storage %s: %w", filepath.Base(s), rerr))
+				}
+			}
+			return fmt.Errorf("replacing storage %s: %w", filepath.Base(dir), err)
+		}
+		swapped = append(swapped, dir)
+	}
+	return nil
+}
+
+func swapRekeyedDir(dir string) error {
+	oldDir := dir + ".old"
+	err := os.RemoveAll(oldDir)
+	if err != nil {
+		return err
+	}
+	err = os.Rename(dir, oldDir)
+	if err != nil {
+		return err
+	}
+	err = os.Rename(dir+".rekey", dir)
+	if err != nil {
+		// put the storage back, so that it can still be read with the old key
+		_ = os.Rename(oldDir, dir)
+		return err
+	}
+	return nil
+}
+
+// restoreRekeyedDir puts back a folder which was replaced by swapRekeyedDir, or which was
+// only moved to ".old" if the process was killed part way through swapRekeyedDir.
+func restoreRekeyedDir(dir string) error {
+	err := os.RemoveAll(dir)
+	if err != nil {
+		return err
+	}
+	return os.Rename(dir+".old", dir)
+}
@@ -0,0 +1,222 @@
+package securestorage
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/99designs/keyring"
+	"github.com/common-fate/granted/pkg/config"
+	"github.com/common-fate/granted/pkg/filelock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+)
+
+const (
+	testKey    = "MDEyMzQ1Njc4OWFiY2RlZjAxMjM0NTY3ODlhYmNkZWY="
+	testNewKey = "ZmVkY2JhOTg3NjU0MzIxMGZlZGNiYTk4NzY1NDMyMTA="
+)
+
+func TestEncryptedFileBackend(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	dir := t.TempDir()
+	t.Setenv(EncryptedFileKeyEnv, testKey)
+	backend := EncryptedFileBackend
+	cfg := &config.Config{Keyring: &config.KeyringConfig{Backend: &backend, FileDir: &dir}}
+
+	// the encrypted-file backend is selected using Keyring.Backend
+	b, err := openKeyringBackend(cfg, "aws-iam-credentials")
+	require.NoError(t, err)
+	require.IsType(t, &encryptedFileBackend{}, b)
+
+	err = b.Set(keyring.Item{Key: "prod", Data: []byte(`{"AccessKeyID":"AKIA"}`)})
+	require.NoError(t, err)
+	item, err := b.Get("prod")
+	require.NoError(t, err)
+	assert.Equal(t, `{"AccessKeyID":"AKIA"}`, string(item.Data))
+
+	t.Setenv(EncryptedFileKeyEnv, testNewKey)
+	b, err = openEncryptedFileBackend(cfg, "aws-iam-credentials")
+	require.NoError(t, err)
+	_, err = b.Get("prod")
+	assert.ErrorContains(t, err, "the encrypted-file key may be incorrect")
+
+	t.Setenv(EncryptedFileKeyEnv, "too-short")
+	_, err = openEncryptedFileBackend(cfg, "aws-iam-credentials")
+	assert.ErrorContains(t, err, "at least 32 characters")
+}
+
+func TestRekeyEncryptedFile(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	dir := t.TempDir()
+	t.Setenv(EncryptedFileKeyEnv, testKey)
+	t.Setenv("NEW_KEY", testNewKey)
+	cfg := &config.Config{Keyring: &config.KeyringConfig{FileDir: &dir}}
+
+	for _, storage := range []string{"aws-iam-credentials", "aws-sso-tokens"} {
+		b, err := openEncryptedFileBackend(cfg, storage)
+		require.NoError(t, err)
+		require.NoError(t, b.Set(keyring.Item{Key: "key", Data: []byte(storage)}))
+	}
+
+	count, err := RekeyEncryptedFile(cfg, EncryptedFileKeySource{Env: "NEW_KEY"})
+	require.NoError(t, err)
+	assert.Equal(t, 2, count)
+
+	t.Setenv(EncryptedFileKeyEnv, testNewKey)
+	for _, storage := range []string{"aws-iam-credentials", "aws-sso-tokens"} {
+		b, err := openEncryptedFileBackend(cfg, storage)
+		require.NoError(t, err)
+		item, err := b.Get("key")
+		require.NoError(t, err)
+		assert.Equal(t, storage, string(item.Data))
+	}
+
+	matches, err := filepath.Glob(filepath.Join(dir, "*.*"))
+	require.NoError(t, err)
+	assert.Empty(t, matches, "the temporary rekey folders should be removed")
+}
+
+func TestRekeyEncryptedFileFailure(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	dir := t.TempDir()
+	t.Setenv(EncryptedFileKeyEnv, testKey)
+	t.Setenv("NEW_KEY", testNewKey)
+	cfg := &config.Config{Keyring: &config.KeyringConfig{FileDir: &dir}}
+
+	b, err := openEncryptedFileBackend(cfg, "aws-iam-credentials")
+	require.NoError(t, err)
+	require.NoError(t, b.Set(keyring.Item{Key: "key", Data: []byte("credentials")}))
+
+	// an item in the second storage which can't be decrypted with the current key
+	t.Setenv(EncryptedFileKeyEnv, testNewKey)
+	b, err = openEncryptedFileBackend(cfg, "aws-sso-tokens")
+	require.NoError(t, err)
+	require.NoError(t, b.Set(keyring.Item{Key: "key", Data: []byte("token")}))
+
+	t.Setenv(EncryptedFileKeyEnv, testKey)
+	_, err = RekeyEncryptedFile(cfg, EncryptedFileKeySource{Env: "NEW_KEY"})
+	require.ErrorContains(t, err, "rekeying storage aws-sso-tokens")
+
+	// the first storage hasn't been replaced, so it can still be read with the current key
+	b, err = openEncryptedFileBackend(cfg, "aws-iam-credentials")
+	require.NoError(t, err)
+	item, err := b.Get("key")
+	require.NoError(t, err)
+	assert.Equal(t, "credentials", string(item.Data))
+
+	matches, err := filepath.Glob(filepath.Join(dir, "*.*"))
+	require.NoError(t, err)
+	assert.Empty(t, matches, "the staged rekey folders should be removed")
+}
+
+func TestEncryptedFileRecoverRekey(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	dir := t.TempDir()
+	t.Setenv(EncryptedFileKeyEnv, testKey)
+	cfg := &config.Config{Keyring: &config.KeyringConfig{FileDir: &dir}}
+
+	b, err := openEncryptedFileBackend(cfg, "aws-iam-credentials")
+	require.NoError(t, err)
+	require.NoError(t, b.Set(keyring.Item{Key: "key", Data: []byte("credentials")}))
+
+	// a rekey which was killed between moving the storage to '.old' and moving the rekeyed storage into place
+	storage := filepath.Join(dir, "aws-iam-credentials")
+	require.NoError(t, os.Rename(storage, storage+".old"))
+	require.NoError(t, os.Mkdir(storage+".rekey", 0700))
+
+	b, err = openEncryptedFileBackend(cfg, "aws-iam-credentials")
+	require.NoError(t, err)
+	item, err := b.Get("key")
+	require.NoError(t, err)
+	assert.Equal(t, "credentials", string(item.Data))
+
+	matches, err := filepath.Glob(filepath.Join(dir, "*.*"))
+	require.NoError(t, err)
+	assert.Empty(t, matches)
+}
+
+func TestEncryptedFileLock(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	dir := t.TempDir()
+	t.Setenv(EncryptedFileKeyEnv, testKey)
+	cfg := &config.Config{Keyring: &config.KeyringConfig{FileDir: &dir}}
+
+	b, err := openEncryptedFileBackend(cfg, "aws-iam-credentials")
+	require.NoError(t, err)
+
+	// items can't be written while another process, such as a rekey, holds the lock
+	lock, err := filelock.Lock(encryptedFileLockName(dir), time.Second)
+	require.NoError(t, err)
+	timeout := encryptedFileLockTimeout
+	encryptedFileLockTimeout = 100 * time.Millisecond
+	t.Cleanup(func() { encryptedFileLockTimeout = timeout })
+
+	err = b.Set(keyring.Item{Key: "key", Data: []byte("credentials")})
+	assert.ErrorIs(t, err, filelock.ErrLocked)
+
+	require.NoError(t, lock.Unlock())
+	assert.NoError(t, b.Set(keyring.Item{Key: "key", Data: []byte("credentials")}))
+}
+
+func TestSwapRekeyedDirsRollback(t *testing.T) {
+	root := t.TempDir()
+	for _, name := range []string{"a", "a.rekey", "b"} {
+		require.NoError(t, os.Mkdir(filepath.Join(root, name), 0700))
+		require.NoError(t, os.WriteFile(filepath.Join(root, name, "item"), []byte(name), 0600))
+	}
+
+	// b doesn't have a staged folder, so it can't be replaced and a should be put back
+	err := swapRekeyedDirs([]string{filepath.Join(root, "a"), filepath.Join(root, "b")})
+	require.ErrorContains(t, err, "replacing storage b")
+
+	for _, name := range []string{"a", "b"} {
+		data, err := os.ReadFile(filepath.Join(root, name, "item"))
+		require.NoError(t, err)
+		assert.Equal(t, name, string(data))
+	}
+}
+
+func TestSSHAgentKey(t *testing.T) {
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	signer, err := ssh.NewSignerFromKey(priv)
+	require.NoError(t, err)
+
+	keys := agent.NewKeyring()
+	require.NoError(t, keys.Add(agent.AddedKey{PrivateKey: priv}))
+
+	sock := filepath.Join(t.TempDir(), "agent.sock")
+	l, err := net.Listen("unix", sock)
+	require.NoError(t, err)
+	defer l.Close()
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				_ = agent.ServeAgent(keys, conn)
+			}()
+		}
+	}()
+	t.Setenv("SSH_AUTH_SOCK", sock)
+
+	byFingerprint, err := EncryptedFileKeySource{SSHKey: ssh.FingerprintSHA256(signer.PublicKey())}.Key()
+	require.NoError(t, err)
+	byPublicKey, err := EncryptedFileKeySource{SSHKey: string(ssh.MarshalAuthorizedKey(signer.PublicKey()))}.Key()
+	require.NoError(t, err)
+	// Ed25519 signatures are deterministic, so the same key is derived each time
+	assert.Equal(t, byFingerprint, byPublicKey)
+
+	_, err = EncryptedFileKeySource{SSHKey: "SHA256:missing"}.Key()
+	assert.ErrorContains(t, err, "wasn't found in the SSH agent")
+}
@@ -0,0 +1,145 @@
+package securestorage
+
+import (
+	"encoding/base64"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/99designs/keyring"
+	"github.com/common-fate/granted/pkg/config"
+	"github.com/common-fate/granted/pkg/filelock"
+)
+
+// itemCipher encrypts and decrypts the items stored by an encryptedFileBackend.
+type itemCipher interface {
+	Encrypt(key string, plaintext []byte) ([]byte, error)
+	Decrypt(key string, ciphertext []byte) ([]byte, error)
+}
+
+// encryptedFileBackend stores each item in its own file in Dir, encrypted by Cipher.
+// The file name is the base64 encoded key, so that keys containing characters which aren't valid in file names can be stored.
+type encryptedFileBackend struct {
+	Dir    string
+	Ext    string
+	Cipher itemCipher
+	// Lock is the name of the lock taken while items are written or removed, so that they aren't
+	// changed while the storage is being rekeyed. Items are written without a lock if it is empty.
+	Lock string
+}
+
+// secureStorageDir returns the folder that file backends store items for the storage in.
+// If dir is empty, a folder in the Granted config folder is used.
+func secureStorageDir(dir string, backend string, storageSuffix string) (string, error) {
+	if dir != "" {
+		return filepath.Join(dir, storageSuffix), nil
+	}
+	grantedFolder, err := config.GrantedConfigFolder()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(grantedFolder, "secure-storage-"+backend, storageSuffix), nil
+}
+
+func (b *encryptedFileBackend) filename(key string) string {
+	return filepath.Join(b.Dir, base64.RawURLEncoding.EncodeToString([]byte(key))+b.Ext)
+}
+
+func (b *encryptedFileBackend) Get(key string) (keyring.Item, error) {
+	ciphertext, err := os.ReadFile(b.filename(key))
+	if errors.Is(err, fs.ErrNotExist) {
+		return keyring.Item{}, keyring.ErrKeyNotFound
+	}
+	if err != nil {
+		return keyring.Item{}, err
+	}
+	data, err := b.Cipher.Decrypt(key, ciphertext)
+	if err != nil {
+		return keyring.Item{}, err
+	}
+	return keyring.Item{Key: key, Data: data}, nil
+}
+
+// lock takes the backend's lock, if it has one, and returns a function to release it.
+func (b *encryptedFileBackend) lock() (func(), error) {
+	if b.Lock == "" {
+		return func() {}, nil
+	}
+	l, err := filelock.Lock(b.Lock, encryptedFileLockTimeout)
+	if err != nil {
+		return nil, err
+	}
+	return func() { _ = l.Unlock() }, nil
+}
+
+func (b *encryptedFileBackend) Set(item keyring.Item) error {
+	unlock, err := b.lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	ciphertext, err := b.Cipher.Encrypt(item.Key, item.Data)
+	if err != nil {
+		return err
+	}
+	err = os.MkdirAll(b.Dir, 0700)
+	if err != nil {
+		return err
+	}
+	// write to a temporary file and rename it, so that a concurrent Get never reads a partially written item
+	tmp, err := os.CreateTemp(b.Dir, ".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	_, err = tmp.Write(ciphertext)
+	if err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	err = tmp.Close()
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), b.filename(item.Key))
+}
+
+func (b *encryptedFileBackend) Remove(key string) error {
+	unlock, err := b.lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	err = os.Remove(b.filename(key))
+	if errors.Is(err, fs.ErrNotExist) {
+		return keyring.ErrKeyNotFound
+	}
+	return err
+}
+
+func (b *encryptedFileBackend) Keys() ([]string, error) {
+	entries, err := os.ReadDir(b.Dir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var keys []string
+	for _, e := range entries {
+		name, ok := strings.CutSuffix(e.Name(), b.Ext)
+		if e.IsDir() || !ok || strings.HasPrefix(name, ".") {
+			continue
+		}
+		key, err := base64.RawURLEncoding.DecodeString(name)
+		if err != nil {
+			continue
+		}
+		keys = append(keys, string(key))
+	}
+	return keys, nil
+}
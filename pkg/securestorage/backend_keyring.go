@@ -0,0 +1,79 @@
+package securestorage
+
+import (
+	"os"
+	"path"
+
+	"github.com/99designs/keyring"
+	"github.com/common-fate/clio"
+	"github.com/common-fate/granted/pkg/config"
+	"github.com/pkg/errors"
+)
+
+func openKeyringBackend(cfg *config.Config, storageSuffix string) (Backend, error) {
+	// the encrypted-file backend can be selected as a keyring backend, as it replaces the keyring's file backend on servers
+	if cfg.Keyring != nil && cfg.Keyring.Backend != nil && *cfg.Keyring.Backend == EncryptedFileBackend {
+		return openEncryptedFileBackend(cfg, storageSuffix)
+	}
+
+	grantedFolder, err := config.GrantedConfigFolder()
+	if err != nil {
+		return nil, err
+	}
+
+	secureStoragePath := path.Join(grantedFolder, "secure-storage-"+storageSuffix)
+	name := "granted-" + storageSuffix
+	c := keyring.Config{
+		ServiceName: name,
+
+		// MacOS keychain
+		KeychainName:             "login",
+		KeychainTrustApplication: true,
+
+		// KDE Wallet
+		KWalletAppID:  name,
+		KWalletFolder: name,
+
+		// Windows
+		WinCredPrefix: name,
+
+		// freedesktop.org's Secret Service
+		LibSecretCollectionName: name,
+
+		// Pass (https://www.passwordstore.org/)
+		PassPrefix: name,
+
+		// Fallback encrypted file
+		FileDir: secureStoragePath,
+
+		FilePasswordFunc: keyring.FixedStringPrompt(os.Getenv("CF_KEYRING_FILE_PASSWORD")),
+	}
+
+	// enable debug logging if the verbose flag is set in the CLI
+	keyring.Debug = clio.IsDebug()
+
+	if cfg.Keyring != nil {
+		if cfg.Keyring.Backend != nil {
+			c.AllowedBackends = []keyring.BackendType{keyring.BackendType(*cfg.Keyring.Backend)}
+		}
+		if cfg.Keyring.KeychainName != nil {
+			c.KeychainName = *cfg.Keyring.KeychainName
+		}
+		if cfg.Keyring.FileDir != nil {
+			c.FileDir = *cfg.Keyring.FileDir
+		}
+		if cfg.Keyring.LibSecretCollectionName != nil {
+			c.LibSecretCollectionName = *cfg.Keyring.LibSecretCollectionName
+		}
+		if cfg.Keyring.PassDir != nil {
+			c.PassDir = *cfg.Keyring.PassDir
+		}
+	}
+
+	k, err := keyring.Open(c)
+	if err != nil {
+		return nil, errors.Wrap(err, "opening keyring")
+	}
+
+	return k, nil
+}
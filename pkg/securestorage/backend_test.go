@@ -0,0 +1,108 @@
+package securestorage
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/99designs/keyring"
+	"github.com/common-fate/granted/pkg/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBackendName(t *testing.T) {
+	assert.Equal(t, KeyringBackend, backendName(&config.Config{}, "aws-sso-tokens"))
+
+	cfg := &config.Config{SecureStorage: &config.SecureStorageConfig{
+		Backend:  AgeBackend,
+		Storages: map[string]string{"aws-iam-credentials": OnePasswordBackend},
+	}}
+	assert.Equal(t, AgeBackend, backendName(cfg, "aws-sso-tokens"))
+	assert.Equal(t, OnePasswordBackend, backendName(cfg, "aws-iam-credentials"))
+}
+
+func TestSecureStorageWithBackend(t *testing.T) {
+	s := SecureStorage{StorageSuffix: "test", Backend: keyring.NewArrayKeyring(nil)}
+
+	err := s.Store("profile/with/slashes", map[string]string{"hello": "world"})
+	require.NoError(t, err)
+
+	var got map[string]string
+	err = s.Retrieve("profile/with/slashes", &got)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"hello": "world"}, got)
+
+	ok, err := s.HasKey("missing")
+	require.NoError(t, err)
+	assert.False(t, ok)
+}
+
+// testVaultTransit is a fake Vault transit engine, which 'encrypts' by base64 encoding the plaintext again.
+func testVaultTransit(t *testing.T) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Vault-Token") != "test-token" {
+			w.WriteHeader(http.StatusForbidden)
+			_ = json.NewEncoder(w).Encode(map[string]any{"errors": []string{"permission denied"}})
+			return
+		}
+		var req map[string]string
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+
+		var data map[string]string
+		switch r.URL.Path {
+		case "/v1/transit/encrypt/granted":
+			data = map[string]string{"ciphertext": "vault:v1:" + base64.StdEncoding.EncodeToString([]byte(req["plaintext"]))}
+		case "/v1/transit/decrypt/granted":
+			plaintext, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(req["ciphertext"], "vault:v1:"))
+			require.NoError(t, err)
+			data = map[string]string{"plaintext": string(plaintext)}
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(map[string]any{"data": data})
+	}))
+}
+
+func TestVaultTransitBackend(t *testing.T) {
+	srv := testVaultTransit(t)
+	defer srv.Close()
+
+	t.Setenv("VAULT_TOKEN", "test-token")
+	dir := t.TempDir()
+	cfg := &config.Config{SecureStorage: &config.SecureStorageConfig{
+		VaultTransit: &config.VaultTransitConfig{Address: srv.URL, KeyName: "granted", Dir: dir},
+	}}
+
+	b, err := openVaultTransitBackend(cfg, "aws-sso-tokens")
+	require.NoError(t, err)
+
+	_, err = b.Get("https://example.awsapps.com/start")
+	assert.ErrorIs(t, err, keyring.ErrKeyNotFound)
+
+	err = b.Set(keyring.Item{Key: "https://example.awsapps.com/start", Data: []byte(`{"token":"secret"}`)})
+	require.NoError(t, err)
+
+	item, err := b.Get("https://example.awsapps.com/start")
+	require.NoError(t, err)
+	assert.Equal(t, `{"token":"secret"}`, string(item.Data))
+
+	keys, err := b.Keys()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"https://example.awsapps.com/start"}, keys)
+
+	require.NoError(t, b.Remove("https://example.awsapps.com/start"))
+	keys, err = b.Keys()
+	require.NoError(t, err)
+	assert.Empty(t, keys)
+
+	t.Setenv("VAULT_TOKEN", "wrong-token")
+	b, err = openVaultTransitBackend(cfg, "aws-sso-tokens")
+	require.NoError(t, err)
+	err = b.Set(keyring.Item{Key: "key", Data: []byte("data")})
+	assert.ErrorContains(t, err, "permission denied")
+}
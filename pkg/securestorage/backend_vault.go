@@ -0,0 +1,142 @@
+package securestorage
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/common-fate/granted/pkg/config"
+)
+
+// vaultTransit encrypts items using the encrypt and decrypt endpoints of a HashiCorp Vault transit key.
+// The key never leaves Vault, so items can only be read while the user has a valid Vault token.
+type vaultTransit struct {
+	Address   string
+	MountPath string
+	KeyName   string
+	Token     string
+	Namespace string
+	Client    *http.Client
+}
+
+func openVaultTransitBackend(cfg *config.Config, storageSuffix string) (Backend, error) {
+	vc := cfg.SecureStorage.VaultTransit
+	if vc == nil || vc.KeyName == "" {
+		return nil, errors.New("the vault-transit secure storage backend requires SecureStorage.VaultTransit.KeyName to be set in the Granted config")
+	}
+
+	v := &vaultTransit{
+		Address:   vc.Address,
+		MountPath: vc.MountPath,
+		KeyName:   vc.KeyName,
+		Namespace: os.Getenv("VAULT_NAMESPACE"),
+		Client:    &http.Client{Timeout: 30 * time.Second},
+	}
+	if v.Address == "" {
+		v.Address = os.Getenv("VAULT_ADDR")
+	}
+	if v.Address == "" {
+		return nil, errors.New("the vault-transit secure storage backend requires VAULT_ADDR or SecureStorage.VaultTransit.Address to be set")
+	}
+	if v.MountPath == "" {
+		v.MountPath = "transit"
+	}
+
+	token, err := vaultToken()
+	if err != nil {
+		return nil, err
+	}
+	v.Token = token
+
+	dir, err := secureStorageDir(vc.Dir, VaultTransitBackend, storageSuffix)
+	if err != nil {
+		return nil, err
+	}
+
+	return &encryptedFileBackend{Dir: dir, Ext: ".vault", Cipher: v}, nil
+}
+
+// vaultToken returns the token from VAULT_TOKEN, or the token saved by 'vault login'.
+func vaultToken() (string, error) {
+	if token := os.Getenv("VAULT_TOKEN"); token != "" {
+		return token, nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	token, err := os.ReadFile(filepath.Join(home, ".vault-token"))
+	if err != nil {
+		return "", fmt.Errorf("the vault-transit secure storage backend requires VAULT_TOKEN to be set, or to log in using 'vault login': %w", err)
+	}
+	return strings.TrimSpace(string(token)), nil
+}
+
+func (v *vaultTransit) Encrypt(key string, plaintext []byte) ([]byte, error) {
+	var res struct {
+		Ciphertext string `json:"ciphertext"`
+	}
+	err := v.do("encrypt", map[string]string{"plaintext": base64.StdEncoding.EncodeToString(plaintext)}, &res)
+	if err != nil {
+		return nil, err
+	}
+	return []byte(res.Ciphertext), nil
+}
+
+func (v *vaultTransit) Decrypt(key string, ciphertext []byte) ([]byte, error) {
+	var res struct {
+		Plaintext string `json:"plaintext"`
+	}
+	err := v.do("decrypt", map[string]string{"ciphertext": string(ciphertext)}, &res)
+	if err != nil {
+		return nil, err
+	}
+	return base64.StdEncoding.DecodeString(res.Plaintext)
+}
+
+func (v *vaultTransit) do(operation string, body any, data any) error {
+	u, err := url.JoinPath(v.Address, "v1", v.MountPath, operation, v.KeyName)
+	if err != nil {
+		return err
+	}
+	b, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, u, bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("X-Vault-Token", v.Token)
+	req.Header.Set("Content-Type", "application/json")
+	if v.Namespace != "" {
+		req.Header.Set("X-Vault-Namespace", v.Namespace)
+	}
+
+	res, err := v.Client.Do(req)
+	if err != nil {
+		return fmt.Errorf("calling vault transit %s: %w", operation, err)
+	}
+	defer res.Body.Close()
+
+	var out struct {
+		Data   json.RawMessage `json:"data"`
+		Errors []string        `json:"errors"`
+	}
+	err = json.NewDecoder(res.Body).Decode(&out)
+	if err != nil {
+		return fmt.Errorf("vault transit %s returned %s: %w", operation, res.Status, err)
+	}
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("vault transit %s returned %s: %s", operation, res.Status, strings.Join(out.Errors, ", "))
+	}
+	return json.Unmarshal(out.Data, data)
+}
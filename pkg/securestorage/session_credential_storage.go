@@ -1,9 +1,12 @@
 package securestorage
 
 import (
+	"encoding/json"
 	"errors"
+	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/common-fate/clio"
 )
 
 type SessionCredentialSecureStorage struct {
@@ -18,25 +21,76 @@ func NewSecureSessionCredentialStorage() SessionCredentialSecureStorage {
 	}
 }
 
-func (i *SessionCredentialSecureStorage) GetCredentials(profile string) (*aws.Credentials, error) {
+// SessionCredentialMetadata describes how cached session credentials were obtained.
+type SessionCredentialMetadata struct {
+	Profile string `json:"profile"`
+	// Assumer is the type of the assumer which obtained the credentials, such as 'AWS_SSO'.
+	Assumer   string    `json:"assumer"`
+	RoleARN   string    `json:"roleArn,omitempty"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// SessionCredentialEntry is an entry in the session credential cache.
+type SessionCredentialEntry struct {
+	Key         string
+	Credentials aws.Credentials
+	// Metadata is nil for entries cached by older versions of Granted.
+	Metadata *SessionCredentialMetadata
+}
+
+// sessionCredentialItem is the stored form of an entry. The credentials are embedded,
+// so that entries cached by older versions of Granted can still be read.
+type sessionCredentialItem struct {
+	aws.Credentials
+	Metadata *SessionCredentialMetadata `json:",omitempty"`
+}
+
+func (i *SessionCredentialSecureStorage) GetCredentials(key string) (*aws.Credentials, error) {
 	// by default, set the credentials to be expiring so that we force a refresh if there are
 	// any problems unmarshalling them.
-	credentials := aws.Credentials{
-		CanExpire: true,
+	item := sessionCredentialItem{
+		Credentials: aws.Credentials{
+			CanExpire: true,
+		},
 	}
 
-	err := i.SecureStorage.Retrieve(profile, &credentials)
+	err := i.SecureStorage.Retrieve(key, &item)
 	if err != nil {
 		return nil, err
 	}
 
-	return &credentials, nil
+	return &item.Credentials, nil
+}
+
+func (i *SessionCredentialSecureStorage) StoreCredentials(key string, credentials aws.Credentials) (err error) {
+	return i.StoreCredentialsWithMetadata(key, credentials, nil)
 }
 
-func (i *SessionCredentialSecureStorage) StoreCredentials(profile string, credentials aws.Credentials) (err error) {
+// StoreCredentialsWithMetadata caches the credentials along with metadata describing how they were obtained,
+// which is shown by 'granted cache list'.
+func (i *SessionCredentialSecureStorage) StoreCredentialsWithMetadata(key string, credentials aws.Credentials, metadata *SessionCredentialMetadata) error {
 	if credentials.AccessKeyID == "" {
 		return errors.New("could not cache credentials: access key ID was empty")
 	}
-	err = i.SecureStorage.Store(profile, &credentials)
-	return
+	return i.SecureStorage.Store(key, &sessionCredentialItem{Credentials: credentials, Metadata: metadata})
+}
+
+// List returns every entry in the session credential cache.
+func (i *SessionCredentialSecureStorage) List() ([]SessionCredentialEntry, error) {
+	items, err := i.SecureStorage.List()
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []SessionCredentialEntry
+	for _, it := range items {
+		item := sessionCredentialItem{Credentials: aws.Credentials{CanExpire: true}}
+		err = json.Unmarshal(it.Data, &item)
+		if err != nil {
+			clio.Debugw("skipping cached session credentials which could not be read", "key", it.Key, "error", err)
+			continue
+		}
+		entries = append(entries, SessionCredentialEntry{Key: it.Key, Credentials: item.Credentials, Metadata: item.Metadata})
+	}
+	return entries, nil
 }
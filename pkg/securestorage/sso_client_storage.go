@@ -0,0 +1,49 @@
+package securestorage
+
+import (
+	"errors"
+	"time"
+
+	"github.com/99designs/keyring"
+)
+
+// SSOClientRegistrationSecureStorage stores OIDC client registrations for IAM Identity Center,
+// so that a single registration can be reused across logins rather than registering
+// a new client every time.
+type SSOClientRegistrationSecureStorage struct {
+	SecureStorage SecureStorage
+}
+
+func NewSecureSSOClientRegistrationStorage() SSOClientRegistrationSecureStorage {
+	return SSOClientRegistrationSecureStorage{
+		SecureStorage: SecureStorage{
+			StorageSuffix: "aws-sso-client-registrations",
+		},
+	}
+}
+
+type ClientRegistration struct {
+	ClientID     string    `json:"clientId"`
+	ClientSecret string    `json:"clientSecret"`
+	ExpiresAt    time.Time `json:"expiresAt"`
+	StartURL     string    `json:"startUrl"`
+	Region       string    `json:"region"`
+	Scopes       []string  `json:"scopes,omitempty"`
+}
+
+// GetRegistration returns the stored client registration, or nil if there isn't one.
+func (s *SSOClientRegistrationSecureStorage) GetRegistration(key string) (*ClientRegistration, error) {
+	var r ClientRegistration
+	err := s.SecureStorage.Retrieve(key, &r)
+	if errors.Is(err, keyring.ErrKeyNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
+
+func (s *SSOClientRegistrationSecureStorage) StoreRegistration(key string, registration ClientRegistration) error {
+	return s.SecureStorage.Store(key, registration)
+}
@@ -2,6 +2,8 @@ package securestorage
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"strings"
 	"time"
 
@@ -38,6 +40,12 @@ type SSOToken struct {
 	RegistrationExpiresAt time.Time `json:"registrationExpiresAt,omitempty"`
 	Region                string    `json:"region,omitempty"`
 	RefreshToken          *string   `json:"refreshToken,omitempty"`
+	// StartURL and Scopes allow the client registration used for the token to be renewed.
+	StartURL string   `json:"startUrl,omitempty"`
+	Scopes   []string `json:"scopes,omitempty"`
+	// Flow is the login flow which the client registration was made for.
+	// Tokens stored by earlier versions of Granted don't have a flow, and used the device code flow.
+	Flow string `json:"flow,omitempty"`
 }
 
 // GetValidSSOToken loads and potentially refreshes an AWS SSO access token from secure storage.
@@ -61,30 +69,48 @@ func (s *SSOTokensSecureStorage) GetValidSSOToken(ctx context.Context, profileKe
 		return &t
 	}
 
-	if t.RefreshToken == nil {
+	if !t.CanRefresh() {
 		// can't refresh the token, so return nil
 		return nil
 	}
 
-	if *t.RefreshToken == "" {
-		// can't refresh the token, so return nil
+	if !t.RegistrationExpiresAt.IsZero() && t.RegistrationExpiresAt.Before(now) {
+		// the client registration used to obtain the token has lapsed, so the refresh token can't be used
+		clio.Debugw("not refreshing token as the OIDC client registration has expired", "registrationExpiresAt", t.RegistrationExpiresAt)
 		return nil
 	}
 
 	// if we get here, we can attempt to refresh the token
-	cfg, err := config.LoadDefaultConfig(ctx)
+	newToken, err := s.RefreshSSOToken(ctx, profileKey, t)
 	if err != nil {
-		clio.Errorf("error loading default AWS config for token refresh: %s", err.Error())
+		clio.Errorf("error refreshing AWS IAM Identity Center token: %s", err.Error())
 		// token is invalid
 		return nil
 	}
 
+	return newToken
+}
+
+// CanRefresh returns true if the token has a refresh token which can be used to obtain a new access token.
+func (t SSOToken) CanRefresh() bool {
+	return t.RefreshToken != nil && *t.RefreshToken != ""
+}
+
+// RefreshSSOToken uses the refresh token to obtain a new access token, and saves it to secure storage.
+func (s *SSOTokensSecureStorage) RefreshSSOToken(ctx context.Context, profileKey string, t SSOToken) (*SSOToken, error) {
+	if !t.CanRefresh() {
+		return nil, errors.New("token does not have a refresh token")
+	}
+
+	cfg, err := config.LoadDefaultConfig(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("loading default AWS config for token refresh: %w", err)
+	}
+
 	if t.Region == "" {
 		// if the region is not set, the AWS SSO OIDC client will make an invalid API call and will return an
 		// 'InvalidGrantException' error.
-		clio.Errorf("existing token had no SSO region set")
-		// token is invalid
-		return nil
+		return nil, errors.New("existing token had no SSO region set")
 	}
 
 	cfg.Region = t.Region
@@ -98,9 +124,7 @@ func (s *SSOTokensSecureStorage) GetValidSSOToken(ctx context.Context, profileKe
 		RefreshToken: t.RefreshToken,
 	})
 	if err != nil {
-		clio.Errorf("error refreshing AWS IAM Identity Center token: %s", err.Error())
-		// token is invalid
-		return nil
+		return nil, err
 	}
 
 	newToken := SSOToken{
@@ -111,12 +135,20 @@ func (s *SSOTokensSecureStorage) GetValidSSOToken(ctx context.Context, profileKe
 		RegistrationExpiresAt: t.RegistrationExpiresAt, // same as the previous token, because the same client was used to refresh
 		RefreshToken:          res.RefreshToken,
 		Region:                t.Region,
+		StartURL:              t.StartURL,
+		Scopes:                t.Scopes,
+		Flow:                  t.Flow,
+	}
+
+	// the refresh token may not be rotated, in which case the existing one remains valid
+	if !newToken.CanRefresh() {
+		newToken.RefreshToken = t.RefreshToken
 	}
 
 	// save the refreshed token to secure storage
 	s.StoreSSOToken(profileKey, newToken)
 
-	return &newToken
+	return &newToken, nil
 }
 
 // Attempts to store the token, any errors will be logged to debug logging
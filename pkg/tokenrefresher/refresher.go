@@ -0,0 +1,236 @@
+// package tokenrefresher proactively refreshes IAM Identity Center access tokens
+// stored in secure storage before they expire, so that users are not sent through
+// the device authorization flow in the middle of their work.
+package tokenrefresher
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/common-fate/clio"
+	"github.com/common-fate/granted/pkg/config"
+	"github.com/common-fate/granted/pkg/idclogin"
+	"github.com/common-fate/granted/pkg/securestorage"
+)
+
+// Status is the refresher's view of a single stored token.
+type Status struct {
+	ExpiresAt             time.Time  `json:"expiresAt"`
+	RegistrationExpiresAt *time.Time `json:"registrationExpiresAt,omitempty"`
+	Refreshable           bool       `json:"refreshable"`
+	LastCheckedAt         time.Time  `json:"lastCheckedAt"`
+	LastRefreshedAt       *time.Time `json:"lastRefreshedAt,omitempty"`
+	LastError             string     `json:"lastError,omitempty"`
+	// RegistrationRenewedAt and RenewedRegistrationExpiresAt are set when the refresher has registered a
+	// new client ahead of the token's registration expiring. The token keeps using its original registration,
+	// as its refresh token is bound to that client, so the renewed registration takes effect at the next login.
+	RegistrationRenewedAt        *time.Time `json:"registrationRenewedAt,omitempty"`
+	RenewedRegistrationExpiresAt *time.Time `json:"renewedRegistrationExpiresAt,omitempty"`
+}
+
+// State is persisted by the refresher after each check, so that other
+// commands such as 'granted sso-tokens expiry' can report on it.
+type State struct {
+	PID       int               `json:"pid"`
+	UpdatedAt time.Time         `json:"updatedAt"`
+	Tokens    map[string]Status `json:"tokens"`
+}
+
+func statePath() (string, error) {
+	stateFolder, err := config.GrantedStateFolder()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(stateFolder, "sso-refresher.json"), nil
+}
+
+// LoadState loads the last saved refresher state. If the refresher has never run, an empty state is returned.
+func LoadState() (*State, error) {
+	p, err := statePath()
+	if err != nil {
+		return nil, err
+	}
+
+	b, err := os.ReadFile(p)
+	if errors.Is(err, os.ErrNotExist) {
+		return &State{Tokens: map[string]Status{}}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var s State
+	err = json.Unmarshal(b, &s)
+	if err != nil {
+		return nil, err
+	}
+	if s.Tokens == nil {
+		s.Tokens = map[string]Status{}
+	}
+	return &s, nil
+}
+
+func (s *State) save() error {
+	p, err := statePath()
+	if err != nil {
+		return err
+	}
+	b, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(p, b, 0600)
+}
+
+type Refresher struct {
+	Storage securestorage.SSOTokensSecureStorage
+	// Window is how long before expiry a token is refreshed.
+	Window time.Duration
+	// RegistrationWindow is how long before a client registration expires that it is renewed.
+	RegistrationWindow time.Duration
+	// Interval is how often stored tokens are checked.
+	Interval time.Duration
+}
+
+// Run checks the stored tokens every Interval until the context is cancelled.
+func (r *Refresher) Run(ctx context.Context) error {
+	clio.Infof("Started IAM Identity Center token refresher, checking tokens every %s", r.Interval)
+
+	ticker := time.NewTicker(r.Interval)
+	defer ticker.Stop()
+
+	for {
+		_, err := r.RunOnce(ctx)
+		if err != nil {
+			clio.Errorf("error checking IAM Identity Center tokens: %s", err.Error())
+		}
+
+		select {
+		case <-ctx.Done():
+			clio.Info("Stopped IAM Identity Center token refresher")
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// RunOnce checks every stored token, refreshing any which are due to expire within the window.
+func (r *Refresher) RunOnce(ctx context.Context) (*State, error) {
+	state, err := LoadState()
+	if err != nil {
+		clio.Debugw("error loading refresher state, starting with an empty state", "error", err)
+		state = &State{Tokens: map[string]Status{}}
+	}
+
+	keys, err := r.Storage.SecureStorage.ListKeys()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	tokens := map[string]Status{}
+
+	for _, key := range keys {
+		status := state.Tokens[key]
+		status.LastCheckedAt = now
+		status.LastError = ""
+
+		var t securestorage.SSOToken
+		err := r.Storage.SecureStorage.Retrieve(key, &t)
+		if err != nil {
+			status.LastError = err.Error()
+			tokens[key] = status
+			continue
+		}
+
+		status.ExpiresAt = t.Expiry
+		status.Refreshable = t.CanRefresh()
+		status.RegistrationExpiresAt = nil
+		if !t.RegistrationExpiresAt.IsZero() {
+			registrationExpiresAt := t.RegistrationExpiresAt
+			status.RegistrationExpiresAt = &registrationExpiresAt
+		}
+
+		if status.RegistrationExpiresAt == nil || status.RegistrationExpiresAt.Add(-r.RegistrationWindow).After(now) {
+			// the token's registration isn't due for renewal, which is also the case after logging in again with a renewed registration
+			status.RegistrationRenewedAt = nil
+			status.RenewedRegistrationExpiresAt = nil
+		} else if status.RenewedRegistrationExpiresAt == nil || status.RenewedRegistrationExpiresAt.Add(-r.RegistrationWindow).Before(now) {
+			clio.Warnf("the OIDC client registration for %s expires at %s, after which the token can no longer be refreshed", key, status.RegistrationExpiresAt.Local().Format(time.RFC3339))
+			registration := r.renewClientRegistration(ctx, key, t)
+			if registration != nil {
+				renewedAt := time.Now()
+				status.RegistrationRenewedAt = &renewedAt
+				status.RenewedRegistrationExpiresAt = &registration.ExpiresAt
+			}
+		}
+
+		if t.Expiry.Add(-r.Window).After(now) {
+			clio.Debugw("token does not need refreshing", "key", key, "expires", t.Expiry)
+			tokens[key] = status
+			continue
+		}
+
+		if !status.Refreshable {
+			clio.Debugw("token is due to expire but cannot be refreshed", "key", key, "expires", t.Expiry)
+			tokens[key] = status
+			continue
+		}
+
+		newToken, err := r.Storage.RefreshSSOToken(ctx, key, t)
+		if err != nil {
+			clio.Errorf("error refreshing token for %s: %s", key, err.Error())
+			status.LastError = err.Error()
+			tokens[key] = status
+			continue
+		}
+
+		refreshedAt := time.Now()
+		status.LastRefreshedAt = &refreshedAt
+		status.ExpiresAt = newToken.Expiry
+		clio.Infof("refreshed token for %s, now expires at %s", key, newToken.Expiry.Local().Format(time.RFC3339))
+		tokens[key] = status
+	}
+
+	state.PID = os.Getpid()
+	state.UpdatedAt = now
+	state.Tokens = tokens
+
+	err = state.save()
+	if err != nil {
+		return nil, err
+	}
+
+	return state, nil
+}
+
+// renewClientRegistration registers a new OIDC client ahead of the current registration expiring,
+// so that the next login for the start URL uses a fresh registration.
+// The registration is made for the flow that the token was obtained with. It returns nil if the registration couldn't be renewed.
+func (r *Refresher) renewClientRegistration(ctx context.Context, key string, t securestorage.SSOToken) *securestorage.ClientRegistration {
+	if t.StartURL == "" || t.Region == "" {
+		clio.Debugw("unable to renew client registration as the token has no start URL or region", "key", key)
+		return nil
+	}
+
+	cfg := aws.NewConfig()
+	cfg.Region = t.Region
+
+	flow := idclogin.Flow(t.Flow)
+	if flow == "" {
+		flow = idclogin.FlowDeviceCode
+	}
+
+	registration, err := idclogin.RenewClientRegistration(ctx, *cfg, flow, t.StartURL, t.Scopes, r.RegistrationWindow)
+	if err != nil {
+		clio.Errorf("error renewing OIDC client registration for %s: %s", key, err.Error())
+		return nil
+	}
+	clio.Infof("OIDC client registration for %s is valid until %s, and will be used at the next login", t.StartURL, registration.ExpiresAt.Local().Format(time.RFC3339))
+	return registration
+}
@@ -51,3 +51,31 @@ func TestFlagsPassToCFFlags(t *testing.T) {
 	}
 
 }
+
+func TestArgs(t *testing.T) {
+	app := cli.App{
+		Name:  "test",
+		Flags: testingFlags,
+		Action: func(c *cli.Context) error {
+			assumeFlags, err := New("assumeFlags", testingFlags, c)
+			if err != nil {
+				return err
+			}
+
+			args, err := assumeFlags.Args()
+			if err != nil {
+				return err
+			}
+
+			assert.Equal(t, []string{"second-role", "third-role"}, args)
+			assert.Equal(t, true, assumeFlags.Bool("testbool"))
+			assert.Equal(t, "region-name", assumeFlags.String("teststringregion"))
+			return nil
+		},
+	}
+
+	os.Args = []string{"", "test-role", "second-role", "-c", "third-role", "-r", "region-name", "--", "cmd"}
+
+	err := app.Run(os.Args)
+	assert.NoError(t, err)
+}
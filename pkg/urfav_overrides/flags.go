@@ -176,3 +176,31 @@ func (set *Flags) Int64(name string) int64 {
 	}
 	return 0
 }
+
+// Args returns the positional arguments following the role arg, for example additional profile names
+// in 'assume profile-a profile-b -r us-east-1'. Flags interleaved with the arguments are parsed.
+//
+// Parsing stops at a '--' argument, which is used to pass a command to --exec.
+func (set *Flags) Args() ([]string, error) {
+	var args []string
+	rest := set.FlagSet.Args()
+	for i, arg := range rest {
+		if arg == "--" {
+			rest = rest[:i]
+			break
+		}
+	}
+	for len(rest) > 0 {
+		if strings.HasPrefix(rest[0], "-") {
+			err := set.FlagSet.Parse(rest)
+			if err != nil {
+				return nil, err
+			}
+			rest = set.FlagSet.Args()
+			continue
+		}
+		args = append(args, rest[0])
+		rest = rest[1:]
+	}
+	return args, nil
+}
@@ -25,6 +25,7 @@ for /f "tokens=1,2,3,4,5,6,7,8,9,10,11 delims= " %%a in ("%ASSUME_OUTPUT%") do (
         set GRANTED_SSO_ROLE_NAME=
         set GRANTED_SSO_REGION=
         set GRANTED_SSO_ACCOUNT_ID=
+        call :granted_apply_env
         Exit /b %ASSUME_STATUS%
     )
     
@@ -78,10 +79,34 @@ for /f "tokens=1,2,3,4,5,6,7,8,9,10,11 delims= " %%a in ("%ASSUME_OUTPUT%") do (
 
         if "%%l" NEQ "None" (
             set GRANTED_SSO_ACCOUNT_ID=%%g)
-        
+
+        call :granted_apply_env
         Exit /b %ASSUME_STATUS%
     )
 
     
     echo %ASSUME_OUTPUT%
 )
+goto :eof
+
+rem GrantedAssume and GrantedDesume are followed by KEY=VALUE assignments after the positional fields.
+rem Each variable is set, or cleared if the value is empty, so that new variables don't require changes to this script.
+:granted_apply_env
+set "GRANTED_REST=%ASSUME_OUTPUT%"
+for /l %%n in (1,1,13) do call :granted_next_token
+:granted_apply_env_loop
+call :granted_next_token
+if not defined GRANTED_TOKEN goto :eof
+for /f "tokens=1,* delims==" %%k in ("%GRANTED_TOKEN%") do set "%%k=%%l"
+goto granted_apply_env_loop
+
+:granted_next_token
+set "GRANTED_TOKEN="
+set "GRANTED_LINE=%GRANTED_REST%"
+set "GRANTED_REST="
+if not defined GRANTED_LINE goto :eof
+for /f "tokens=1,*" %%a in ("%GRANTED_LINE%") do (
+    set "GRANTED_TOKEN=%%a"
+    set "GRANTED_REST=%%b"
+)
+goto :eof
@@ -12,6 +12,22 @@ set GRANTED_STATUS $status
 echo $GRANTED_OUTPUT | IFS=' ' read GRANTED_FLAG GRANTED_1 GRANTED_2 GRANTED_3 GRANTED_4 GRANTED_5 GRANTED_6 GRANTED_7 GRANTED_8 GRANTED_9 GRANTED_10 GRANTED_11 GRANTED_12
 
 
+# GrantedAssume and GrantedDesume are followed by KEY=VALUE assignments after the positional fields,
+# which are read into GRANTED_12 along with the last positional field. Each variable is exported,
+# or unset if the value is empty, so that new variables don't require changes to this script.
+function __granted_apply_env
+  for assignment in (string split ' ' -- $argv[1])
+    set -l kv (string split -m 1 '=' -- $assignment)
+    if test (count $kv) -eq 2
+      if test -z "$kv[2]"
+        set -e $kv[1]
+      else
+        set -gx $kv[1] $kv[2]
+      end
+    end
+  end
+end
+
 # remove carriage return
 set -gx GRANTED_FLAG (echo $GRANTED_FLAG | tr -d '\r')
 
@@ -31,6 +47,7 @@ else if test "$GRANTED_FLAG" = "GrantedDesume"
   set -e GRANTED_SSO_ROLE_NAME
   set -e GRANTED_SSO_REGION
   set -e GRANTED_SSO_ACCOUNT_ID
+  __granted_apply_env "$GRANTED_12"
 else if test "$GRANTED_FLAG" = "GrantedAssume"
   set -e AWS_ACCESS_KEY_ID
   set -e AWS_SECRET_ACCESS_KEY
@@ -82,6 +99,7 @@ else if test "$GRANTED_FLAG" = "GrantedAssume"
   if test "$GRANTED_11" != "None"
     set -gx GRANTED_SSO_ACCOUNT_ID $GRANTED_11
   end
+  __granted_apply_env "$GRANTED_12"
 
 else if test "$GRANTED_FLAG" = "GrantedOutput"
   for line in $GRANTED_OUTPUT
@@ -4,10 +4,22 @@
 #ASSUME_n - the data from assumego
 $env:SHELL="ps"
 $env:GRANTED_ALIAS_CONFIGURED="true"
-$ASSUME_FLAG, $ASSUME_1, $ASSUME_2, $ASSUME_3, $ASSUME_4, $ASSUME_5, $ASSUME_6, $ASSUME_7, $ASSUME_8, $ASSUME_9, $ASSUME_10, $ASSUME_11= `
+$ASSUME_FLAG, $ASSUME_1, $ASSUME_2, $ASSUME_3, $ASSUME_4, $ASSUME_5, $ASSUME_6, $ASSUME_7, $ASSUME_8, $ASSUME_9, $ASSUME_10, $ASSUME_11, $ASSUME_12, $ASSUME_ENV= `
 $(& (Join-Path $PSScriptRoot -ChildPath "assumego") $args) -split '\s+'
 $env:ASSUME_STATUS = $LASTEXITCODE
 
+# GrantedAssume and GrantedDesume are followed by KEY=VALUE assignments after the positional fields.
+# Each variable is set, or cleared if the value is empty, so that new variables don't require changes to this script.
+function Set-GrantedEnv($Assignments) {
+    foreach ($assignment in $Assignments) {
+        $key, $value = $assignment -split '=', 2
+        if ($null -eq $value) {
+            continue
+        }
+        Set-Item -Path "env:$key" -Value $value
+    }
+}
+
 
 if ( $ASSUME_FLAG -eq "GrantedDesume" ) {
     $env:AWS_ACCESS_KEY_ID = ""
@@ -23,6 +35,7 @@ if ( $ASSUME_FLAG -eq "GrantedDesume" ) {
     $env:GRANTED_SSO_ROLE_NAME = ""
     $env:GRANTED_SSO_REGION = ""
     $env:GRANTED_SSO_ACCOUNT_ID = ""
+    Set-GrantedEnv $ASSUME_ENV
     exit
 }
 
@@ -88,6 +101,8 @@ elseif ( $ASSUME_FLAG -eq "GrantedAssume") {
     if ( $ASSUME_11 -ne "None" ) {
         $env:GRANTED_SSO_ACCOUNT_ID = $ASSUME_11
     }
+
+    Set-GrantedEnv $ASSUME_ENV
 }
 
 